@@ -5,10 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"time"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/patrickdappollonio/http-server/internal/server"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -17,7 +22,7 @@ import (
 )
 
 const (
-	configFilePrefix = ".http-server" // no extension, cobra will pick from several options
+	configFilePrefix = ".http-server" // no extension, one of configFileExtensions
 	envVarPrefix     = "file_server_" // case insensitive, it's uppercased in code
 )
 
@@ -54,9 +59,11 @@ func run() error {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 
-		// Bind viper settings against the root command
+		// Bind viper settings against the root command, while
+		// subcommands read the settings they need on their own
 		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
-			return bindCobraAndViper(cmd)
+			_, err := loadSettings(cmd.Root(), &srv)
+			return err
 		},
 
 		// Execute the server
@@ -70,16 +77,18 @@ func run() error {
 				return fmt.Errorf("unable to validate configuration: %w", err)
 			}
 
-			// Add custom CSS file to skip force download list if specified
-			if srv.CustomCSS != "" {
-				srv.SkipForceDownloadFiles = append(srv.SkipForceDownloadFiles, srv.CustomCSS)
-			}
-
 			// Load redirections file if enabled
 			if err := srv.LoadRedirectionsIfEnabled(); err != nil {
 				return fmt.Errorf("unable to load redirections file: %w", err)
 			}
 
+			// Read the settings again from the same flags, environment
+			// variables and configuration file when the file changes
+			args := os.Args[1:]
+			srv.SetConfigLoader(func() (*server.Server, error) {
+				return reloadSettings(args)
+			})
+
 			// Print some sane defaults and some information about the request
 			srv.PrintStartup()
 
@@ -106,35 +115,122 @@ func run() error {
 	})
 
 	// Define the flags for the root command
-	flags := rootCmd.Flags()
+	defineFlags(rootCmd.Flags(), &srv)
+
+	// Add subcommands
+	rootCmd.AddCommand(newRedirectsCommand(), newConfigCommand())
+
+	//nolint:wrapcheck // no need to wrap this error
+	return rootCmd.Execute()
+}
+
+// defineFlags defines the flags of the root command, setting the values
+// of the server settings
+func defineFlags(flags *pflag.FlagSet, srv *server.Server) {
+	flags.StringVar(&srv.ConfigFile, "config", "", "path to a YAML, TOML or JSON configuration file, instead of looking for one in the working directory, the served path, \"$XDG_CONFIG_HOME/http-server\" and \"/etc/http-server\"")
 	flags.IntVarP(&srv.Port, "port", "p", 5000, "port to configure the server to listen on")
+	flags.StringArrayVar(&srv.Listen, "listen", nil, "address to listen on instead of all interfaces on the port, as \"host:port\", \"[::1]:port\" or \"unix:/path/to/file.sock\" (can be repeated)")
+	flags.StringVar(&srv.SocketMode, "socket-mode", "0660", "permissions of the Unix domain sockets set with --listen, in octal")
+	flags.DurationVar(&srv.ReadTimeout, "read-timeout", 0, "maximum duration for reading an entire request, including the body, or 0 for no limit")
+	flags.DurationVar(&srv.ReadHeaderTimeout, "read-header-timeout", 10*time.Second, "maximum duration for reading the headers of a request, or 0 for no limit")
+	flags.DurationVar(&srv.WriteTimeout, "write-timeout", 0, "maximum duration for writing a response, including the download of big files, or 0 for no limit")
+	flags.DurationVar(&srv.IdleTimeout, "idle-timeout", 2*time.Minute, "maximum duration to keep idle keep-alive connections open, or 0 for no limit")
+	flags.StringVar(&srv.MaxHeaderBytes, "max-header-bytes", "1M", "maximum size of the headers of a request")
+	flags.DurationVar(&srv.ShutdownTimeout, "shutdown-timeout", 5*time.Minute, "maximum duration to wait for in-flight requests when stopping, or 0 for no limit")
+	flags.DurationVar(&srv.DrainPeriod, "drain-period", 0, "duration to keep serving requests while the health check fails, before stopping, so load balancers stop sending requests")
 	flags.StringVarP(&srv.Path, "path", "d", "./", "path to the directory you want to serve")
 	flags.StringVar(&srv.PathPrefix, "pathprefix", "/", "path prefix for the URL where the server will listen on")
 	flags.BoolVar(&srv.CorsEnabled, "cors", false, "enable CORS support by setting the \"Access-Control-Allow-Origin\" header to \"*\"")
+	flags.BoolVar(&srv.StatusEnabled, "status-endpoint", false, "enable the \"/_/status\" endpoint, reporting the settings and usage of the server as JSON, behind the same authentication as the files")
 	flags.StringVar(&srv.Username, "username", "", "username for basic authentication")
 	flags.StringVar(&srv.Password, "password", "", "password for basic authentication")
+	flags.StringVar(&srv.PasswordFile, "password-file", "", "path to a file with the password for basic authentication, like a Docker or Kubernetes secret, instead of --password")
 	flags.StringVar(&srv.PageTitle, "title", "", "title of the directory listing page")
 	flags.BoolVar(&srv.HideLinks, "hide-links", false, "hide the links to this project's source code visible in the header and footer")
 	flags.BoolVar(&srv.DisableCacheBuster, "disable-cache-buster", false, "disable the cache buster for assets from the directory listing feature")
 	flags.BoolVar(&srv.DisableMarkdown, "disable-markdown", false, "disable the markdown rendering feature")
 	flags.BoolVar(&srv.MarkdownBeforeDir, "markdown-before-dir", false, "render markdown content before the directory listing")
 	flags.StringVar(&srv.JWTSigningKey, "jwt-key", "", "signing key for JWT authentication")
+	flags.StringVar(&srv.JWTSigningKeyFile, "jwt-key-file", "", "path to a file with the signing key for JWT authentication, like a Docker or Kubernetes secret, instead of --jwt-key")
 	flags.BoolVar(&srv.ValidateTimedJWT, "ensure-unexpired-jwt", false, "enable time validation for JWT claims \"exp\" and \"nbf\"")
 	flags.StringVar(&srv.BannerMarkdown, "banner", "", "markdown text to be rendered at the top of the directory listing page")
 	flags.BoolVar(&srv.ETagDisabled, "disable-etag", false, "disable etag header generation")
-	flags.StringVar(&srv.ETagMaxSize, "etag-max-size", "5M", "maximum size for etag header generation, where bigger size = more memory usage")
-	flags.BoolVar(&srv.GzipEnabled, "gzip", false, "enable gzip compression for supported content-types")
+	flags.StringVar(&srv.ETagMaxSize, "etag-max-size", "5M", "maximum size for etag header generation of directory listings and rendered markdown, and for hashing files synchronously with --etag-hash-cache, where bigger size = more memory usage")
+	flags.StringVar(&srv.ETagHashCache, "etag-hash-cache", "", "path to a file where content hashes of files are saved, to generate etag headers from the content of files rather than their metadata")
+	flags.BoolVar(&srv.Precompressed, "precompressed", false, "serve precompressed versions of files, like \"app.js.br\" or \"app.js.gz\", in place of the original files to clients accepting them")
+	flags.BoolVar(&srv.GzipEnabled, "gzip", false, "enable gzip compression for supported content-types, same as \"--compression=gzip\"")
+	flags.StringSliceVar(&srv.Compression, "compression", nil, "encodings to compress responses with for supported content-types, in order of preference: \"gzip\", \"br\" or \"zstd\"")
+	flags.StringVar(&srv.CompressionMinSize, "compression-min-size", "1K", "minimum size of a response for it to be compressed")
+	flags.StringVar(&srv.CacheSize, "cache-size", "0", "size of the in-memory cache for small files and rendered markdown, like \"256M\", or 0 to disable it")
+	flags.StringArrayVar(&srv.CacheControl, "cache-control", nil, "rule to set the Cache-Control header of files and listings, as a path, filename or content type pattern followed by directives, like \"*.html no-cache\" or \"fingerprinted\" (can be repeated)")
+	flags.BoolVar(&srv.HideDotfiles, "hide-dotfiles", false, "hide files and directories starting with a dot, except \".well-known\", from listings and direct access")
+	flags.StringArrayVar(&srv.Exclude, "exclude", nil, "patterns of files and directories to hide from listings and direct access, using the .gitignore syntax, in addition to those in a \".httpserverignore\" file at the root of the served path (can be repeated)")
+	flags.StringVar(&srv.Symlinks, "symlinks", "within-root", "how to handle symbolic links within the served path: \"follow\" them anywhere, only \"within-root\" when pointing within the served path, or \"deny\" them")
 	flags.BoolVar(&srv.DisableRedirects, "disable-redirects", false, "disable redirection file handling")
 	flags.BoolVar(&srv.DisableDirectoryList, "disable-directory-listing", false, "disable the directory listing feature and return 404s for directories without index")
 	flags.StringVar(&srv.CustomNotFoundPage, "custom-404", "", "custom \"page not found\" to serve")
 	flags.IntVar(&srv.CustomNotFoundStatusCode, "custom-404-code", 0, "custom status code for pages not found")
+	flags.StringVar(&srv.SPAFallback, "spa-fallback", "", "path within the served files to serve for client-side routes of a single-page application, like \"index.html\"")
 	flags.BoolVar(&srv.HideFilesInMarkdown, "hide-files-in-markdown", false, "hide file and directory listing in markdown rendering")
 	flags.StringVar(&srv.CustomCSS, "custom-css-file", "", "path within the served files to a custom CSS file")
 	flags.BoolVar(&srv.FullMarkdownRender, "render-all-markdown", false, "if enabled, all Markdown files will be rendered using the same rendering as the directory listing READMEs")
 	flags.StringSliceVar(&srv.ForceDownloadExtensions, "force-download-extensions", nil, "file extensions that should be downloaded instead of displayed in browser")
+}
 
-	//nolint:wrapcheck // no need to wrap this error
-	return rootCmd.Execute()
+// loadSettings binds the flags of the command against environment
+// variables and the configuration file, and reads the settings that can
+// only be set in the configuration file, returning where each setting
+// comes from
+func loadSettings(cmd *cobra.Command, srv *server.Server) (settingSources, error) {
+	v, sources, err := bindCobraAndViper(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep track of the configuration file, to reload it on changes
+	srv.ConfigFile = v.ConfigFileUsed()
+
+	// Mounts and virtual hosts have no flags, and can only
+	// be set in the configuration file
+	decodeReferences := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		referenceDecodeHook,
+	))
+
+	if err := v.UnmarshalKey("mounts", &srv.Mounts, decodeReferences); err != nil {
+		return nil, fmt.Errorf("unable to read mounts from configuration file: %w", err)
+	}
+
+	if err := v.UnmarshalKey("vhosts", &srv.VirtualHosts, decodeReferences); err != nil {
+		return nil, fmt.Errorf("unable to read virtual hosts from configuration file: %w", err)
+	}
+
+	// Add custom CSS file to skip force download list if specified
+	if srv.CustomCSS != "" {
+		srv.SkipForceDownloadFiles = append(srv.SkipForceDownloadFiles, srv.CustomCSS)
+	}
+
+	return sources, nil
+}
+
+// reloadSettings reads the server settings again from the command line
+// arguments, the environment variables and the configuration file
+func reloadSettings(args []string) (*server.Server, error) {
+	next := &server.Server{ConfigFilePrefix: configFilePrefix}
+
+	cmd := &cobra.Command{Use: "http-server"}
+	defineFlags(cmd.Flags(), next)
+
+	if err := cmd.Flags().Parse(args); err != nil {
+		return nil, fmt.Errorf("unable to parse flags: %w", err)
+	}
+
+	if _, err := loadSettings(cmd, next); err != nil {
+		return nil, err
+	}
+
+	return next, nil
 }
 
 // sendPipeToLogger reads from the pipe and sends the output to the logger
@@ -163,7 +259,8 @@ var ignoredFlags = map[string]struct{}{
 // A list of cobra flags that need the long form of the environment
 // variable name, because the short form can be ambiguous
 var skipShortVersionFlag = map[string]struct{}{
-	"path": {},
+	"path":   {},
+	"config": {},
 }
 
 // A set of cobra flag names to environment variable aliases
@@ -175,36 +272,37 @@ var bindingAliases = map[string][]string{
 	"title":      {envVarPrefix + "page_title"},
 }
 
-// binds the cobra command flags against the viper configuration
-func bindCobraAndViper(rootCommand *cobra.Command) error {
-	v := viper.New()
-
-	// Attempt to read settings from a config file from multiple
-	// different file types
-	v.SetConfigName(configFilePrefix)
-	v.SetConfigType("yaml")
+// configFileExtensions are the formats supported for the configuration
+// file, detected from its extension
+var configFileExtensions = []string{"yaml", "yml", "toml", "json"}
 
-	// Look for the config file in these locations
-	v.AddConfigPath(".")
+// binds the cobra command flags against the viper configuration,
+// returning it so settings without flags can be read from it, and
+// where the value of each flag comes from
+func bindCobraAndViper(rootCommand *cobra.Command) (*viper.Viper, settingSources, error) {
+	v := viper.New()
+	flags := rootCommand.Flags()
 
-	// Try to read the config file from any of the locations
-	if err := v.ReadInConfig(); err != nil {
-		// If the configuration file was not found, it's all good, we ignore
-		// the failure and proceed with the default settings
-		if f := (&viper.ConfigFileNotFoundError{}); errors.As(err, &f) {
-			return fmt.Errorf("unable to read configuration file: %w", err)
-		}
-	}
+	// Environment variables bound to each flag, in the order viper
+	// checks them, to tell which one set the value
+	envVars := make(map[string][]envVarSource)
 
 	// Anonymous function to potentially log when we bind an
 	// environment variable to a cobra flag
-	bind := func(flagName, envVar string) {
+	bind := func(flagName, envVar string, alias bool) {
+		for _, e := range envVars[flagName] {
+			if e.name == envVar {
+				return
+			}
+		}
+
 		v.BindEnv(flagName, envVar)
+		envVars[flagName] = append(envVars[flagName], envVarSource{name: envVar, alias: alias})
 	}
 
 	// Configure prefixes for environment variables and
 	// set backwards-compatible environment variables
-	rootCommand.Flags().VisitAll(func(f *pflag.Flag) {
+	flags.VisitAll(func(f *pflag.Flag) {
 		// Skip those flags that don't need to be bound
 		if _, ok := ignoredFlags[f.Name]; ok {
 			return
@@ -216,27 +314,249 @@ func bindCobraAndViper(rootCommand *cobra.Command) error {
 		// Bind the key to the new environment variable name, uppercased,
 		// and dashes replaced with underscore
 		if _, found := skipShortVersionFlag[f.Name]; !found {
-			bind(f.Name, strings.ToUpper(newName))
+			bind(f.Name, strings.ToUpper(newName), false)
 		}
 
 		// Bind the key to the new environment variable name including
 		// the prefix, uppercased, and dashes replaced with underscore
-		bind(f.Name, strings.ToUpper(envVarPrefix+newName))
+		bind(f.Name, strings.ToUpper(envVarPrefix+newName), false)
 
 		// Bind potential aliases of the environment variables to maintain
 		// backwards compatibility
 		if aliases, found := bindingAliases[f.Name]; found {
 			for _, alias := range aliases {
-				bind(f.Name, strings.ToUpper(alias))
+				bind(f.Name, strings.ToUpper(alias), true)
+			}
+		}
+	})
+
+	// The location of the configuration file, and the served path where
+	// it can live, can only come from flags or environment variables
+	preConfigValue := func(name string) string {
+		f := flags.Lookup(name)
+		if f == nil {
+			return ""
+		}
+
+		if !f.Changed && v.IsSet(name) {
+			return v.GetString(name)
+		}
+
+		return f.Value.String()
+	}
+
+	// Find the configuration file, either the one set explicitly, which
+	// must exist, or the first one found in the well-known locations
+	configFile, err := findConfigFile(preConfigValue("config"), preConfigValue("path"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+
+		// Any error here means the file exists but can't be read or
+		// parsed, since missing files were handled above
+		if err := v.ReadInConfig(); err != nil {
+			return nil, nil, fmt.Errorf("unable to read configuration file %q: %w", configFile, err)
+		}
+	}
+
+	var bindErr error
+	sources := make(settingSources)
+	flags.VisitAll(func(f *pflag.Flag) {
+		if _, ok := ignoredFlags[f.Name]; ok {
+			return
+		}
+
+		switch {
+		case f.Changed:
+			sources[f.Name] = settingSource{kind: sourceFlag}
+		case !v.IsSet(f.Name):
+			sources[f.Name] = settingSource{kind: sourceDefault}
+		default:
+			sources[f.Name] = settingSource{kind: sourceFile, name: configFile}
+			for _, e := range envVars[f.Name] {
+				if os.Getenv(e.name) != "" {
+					sources[f.Name] = settingSource{kind: sourceEnv, name: e.name, alias: e.alias}
+					break
+				}
 			}
 		}
 
-		// If the flag hasn't been changed, and the value is set in
-		// the environment, set the flag to the value from the environment
-		if !f.Changed && v.IsSet(f.Name) {
-			rootCommand.Flags().Set(f.Name, v.GetString(f.Name))
+		// If the flag hasn't been changed, and the value is set in the
+		// environment or the configuration file, set the flag to it
+		value := f.Value.String()
+		switch {
+		case !f.Changed && !v.IsSet(f.Name):
+			return
+
+		case !f.Changed:
+			// Lists from the configuration file set all the values of
+			// flags that accept multiple values at once
+			if sv, ok := f.Value.(pflag.SliceValue); ok {
+				if _, isList := v.Get(f.Name).([]any); isList {
+					sv.Replace(v.GetStringSlice(f.Name))
+					return
+				}
+			}
+
+			value = v.GetString(f.Name)
+
+		default:
+			// Flags accepting multiple values keep each of them as-is
+			if _, ok := f.Value.(pflag.SliceValue); ok {
+				return
+			}
+		}
+
+		// Credentials can point to a file or another environment variable
+		// holding the actual value, wherever they were set, while other
+		// values are kept as-is
+		if _, ok := referenceFlags[f.Name]; !ok {
+			if !f.Changed {
+				flags.Set(f.Name, value)
+			}
+			return
+		}
+
+		resolved, err := resolveReference(value)
+		if err != nil {
+			if bindErr == nil {
+				bindErr = fmt.Errorf("unable to read the value of --%s: %w", f.Name, err)
+			}
+			return
+		}
+
+		if resolved != value {
+			source := sources[f.Name]
+			source.reference = value
+			sources[f.Name] = source
+		} else if f.Changed {
+			return
 		}
+
+		flags.Set(f.Name, resolved)
 	})
 
-	return nil
+	if bindErr != nil {
+		return nil, nil, bindErr
+	}
+
+	return v, sources, nil
+}
+
+// Prefixes of values from flags, environment variables and the
+// configuration file pointing to where the actual value is, so secrets
+// can be mounted as files or kept in another environment variable
+const (
+	fileReferencePrefix = "file:"
+	envReferencePrefix  = "env:"
+)
+
+// resolveReference returns the value a "file:" or "env:" reference points
+// to, or the value as-is if it isn't a reference. Files are read without
+// their trailing newline.
+func resolveReference(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, fileReferencePrefix):
+		return server.ReadSecretFile(strings.TrimPrefix(value, fileReferencePrefix)) //nolint:wrapcheck // error already includes the file path
+
+	case strings.HasPrefix(value, envReferencePrefix):
+		name := strings.TrimPrefix(value, envReferencePrefix)
+		resolved, found := os.LookupEnv(name)
+		if !found {
+			return "", fmt.Errorf("environment variable %q is not set", name)
+		}
+		return resolved, nil
+	}
+
+	return value, nil
+}
+
+// referenceDecodeHook resolves "file:" and "env:" references in the
+// credentials of mounts and virtual hosts
+func referenceDecodeHook(from, to reflect.Type, data any) (any, error) {
+	settings, ok := data.(map[string]any)
+	if !ok || from.Kind() != reflect.Map || to.Kind() != reflect.Struct {
+		return data, nil
+	}
+
+	resolved := make(map[string]any, len(settings))
+	for key, value := range settings {
+		if s, isString := value.(string); isString {
+			if _, ok := referenceFlags[key]; ok {
+				r, err := resolveReference(s)
+				if err != nil {
+					return nil, fmt.Errorf("unable to read the value of %q: %w", key, err)
+				}
+				value = r
+			}
+		}
+		resolved[key] = value
+	}
+
+	return resolved, nil
+}
+
+// findConfigFile returns the absolute path to the configuration file. If
+// a file is set explicitly, it must exist. Otherwise, a file named after
+// the config prefix is looked for in the working directory and in the
+// served path, then a file named "config" in the "http-server" directory
+// of $XDG_CONFIG_HOME and of /etc, in that order. An empty string is
+// returned if none is found.
+func findConfigFile(explicit, servedPath string) (string, error) {
+	if explicit != "" {
+		if _, err := os.Stat(explicit); err != nil {
+			return "", fmt.Errorf("unable to read configuration file: %w", err)
+		}
+
+		return filepath.Abs(explicit) //nolint:wrapcheck // error is self-explanatory
+	}
+
+	type location struct{ dir, name string }
+	locations := []location{{".", configFilePrefix}}
+
+	if servedPath != "" && filepath.Clean(servedPath) != "." {
+		locations = append(locations, location{servedPath, configFilePrefix})
+	}
+
+	// Follow the XDG base directory specification, which ignores
+	// relative paths and defaults to ~/.config
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(configHome) {
+		configHome = ""
+		if home, err := os.UserHomeDir(); err == nil {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+
+	if configHome != "" {
+		locations = append(locations, location{filepath.Join(configHome, "http-server"), "config"})
+	}
+
+	locations = append(locations, location{filepath.Join("/etc", "http-server"), "config"})
+
+	for _, loc := range locations {
+		for _, ext := range configFileExtensions {
+			file := filepath.Join(loc.dir, loc.name+"."+ext)
+
+			fi, err := os.Stat(file)
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+
+				return "", fmt.Errorf("unable to read configuration file: %w", err)
+			}
+
+			if fi.IsDir() {
+				continue
+			}
+
+			return filepath.Abs(file) //nolint:wrapcheck // error is self-explanatory
+		}
+	}
+
+	return "", nil
 }
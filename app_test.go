@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReloadSettingsReferences(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HTTP_SERVER_TEST_PASSWORD", "s3cr3t")
+	t.Setenv("HTTP_SERVER_TEST_JWT_KEY", "signing-key")
+
+	configFile := filepath.Join(dir, "config.yaml")
+	config := `mounts:
+  - prefix: /private/
+    path: ` + dir + `
+    title: "env: private"
+    jwt-key: env:HTTP_SERVER_TEST_JWT_KEY
+`
+	if err := os.WriteFile(configFile, []byte(config), 0o600); err != nil {
+		t.Fatalf("unable to write configuration file: %v", err)
+	}
+
+	srv, err := reloadSettings([]string{
+		"--config", configFile,
+		"--path", dir,
+		"--title", "env: staging",
+		"--banner", "file: notes",
+		"--username", "admin",
+		"--password", "env:HTTP_SERVER_TEST_PASSWORD",
+	})
+	if err != nil {
+		t.Fatalf("unable to load settings: %v", err)
+	}
+
+	if len(srv.Mounts) != 1 {
+		t.Fatalf("expected one mount, got %d", len(srv.Mounts))
+	}
+
+	// Only credentials are resolved, other values are kept as-is
+	tests := []struct {
+		name   string
+		got    string
+		expect string
+	}{
+		{name: "title", got: srv.PageTitle, expect: "env: staging"},
+		{name: "banner", got: srv.BannerMarkdown, expect: "file: notes"},
+		{name: "password", got: srv.Password, expect: "s3cr3t"},
+		{name: "mount title", got: srv.Mounts[0].PageTitle, expect: "env: private"},
+		{name: "mount jwt-key", got: srv.Mounts[0].JWTSigningKey, expect: "signing-key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.expect {
+				t.Fatalf("expected %q, got %q", tt.expect, tt.got)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/patrickdappollonio/http-server/internal/server"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// redactedValue replaces the value of secrets when printing the settings
+const redactedValue = "REDACTED"
+
+// secretFlags are the flags whose values are never printed
+var secretFlags = map[string]struct{}{
+	"password": {},
+	"jwt-key":  {},
+}
+
+// referenceFlags are the flags, and the matching settings of mounts and
+// virtual hosts, whose values can be "file:" or "env:" references
+var referenceFlags = map[string]struct{}{
+	"username": {},
+	"password": {},
+	"jwt-key":  {},
+}
+
+// Kinds of places the value of a setting can come from
+const (
+	sourceDefault = "default"
+	sourceFlag    = "flag"
+	sourceEnv     = "env"
+	sourceFile    = "file"
+)
+
+// envVarSource is an environment variable bound to a flag
+type envVarSource struct {
+	name  string
+	alias bool
+}
+
+// settingSource describes where the value of a setting comes from: its
+// kind, the environment variable or configuration file that set it, and
+// the "file:" or "env:" reference the value was read from, if any
+type settingSource struct {
+	kind      string
+	name      string
+	alias     bool
+	reference string
+}
+
+// String renders the source of the setting for humans
+func (s settingSource) String() string {
+	var source string
+	switch {
+	case s.kind == sourceEnv && s.alias:
+		source = fmt.Sprintf("env %s (alias)", s.name)
+	case s.name != "":
+		source = fmt.Sprintf("%s %s", s.kind, s.name)
+	default:
+		source = s.kind
+	}
+
+	if s.reference != "" {
+		source += ", read from " + s.reference
+	}
+
+	return source
+}
+
+// settingSources maps the name of each flag to where its value comes from
+type settingSources map[string]settingSource
+
+// newConfigCommand creates the "config" command and its subcommands, used
+// to inspect, check and create the settings of the server
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Print, validate and generate the server settings",
+
+		// Subcommands read the settings from their own flags
+		PersistentPreRunE: func(*cobra.Command, []string) error { return nil },
+	}
+
+	// Subcommands print straight to stdout, skipping the logger
+	cmd.SetOut(os.Stdout)
+
+	cmd.AddCommand(
+		newConfigPrintCommand(),
+		newConfigValidateCommand(),
+		newConfigInitCommand(),
+	)
+
+	return cmd
+}
+
+// newConfigPrintCommand creates the "config print" command, which prints
+// the value of every setting and where it comes from
+func newConfigPrintCommand() *cobra.Command {
+	srv := &server.Server{ConfigFilePrefix: configFilePrefix}
+
+	cmd := &cobra.Command{
+		Use:   "print",
+		Short: "Print the effective settings and where each of them comes from",
+		Long:  "Print the effective settings, merged from flags, environment variables and the configuration file,\nand where each of them comes from. The flags are the same as those used to start the server.\nSecrets are redacted.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			sources, err := loadSettings(cmd, srv)
+			if err != nil {
+				return err
+			}
+
+			return printSettings(cmd.OutOrStdout(), cmd.Flags(), sources, srv)
+		},
+	}
+
+	defineFlags(cmd.Flags(), srv)
+	return cmd
+}
+
+// newConfigValidateCommand creates the "config validate" command, which
+// checks the settings without starting the server
+func newConfigValidateCommand() *cobra.Command {
+	srv := &server.Server{ConfigFilePrefix: configFilePrefix}
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the settings without starting the server",
+		Long:  "Validate the settings, merged from flags, environment variables and the configuration file,\nrunning the same checks as when starting the server, without starting it.\nThe flags are the same as those used to start the server.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if _, err := loadSettings(cmd, srv); err != nil {
+				return err
+			}
+
+			srv.LogOutput = cmd.OutOrStdout()
+
+			if err := srv.Validate(); err != nil {
+				return fmt.Errorf("unable to validate configuration: %w", err)
+			}
+
+			if err := srv.LoadRedirectionsIfEnabled(); err != nil {
+				return fmt.Errorf("unable to load redirections file: %w", err)
+			}
+
+			if srv.ConfigFile == "" {
+				fmt.Fprintln(cmd.OutOrStdout(), "Configuration is valid, no configuration file found")
+				return nil
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Configuration is valid, using configuration file %q\n", srv.ConfigFile)
+			return nil
+		},
+	}
+
+	defineFlags(cmd.Flags(), srv)
+	return cmd
+}
+
+// newConfigInitCommand creates the "config init" command, which generates
+// a configuration file with every setting and its default value
+func newConfigInitCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Generate a YAML configuration file documenting every setting",
+		Long:  "Generate a YAML configuration file documenting every setting, commented out with its default value.\nAn existing file is never overwritten.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			flags := pflag.NewFlagSet("http-server", pflag.ContinueOnError)
+			defineFlags(flags, &server.Server{})
+
+			if output == "" || output == "-" {
+				return writeConfigTemplate(cmd.OutOrStdout(), flags)
+			}
+
+			f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+			if err != nil {
+				if errors.Is(err, fs.ErrExist) {
+					return fmt.Errorf("configuration file %q already exists: remove it first to generate a new one", output)
+				}
+				return fmt.Errorf("unable to create configuration file: %w", err)
+			}
+
+			if err := writeConfigTemplate(f, flags); err != nil {
+				f.Close()
+				return err
+			}
+
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("unable to write configuration file: %w", err)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Configuration file written to %q\n", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write the configuration to (defaults to stdout)")
+	return cmd
+}
+
+// printSettings prints the configuration file used, and the value of every
+// flag with its source, followed by the settings only available in the
+// configuration file
+func printSettings(w io.Writer, flags *pflag.FlagSet, sources settingSources, srv *server.Server) error {
+	if srv.ConfigFile != "" {
+		fmt.Fprintf(w, "Configuration file: %s\n\n", srv.ConfigFile)
+	} else {
+		fmt.Fprintf(w, "Configuration file: none found\n\n")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+
+	flags.VisitAll(func(f *pflag.Flag) {
+		// The configuration file used is already printed above
+		source, found := sources[f.Name]
+		if !found || f.Name == "config" {
+			return
+		}
+
+		value := f.Value.String()
+		if _, secret := secretFlags[f.Name]; secret && value != "" {
+			value = redactedValue
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Name, value, source)
+	})
+
+	// Mounts and virtual hosts can hold secrets of their own, so only
+	// how many are configured is printed
+	if n := len(srv.Mounts); n > 0 {
+		fmt.Fprintf(tw, "mounts\t%d configured\t%s\n", n, settingSource{kind: sourceFile, name: srv.ConfigFile})
+	}
+
+	if n := len(srv.VirtualHosts); n > 0 {
+		fmt.Fprintf(tw, "vhosts\t%d configured\t%s\n", n, settingSource{kind: sourceFile, name: srv.ConfigFile})
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("unable to print settings: %w", err)
+	}
+
+	return nil
+}
+
+// writeConfigTemplate writes a YAML configuration file with every flag
+// commented out, set to its default value, and documented with its usage
+// and environment variables
+func writeConfigTemplate(w io.Writer, flags *pflag.FlagSet) error {
+	var b strings.Builder
+
+	b.WriteString("# Configuration file for http-server, generated with \"http-server config init\".\n")
+	b.WriteString("# Uncomment and change the settings you need: the values shown are the defaults.\n")
+	b.WriteString("# Flags and environment variables take precedence over this file.\n")
+
+	flags.VisitAll(func(f *pflag.Flag) {
+		if _, ok := ignoredFlags[f.Name]; ok || f.Name == "config" {
+			return
+		}
+
+		fmt.Fprintf(&b, "\n# %s\n", f.Usage)
+		fmt.Fprintf(&b, "# Environment variables: %s\n", strings.Join(flagEnvVars(f.Name), ", "))
+		fmt.Fprintf(&b, "# %s: %s\n", f.Name, yamlDefault(f))
+	})
+
+	b.WriteString("\n# Directories served under their own path prefix, see docs/mounts.md\n")
+	b.WriteString("# mounts: []\n")
+	b.WriteString("\n# Directories served for their own domains, see docs/virtual-hosts.md\n")
+	b.WriteString("# vhosts: []\n")
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("unable to write configuration file: %w", err)
+	}
+
+	return nil
+}
+
+// flagEnvVars returns the environment variables bound to a flag, in the
+// same order bindCobraAndViper binds them
+func flagEnvVars(name string) []string {
+	newName := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+
+	var names []string
+	if _, found := skipShortVersionFlag[name]; !found {
+		names = append(names, newName)
+	}
+
+	names = append(names, strings.ToUpper(envVarPrefix)+newName)
+
+	for _, alias := range bindingAliases[name] {
+		if alias := strings.ToUpper(alias); !strings.EqualFold(alias, envVarPrefix+newName) {
+			names = append(names, alias)
+		}
+	}
+
+	return names
+}
+
+// yamlDefault renders the default value of a flag as a YAML value
+func yamlDefault(f *pflag.Flag) string {
+	switch f.Value.Type() {
+	case "bool", "int":
+		return f.DefValue
+	case "stringSlice", "stringArray":
+		return "[]"
+	default:
+		return strconv.Quote(f.DefValue)
+	}
+}
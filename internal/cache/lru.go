@@ -0,0 +1,205 @@
+// Package cache implements a bounded, least-recently-used cache for
+// content derived from files, which is invalidated when files change.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Key identifies a file at a point in time. An entry cached for a file
+// is only returned while its modification time and size stay the same.
+type Key struct {
+	Kind    string // What's cached about the file, like its content
+	Path    string
+	ModTime time.Time
+	Size    int64
+}
+
+// Stats are counters describing how the cache is being used.
+type Stats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Entries       int
+	Size          int64
+	MaxSize       int64
+}
+
+// entry is an element of the cache, stored in the recency list.
+type entry[V any] struct {
+	key   Key
+	value V
+	size  int64
+}
+
+// identity is the part of the key an entry is stored under, so a newer
+// version of a file replaces the older one.
+type identity struct {
+	kind string
+	path string
+}
+
+// LRU is a cache holding up to a maximum size, in bytes, of values,
+// evicting the least recently used ones first. A nil *LRU is a valid,
+// disabled cache, where nothing is ever stored.
+type LRU[V any] struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	order   *list.List
+	items   map[identity]*list.Element
+
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	evictions     atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+// New creates a cache holding up to maxSize bytes. A nil cache is
+// returned if maxSize is zero or negative.
+func New[V any](maxSize int64) *LRU[V] {
+	if maxSize <= 0 {
+		return nil
+	}
+
+	return &LRU[V]{
+		maxSize: maxSize,
+		order:   list.New(),
+		items:   make(map[identity]*list.Element),
+	}
+}
+
+// MaxEntrySize is the size of the biggest value the cache accepts, so
+// a single big file can't evict everything else.
+func (c *LRU[V]) MaxEntrySize() int64 {
+	if c == nil {
+		return 0
+	}
+
+	return c.maxSize / 8
+}
+
+// Get returns the value cached for the key. Values cached for an older
+// version of the file, with a different modification time or size, are
+// removed from the cache.
+func (c *LRU[V]) Get(key Key) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, found := c.items[identity{key.Kind, key.Path}]
+	if !found {
+		c.misses.Add(1)
+		return zero, false
+	}
+
+	e := el.Value.(*entry[V])
+	if !e.key.ModTime.Equal(key.ModTime) || e.key.Size != key.Size {
+		c.removeElement(el)
+		c.invalidations.Add(1)
+		c.misses.Add(1)
+		return zero, false
+	}
+
+	c.order.MoveToFront(el)
+	c.hits.Add(1)
+	return e.value, true
+}
+
+// Add stores the value for the key, with the given size in bytes,
+// evicting the least recently used values if needed. Values bigger than
+// MaxEntrySize are not stored.
+func (c *LRU[V]) Add(key Key, value V, size int64) {
+	if c == nil || size > c.MaxEntrySize() {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := identity{key.Kind, key.Path}
+	if el, found := c.items[id]; found {
+		c.removeElement(el)
+	}
+
+	c.items[id] = c.order.PushFront(&entry[V]{key: key, value: value, size: size})
+	c.size += size
+
+	for c.size > c.maxSize {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+
+		c.removeElement(oldest)
+		c.evictions.Add(1)
+	}
+}
+
+// Remove drops any value cached for the path, of any kind.
+func (c *LRU[V]) Remove(path string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, el := range c.items {
+		if id.path == path {
+			c.removeElement(el)
+			c.invalidations.Add(1)
+		}
+	}
+}
+
+// Purge drops every value from the cache, keeping the counters.
+func (c *LRU[V]) Purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	clear(c.items)
+	c.size = 0
+}
+
+// Stats returns the current counters of the cache.
+func (c *LRU[V]) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+
+	c.mu.Lock()
+	entries, size := len(c.items), c.size
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Evictions:     c.evictions.Load(),
+		Invalidations: c.invalidations.Load(),
+		Entries:       entries,
+		Size:          size,
+		MaxSize:       c.maxSize,
+	}
+}
+
+// removeElement removes an element from the cache. The caller must hold
+// the lock.
+func (c *LRU[V]) removeElement(el *list.Element) {
+	e := c.order.Remove(el).(*entry[V])
+	delete(c.items, identity{e.key.Kind, e.key.Path})
+	c.size -= e.size
+}
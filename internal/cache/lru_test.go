@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testModTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func key(path string) Key {
+	return Key{Kind: "file", Path: path, ModTime: testModTime, Size: 10}
+}
+
+func TestLRU(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxSize     int64
+		run         func(c *LRU[string])
+		wantPresent []string
+		wantMissing []string
+		wantStats   Stats
+	}{
+		{
+			name:    "get after add",
+			maxSize: 800,
+			run: func(c *LRU[string]) {
+				c.Add(key("/a"), "a", 10)
+			},
+			wantPresent: []string{"/a"},
+			wantStats:   Stats{Hits: 1, Entries: 1, Size: 10, MaxSize: 800},
+		},
+		{
+			name:    "least recently used is evicted",
+			maxSize: 200,
+			run: func(c *LRU[string]) {
+				c.Add(key("/a"), "a", 20)
+				c.Add(key("/b"), "b", 20)
+				c.Add(key("/c"), "c", 20)
+				c.Get(key("/a"))
+				for i := range 7 {
+					c.Add(key(fmt.Sprintf("/filler/%d", i)), "filler", 25)
+				}
+			},
+			wantPresent: []string{"/a"},
+			wantMissing: []string{"/b", "/c"},
+		},
+		{
+			name:    "entries bigger than the maximum entry size are skipped",
+			maxSize: 800,
+			run: func(c *LRU[string]) {
+				c.Add(key("/big"), "big", 101)
+			},
+			wantMissing: []string{"/big"},
+		},
+		{
+			name:    "file changes invalidate entries",
+			maxSize: 800,
+			run: func(c *LRU[string]) {
+				c.Add(key("/a"), "a", 10)
+
+				changed := key("/a")
+				changed.ModTime = changed.ModTime.Add(time.Second)
+				if _, found := c.Get(changed); found {
+					panic("expected entry for a changed file to be invalidated")
+				}
+			},
+			wantMissing: []string{"/a"},
+		},
+		{
+			name:    "kinds are cached separately",
+			maxSize: 800,
+			run: func(c *LRU[string]) {
+				c.Add(key("/a"), "a", 10)
+				c.Add(Key{Kind: "markdown", Path: "/a", ModTime: testModTime, Size: 10}, "<p>a</p>", 10)
+			},
+			wantPresent: []string{"/a"},
+			wantStats:   Stats{Hits: 1, Entries: 2, Size: 20, MaxSize: 800},
+		},
+		{
+			name:    "remove drops every kind",
+			maxSize: 800,
+			run: func(c *LRU[string]) {
+				c.Add(key("/a"), "a", 10)
+				c.Add(Key{Kind: "markdown", Path: "/a", ModTime: testModTime, Size: 10}, "<p>a</p>", 10)
+				c.Remove("/a")
+			},
+			wantMissing: []string{"/a"},
+			wantStats:   Stats{Misses: 1, Invalidations: 2, MaxSize: 800},
+		},
+		{
+			name:    "replacing an entry keeps the size accurate",
+			maxSize: 800,
+			run: func(c *LRU[string]) {
+				c.Add(key("/a"), "a", 10)
+				c.Add(key("/a"), "a", 20)
+			},
+			wantPresent: []string{"/a"},
+			wantStats:   Stats{Hits: 1, Entries: 1, Size: 20, MaxSize: 800},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New[string](tt.maxSize)
+			tt.run(c)
+
+			for _, p := range tt.wantPresent {
+				if _, found := c.Get(key(p)); !found {
+					t.Errorf("expected %q to be cached", p)
+				}
+			}
+
+			for _, p := range tt.wantMissing {
+				if _, found := c.Get(key(p)); found {
+					t.Errorf("expected %q not to be cached", p)
+				}
+			}
+
+			if tt.wantStats != (Stats{}) {
+				if got := c.Stats(); got != tt.wantStats {
+					t.Errorf("unexpected stats:\ngot:  %+v\nwant: %+v", got, tt.wantStats)
+				}
+			}
+
+			if got := c.Stats(); got.Size > tt.maxSize {
+				t.Errorf("cache size %d exceeds maximum %d", got.Size, tt.maxSize)
+			}
+		})
+	}
+}
+
+func TestNilLRU(t *testing.T) {
+	c := New[string](0)
+	if c != nil {
+		t.Fatalf("expected a nil cache for a zero size")
+	}
+
+	c.Add(key("/a"), "a", 1)
+	if _, found := c.Get(key("/a")); found {
+		t.Errorf("expected a disabled cache to never return values")
+	}
+
+	c.Remove("/a")
+	c.Purge()
+
+	if got := c.Stats(); got != (Stats{}) {
+		t.Errorf("expected empty stats, got %+v", got)
+	}
+}
+
+func TestLRUConcurrentAccess(t *testing.T) {
+	c := New[int](1024)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 500 {
+				k := key(fmt.Sprintf("/%d", (i+j)%50))
+				if _, found := c.Get(k); !found {
+					c.Add(k, j, 16)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := c.Stats()
+	if stats.Hits+stats.Misses != 8*500 {
+		t.Errorf("expected %d lookups, got %d", 8*500, stats.Hits+stats.Misses)
+	}
+
+	if stats.Size > 1024 {
+		t.Errorf("cache size %d exceeds maximum", stats.Size)
+	}
+}
@@ -0,0 +1,331 @@
+// Package cachecontrol generates "Cache-Control" headers for responses
+// based on rules matching their path or content type.
+package cachecontrol
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PresetFingerprinted is a rule matching fingerprinted filenames, like
+// "app.3f9a1c2e.js", whose content never changes for the same name.
+const PresetFingerprinted = "fingerprinted"
+
+// fingerprintedDirectives are the directives applied to fingerprinted
+// files when the preset is used without directives.
+const fingerprintedDirectives = "max-age=1y, immutable"
+
+// Directives are the caching options of a rule.
+type Directives struct {
+	MaxAge               time.Duration
+	StaleWhileRevalidate time.Duration
+	Immutable            bool
+	NoStore              bool
+	NoCache              bool
+}
+
+// String renders the directives as the value of a "Cache-Control" header.
+func (d Directives) String() string {
+	if d.NoStore {
+		return "no-store"
+	}
+
+	var parts []string
+	if d.NoCache {
+		parts = append(parts, "no-cache")
+	}
+
+	parts = append(parts, "max-age="+strconv.FormatInt(int64(d.MaxAge/time.Second), 10))
+
+	if d.StaleWhileRevalidate > 0 {
+		parts = append(parts, "stale-while-revalidate="+strconv.FormatInt(int64(d.StaleWhileRevalidate/time.Second), 10))
+	}
+
+	if d.Immutable {
+		parts = append(parts, "immutable")
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Rule applies caching directives to responses whose path or content
+// type match its pattern.
+type Rule struct {
+	Pattern    string
+	Directives Directives
+	header     string
+}
+
+// matches checks if the rule applies to a response for the given path,
+// relative to the root of the server, and content type.
+func (r *Rule) matches(requestPath, contentType string) bool {
+	switch {
+	case r.Pattern == PresetFingerprinted:
+		return isFingerprinted(path.Base(requestPath))
+
+	case strings.HasPrefix(r.Pattern, "/"):
+		// Path patterns ending in "/**" match everything below them
+		if prefix, found := strings.CutSuffix(r.Pattern, "/**"); found {
+			return requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+		}
+
+		matched, _ := path.Match(r.Pattern, requestPath)
+		return matched
+
+	case strings.Contains(r.Pattern, "/"):
+		mediaType, _, _ := strings.Cut(contentType, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+		if prefix, found := strings.CutSuffix(r.Pattern, "/*"); found {
+			return strings.HasPrefix(mediaType, prefix+"/")
+		}
+
+		return mediaType == r.Pattern
+
+	default:
+		matched, _ := path.Match(r.Pattern, path.Base(requestPath))
+		return matched
+	}
+}
+
+// isFingerprinted checks if the filename has a hash before its extension,
+// separated by dots, like "app.3f9a1c2e.js". Hashes are 8 or more
+// hexadecimal characters, and must include both a letter and a digit, so
+// dates like "app-20240101.js" or words aren't mistaken for hashes.
+func isFingerprinted(name string) bool {
+	ext := path.Ext(name)
+	if ext == "" {
+		return false
+	}
+
+	segments := strings.Split(strings.TrimSuffix(name, ext), ".")
+
+	// The first segment is the name of the file, not a hash
+	for i := 1; i < len(segments); i++ {
+		if isHash(segments[i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isHash checks if the segment of a filename looks like a hash.
+func isHash(s string) bool {
+	if len(s) < 8 {
+		return false
+	}
+
+	hasDigit, hasLetter := false, false
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			hasDigit = true
+		case (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'):
+			hasLetter = true
+		default:
+			return false
+		}
+	}
+
+	return hasDigit && hasLetter
+}
+
+// Policy is a list of rules, where the first rule matching a response
+// sets its "Cache-Control" header.
+type Policy struct {
+	Rules []Rule
+}
+
+// Header returns the value of the "Cache-Control" header for a response
+// with the given path, relative to the root of the server, and content
+// type. An empty string is returned if no rule matches.
+func (p *Policy) Header(requestPath, contentType string) string {
+	if p == nil {
+		return ""
+	}
+
+	for i := range p.Rules {
+		if p.Rules[i].matches(requestPath, contentType) {
+			return p.Rules[i].header
+		}
+	}
+
+	return ""
+}
+
+// Parse parses caching rules, each of them written as a pattern followed
+// by a space and a comma-separated list of directives, like
+// "*.html no-cache" or "/assets/** max-age=1y, immutable". Patterns can be:
+//
+//   - Paths starting with "/", which can include wildcards, and match
+//     everything below them when ending in "/**".
+//   - Content types, like "text/html" or "image/*".
+//   - Filenames, which can include wildcards, like "*.css".
+//   - The "fingerprinted" preset, matching filenames with a hash like
+//     "app.3f9a1c2e.js", which defaults to "max-age=1y, immutable".
+//
+// No rules return an empty policy, which never sets the header.
+func Parse(rules []string) (*Policy, error) {
+	policy := &Policy{Rules: make([]Rule, 0, len(rules))}
+
+	for _, line := range rules {
+		rule, err := parseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache control rule %q: %w", line, err)
+		}
+
+		policy.Rules = append(policy.Rules, rule)
+	}
+
+	return policy, nil
+}
+
+// parseRule parses a single rule.
+func parseRule(line string) (Rule, error) {
+	pattern, directives, _ := strings.Cut(strings.TrimSpace(line), " ")
+	directives = strings.TrimSpace(directives)
+
+	if pattern == "" {
+		return Rule{}, errors.New("missing pattern")
+	}
+
+	if directives == "" {
+		if pattern != PresetFingerprinted {
+			return Rule{}, errors.New("missing directives after the pattern")
+		}
+
+		directives = fingerprintedDirectives
+	}
+
+	if err := validatePattern(pattern); err != nil {
+		return Rule{}, err
+	}
+
+	d, err := parseDirectives(directives)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	return Rule{Pattern: pattern, Directives: d, header: d.String()}, nil
+}
+
+// validatePattern checks that the wildcards in a pattern are valid.
+func validatePattern(pattern string) error {
+	if pattern == PresetFingerprinted {
+		return nil
+	}
+
+	glob := strings.TrimSuffix(pattern, "/**")
+	if _, err := path.Match(glob, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	if strings.Contains(glob, "**") {
+		return fmt.Errorf("invalid pattern %q: \"**\" is only supported at the end of a path", pattern)
+	}
+
+	return nil
+}
+
+// parseDirectives parses a comma-separated list of directives.
+func parseDirectives(s string) (Directives, error) {
+	var d Directives
+	var hasMaxAge bool
+
+	for _, part := range strings.Split(s, ",") {
+		name, value, hasValue := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+
+		switch name {
+		case "max-age", "stale-while-revalidate":
+			if !hasValue {
+				return d, fmt.Errorf("directive %q requires a duration, like %s=1h", name, name)
+			}
+
+			duration, err := parseDuration(value)
+			if err != nil {
+				return d, fmt.Errorf("invalid duration for %q: %w", name, err)
+			}
+
+			if name == "max-age" {
+				d.MaxAge, hasMaxAge = duration, true
+			} else {
+				d.StaleWhileRevalidate = duration
+			}
+
+		case "immutable", "no-store", "no-cache":
+			if hasValue {
+				return d, fmt.Errorf("directive %q doesn't take a value", name)
+			}
+
+			switch name {
+			case "immutable":
+				d.Immutable = true
+			case "no-store":
+				d.NoStore = true
+			case "no-cache":
+				d.NoCache = true
+			}
+
+		case "":
+			return d, errors.New("empty directive")
+
+		default:
+			return d, fmt.Errorf("unsupported directive %q: must be one of max-age, stale-while-revalidate, immutable, no-store or no-cache", name)
+		}
+	}
+
+	if d.NoStore && (hasMaxAge || d.Immutable || d.NoCache || d.StaleWhileRevalidate > 0) {
+		return d, errors.New("\"no-store\" can't be combined with other directives")
+	}
+
+	if d.Immutable && !hasMaxAge {
+		return d, errors.New("\"immutable\" requires a \"max-age\"")
+	}
+
+	return d, nil
+}
+
+// parseDuration parses a duration given in seconds, like "3600", as a Go
+// duration, like "1h30m", or in days, weeks or years, like "7d", "2w" or
+// "1y".
+func parseDuration(s string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, errors.New("duration can't be negative")
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	units := map[byte]time.Duration{
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'y': 365 * 24 * time.Hour,
+	}
+
+	if len(s) > 1 {
+		if unit, found := units[s[len(s)-1]]; found {
+			n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+			if err == nil && n >= 0 {
+				return time.Duration(n) * unit, nil
+			}
+		}
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number of seconds or a duration like \"1h\", \"7d\" or \"1y\"", s)
+	}
+
+	if duration < 0 {
+		return 0, errors.New("duration can't be negative")
+	}
+
+	return duration.Truncate(time.Second), nil
+}
@@ -0,0 +1,138 @@
+package cachecontrol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       string
+		wantHeader string
+		wantErr    bool
+	}{
+		{name: "max age in seconds", rule: "*.css max-age=3600", wantHeader: "max-age=3600"},
+		{name: "max age as duration", rule: "*.css max-age=1h30m", wantHeader: "max-age=5400"},
+		{name: "max age in days", rule: "*.css max-age=7d", wantHeader: "max-age=604800"},
+		{name: "immutable", rule: "/assets/** max-age=1y, immutable", wantHeader: "max-age=31536000, immutable"},
+		{name: "stale while revalidate", rule: "text/html max-age=60, stale-while-revalidate=1h", wantHeader: "max-age=60, stale-while-revalidate=3600"},
+		{name: "no store", rule: "/private/** no-store", wantHeader: "no-store"},
+		{name: "no cache", rule: "*.html no-cache", wantHeader: "no-cache, max-age=0"},
+		{name: "fingerprinted preset", rule: "fingerprinted", wantHeader: "max-age=31536000, immutable"},
+		{name: "fingerprinted preset with directives", rule: "fingerprinted max-age=30d", wantHeader: "max-age=2592000"},
+		{name: "extra spaces", rule: "  *.js   max-age=60 ,immutable ", wantHeader: "max-age=60, immutable"},
+		{name: "missing directives", rule: "*.css", wantErr: true},
+		{name: "unknown directive", rule: "*.css public", wantErr: true},
+		{name: "missing duration", rule: "*.css max-age", wantErr: true},
+		{name: "invalid duration", rule: "*.css max-age=soon", wantErr: true},
+		{name: "negative duration", rule: "*.css max-age=-1", wantErr: true},
+		{name: "no store with other directives", rule: "*.css no-store, max-age=60", wantErr: true},
+		{name: "immutable without max age", rule: "*.css immutable", wantErr: true},
+		{name: "value on a flag directive", rule: "*.css immutable=yes", wantErr: true},
+		{name: "invalid glob", rule: "[.css max-age=60", wantErr: true},
+		{name: "double star in the middle", rule: "/a/**/b max-age=60", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := Parse([]string{tt.rule})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got := policy.Rules[0].header; got != tt.wantHeader {
+				t.Errorf("expected header %q, got %q", tt.wantHeader, got)
+			}
+		})
+	}
+}
+
+func TestPolicyHeader(t *testing.T) {
+	policy, err := Parse([]string{
+		"fingerprinted",
+		"/private/** no-store",
+		"/docs/*.pdf max-age=1d",
+		"*.html no-cache",
+		"image/* max-age=1w",
+		"text/css max-age=1h",
+	})
+	if err != nil {
+		t.Fatalf("unable to parse policy: %v", err)
+	}
+
+	tests := []struct {
+		path        string
+		contentType string
+		want        string
+	}{
+		{path: "/app.3f9a1c2e.js", contentType: "text/javascript", want: "max-age=31536000, immutable"},
+		{path: "/assets/main.D41D8CD9.css", contentType: "text/css", want: "max-age=31536000, immutable"},
+		{path: "/assets/vendor.min.0a1b2c3d4e5f.js", contentType: "text/javascript", want: "max-age=31536000, immutable"},
+		{path: "/app.3f9a1c.js", contentType: "text/javascript", want: ""},
+		{path: "/app-20240101.js", contentType: "text/javascript", want: ""},
+		{path: "/app.20240101.js", contentType: "text/javascript", want: ""},
+		{path: "/app.deadbeef.js", contentType: "text/javascript", want: ""},
+		{path: "/app-3f9a1c2e.js", contentType: "text/javascript", want: ""},
+		{path: "/assets/chunk.7HQ2VX4M.js", contentType: "text/javascript", want: ""},
+		{path: "/3f9a1c2e.js", contentType: "text/javascript", want: ""},
+		{path: "/react-dom.production.min.js", contentType: "text/javascript", want: ""},
+		{path: "/images/facade.png", contentType: "image/png", want: "max-age=604800"},
+		{path: "/private/report.txt", contentType: "text/plain", want: "no-store"},
+		{path: "/private/nested/deep.txt", contentType: "text/plain", want: "no-store"},
+		{path: "/private", contentType: "text/html", want: "no-store"},
+		{path: "/privateer.txt", contentType: "text/plain", want: ""},
+		{path: "/docs/manual.pdf", contentType: "application/pdf", want: "max-age=86400"},
+		{path: "/docs/nested/manual.pdf", contentType: "application/pdf", want: ""},
+		{path: "/about/index.html", contentType: "text/html; charset=utf-8", want: "no-cache, max-age=0"},
+		{path: "/style.css", contentType: "text/css; charset=utf-8", want: "max-age=3600"},
+		{path: "/Style.CSS", contentType: "TEXT/CSS", want: "max-age=3600"},
+		{path: "/notes.txt", contentType: "text/plain", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := policy.Header(tt.path, tt.contentType); got != tt.want {
+				t.Errorf("Header(%q, %q) = %q, want %q", tt.path, tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmptyPolicy(t *testing.T) {
+	policy, err := Parse(nil)
+	if err != nil || policy == nil || len(policy.Rules) != 0 {
+		t.Fatalf("expected an empty policy and no error, got %v and %v", policy, err)
+	}
+
+	if got := policy.Header("/index.html", "text/html"); got != "" {
+		t.Errorf("expected no header, got %q", got)
+	}
+}
+
+func TestNilPolicy(t *testing.T) {
+	var policy *Policy
+	if got := policy.Header("/index.html", "text/html"); got != "" {
+		t.Errorf("expected no header, got %q", got)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"0":    0,
+		"90":   90 * time.Second,
+		"2w":   14 * 24 * time.Hour,
+		"1y":   365 * 24 * time.Hour,
+		"1.5s": time.Second,
+	}
+
+	for input, want := range tests {
+		if got, err := parseDuration(input); err != nil || got != want {
+			t.Errorf("parseDuration(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+}
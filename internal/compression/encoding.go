@@ -0,0 +1,118 @@
+// Package compression negotiates and applies content encodings to
+// responses, such as gzip, brotli and zstd.
+package compression
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Content encodings supported by the server, as used in the
+// "Accept-Encoding" and "Content-Encoding" headers.
+const (
+	Brotli = "br"
+	Zstd   = "zstd"
+	Gzip   = "gzip"
+)
+
+// extensions maps each encoding to the file extension used by
+// precompressed files.
+var extensions = map[string]string{
+	Brotli: ".br",
+	Zstd:   ".zst",
+	Gzip:   ".gz",
+}
+
+// Extension returns the file extension of precompressed files with
+// the given encoding, like ".br" for brotli.
+func Extension(encoding string) string {
+	return extensions[encoding]
+}
+
+// IsPrecompressedExtension checks if the extension, including its
+// leading dot, belongs to a precompressed file.
+func IsPrecompressedExtension(ext string) bool {
+	ext = strings.ToLower(ext)
+	for _, e := range extensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// AddVary adds a request header to the "Vary" header, unless it's already
+// listed, since several handlers can depend on the same request header.
+func AddVary(h http.Header, name string) {
+	for _, line := range h.Values("Vary") {
+		for _, value := range strings.Split(line, ",") {
+			if value = strings.TrimSpace(value); value == "*" || strings.EqualFold(value, name) {
+				return
+			}
+		}
+	}
+
+	h.Add("Vary", name)
+}
+
+// Negotiate picks the encoding, among the supported ones, that the client
+// prefers according to the "Accept-Encoding" header. Ties are broken using
+// the order of the supported encodings. An empty string is returned if the
+// client doesn't accept any of them.
+func Negotiate(acceptEncoding string, supported ...string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	weights := make(map[string]float64)
+	wildcard := -1.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found || !strings.EqualFold(strings.TrimSpace(key), "q") {
+				continue
+			}
+
+			v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				v = 0
+			}
+			q = v
+		}
+
+		// "x-gzip" is an alias of "gzip"
+		if name == "x-gzip" {
+			name = Gzip
+		}
+
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+
+		weights[name] = q
+	}
+
+	best, bestWeight := "", 0.0
+	for _, encoding := range supported {
+		weight, found := weights[encoding]
+		if !found {
+			weight = wildcard
+		}
+
+		if weight > bestWeight {
+			best, bestWeight = encoding, weight
+		}
+	}
+
+	return best
+}
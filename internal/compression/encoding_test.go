@@ -0,0 +1,105 @@
+package compression
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		supported      []string
+		want           string
+	}{
+		{
+			name:      "no header",
+			supported: []string{Brotli, Zstd, Gzip},
+			want:      "",
+		},
+		{
+			name:           "server preference breaks ties",
+			acceptEncoding: "gzip, deflate, br, zstd",
+			supported:      []string{Brotli, Zstd, Gzip},
+			want:           Brotli,
+		},
+		{
+			name:           "client preference wins",
+			acceptEncoding: "br;q=0.5, gzip;q=0.9",
+			supported:      []string{Brotli, Zstd, Gzip},
+			want:           Gzip,
+		},
+		{
+			name:           "only supported encodings",
+			acceptEncoding: "br",
+			supported:      []string{Zstd, Gzip},
+			want:           "",
+		},
+		{
+			name:           "explicitly rejected",
+			acceptEncoding: "br;q=0, gzip",
+			supported:      []string{Brotli, Gzip},
+			want:           Gzip,
+		},
+		{
+			name:           "wildcard",
+			acceptEncoding: "*",
+			supported:      []string{Zstd, Gzip},
+			want:           Zstd,
+		},
+		{
+			name:           "wildcard with exclusions",
+			acceptEncoding: "zstd;q=0, *;q=0.5",
+			supported:      []string{Zstd, Gzip},
+			want:           Gzip,
+		},
+		{
+			name:           "x-gzip alias and uppercase",
+			acceptEncoding: "X-GZIP",
+			supported:      []string{Brotli, Gzip},
+			want:           Gzip,
+		},
+		{
+			name:           "identity only",
+			acceptEncoding: "identity",
+			supported:      []string{Brotli, Zstd, Gzip},
+			want:           "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Negotiate(tt.acceptEncoding, tt.supported...); got != tt.want {
+				t.Errorf("Negotiate(%q) = %q, want %q", tt.acceptEncoding, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		want     []string
+	}{
+		{name: "no vary header", want: []string{"Accept-Encoding"}},
+		{name: "other headers", existing: []string{"Origin"}, want: []string{"Origin", "Accept-Encoding"}},
+		{name: "already listed", existing: []string{"Origin, accept-encoding"}, want: []string{"Origin, accept-encoding"}},
+		{name: "varies on everything", existing: []string{"*"}, want: []string{"*"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for _, v := range tt.existing {
+				h.Add("Vary", v)
+			}
+
+			AddVary(h, "Accept-Encoding")
+			if got := h.Values("Vary"); !slices.Equal(got, tt.want) {
+				t.Fatalf("expected vary headers %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package compression
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/patrickdappollonio/http-server/internal/ctype"
+)
+
+// Supported lists the encodings Handler can compress responses with.
+var Supported = []string{Gzip, Brotli, Zstd}
+
+// brotliLevel is the compression level used for brotli, lower than its
+// default since responses are compressed on every request.
+const brotliLevel = 4
+
+// encoder is implemented by the gzip, brotli and zstd writers.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// newEncoder creates a writer for the encoding, or returns nil if the
+// encoding isn't supported.
+//
+//nolint:ireturn // encoders of different packages are pooled together
+func newEncoder(encoding string) encoder {
+	switch encoding {
+	case Gzip:
+		return gzip.NewWriter(nil)
+	case Brotli:
+		return brotli.NewWriterLevel(nil, brotliLevel)
+	case Zstd:
+		// The options are fixed, so this can't fail. Browsers refuse
+		// windows bigger than 8 MB, so stay well below that.
+		enc, _ := zstd.NewWriter(nil,
+			zstd.WithEncoderLevel(zstd.SpeedFastest),
+			zstd.WithEncoderConcurrency(1),
+			zstd.WithWindowSize(1<<20),
+			zstd.WithLowerEncoderMem(true),
+		)
+		return enc
+	default:
+		return nil
+	}
+}
+
+// Handler returns a middleware that compresses responses using the
+// encodings given, picking the one preferred by the client through the
+// "Accept-Encoding" header, with ties broken by the order of the encodings.
+// Responses smaller than minSize, responses already encoded, partial
+// responses, and content types that are already compressed, like zip
+// files, videos or most images, are sent as-is.
+func Handler(encodings []string, minSize int64) func(http.Handler) http.Handler {
+	encodings = slices.DeleteFunc(slices.Clone(encodings), func(e string) bool {
+		return !slices.Contains(Supported, e)
+	})
+
+	pools := make(map[string]*sync.Pool, len(encodings))
+	for _, encoding := range encodings {
+		pools[encoding] = &sync.Pool{
+			New: func() interface{} { return newEncoder(encoding) },
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(encodings) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The response depends on the "Accept-Encoding" header, even
+			// when it ends up not being compressed
+			AddVary(w.Header(), "Accept-Encoding")
+
+			encoding := Negotiate(r.Header.Get("Accept-Encoding"), encodings...)
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &responseWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				pool:           pools[encoding],
+				minSize:        minSize,
+			}
+
+			next.ServeHTTP(cw, r)
+			cw.close()
+		})
+	}
+}
+
+// responseWriter buffers the beginning of a response until it can decide
+// whether to compress it, and then either compresses or passes through
+// the rest of it.
+type responseWriter struct {
+	http.ResponseWriter
+	encoding string
+	pool     *sync.Pool
+	minSize  int64
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+// WriteHeader records the status code, which is sent once the response
+// is known to be compressed or not.
+func (w *responseWriter) WriteHeader(code int) {
+	// Informational responses, like "103 Early Hints", go through
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	if w.decided {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	if w.status == 0 {
+		w.status = code
+	}
+
+	// Responses with a known small size don't need to be buffered
+	if w.declaredLength() >= 0 {
+		w.decide(false)
+	}
+}
+
+// Write buffers the content until there's enough of it to decide
+// whether to compress it.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+
+		w.buf = append(w.buf, b...)
+		if int64(len(w.buf)) < w.minSize {
+			return len(b), nil
+		}
+
+		if err := w.decide(false); err != nil {
+			return 0, err
+		}
+
+		return len(b), nil
+	}
+
+	if w.enc != nil {
+		return w.enc.Write(b) //nolint:wrapcheck // errors from the underlying writer are returned as-is
+	}
+
+	return w.ResponseWriter.Write(b) //nolint:wrapcheck // errors from the underlying writer are returned as-is
+}
+
+// Flush sends any buffered content to the client. Flushed responses are
+// streamed, so they're compressed regardless of the minimum size.
+func (w *responseWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+
+	if w.enc != nil {
+		if err := w.enc.Flush(); err != nil {
+			return
+		}
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the original writer.
+//
+//nolint:ireturn // the signature is required by http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close sends whatever is still buffered and finishes the compressed
+// stream, if any.
+func (w *responseWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			// Nothing was written, let the server send its defaults
+			w.decided = true
+			return
+		}
+
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+
+		if err := w.decide(false); err != nil {
+			return
+		}
+	}
+
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc.Reset(nil)
+		w.pool.Put(w.enc)
+		w.enc = nil
+	}
+}
+
+// declaredLength returns the value of the "Content-Length" header, or -1
+// if it isn't set or it's invalid.
+func (w *responseWriter) declaredLength() int64 {
+	cl := w.Header().Get("Content-Length")
+	if cl == "" {
+		return -1
+	}
+
+	n, err := strconv.ParseInt(cl, 10, 64)
+	if err != nil || n < 0 {
+		return -1
+	}
+
+	return n
+}
+
+// decide sets the headers for a compressed or plain response, sends
+// them and writes any buffered content.
+func (w *responseWriter) decide(streaming bool) error {
+	w.decided = true
+	h := w.Header()
+
+	// Detect the content type the same way the server would, since it
+	// won't be able to sniff it from compressed content
+	if _, found := h["Content-Type"]; !found && len(w.buf) > 0 && bodyAllowed(w.status) {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if w.shouldCompress(streaming) {
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		h.Set("Content-Encoding", w.encoding)
+
+		// The compressed content is not byte-for-byte the same as the
+		// original, so strong validators no longer apply
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+
+		w.enc = w.pool.Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	buf := w.buf
+	w.buf = nil
+
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+
+	return err //nolint:wrapcheck // errors from the underlying writer are returned as-is
+}
+
+// shouldCompress checks whether the response can and should be compressed.
+func (w *responseWriter) shouldCompress(streaming bool) bool {
+	h := w.Header()
+
+	if !bodyAllowed(w.status) || w.status == http.StatusPartialContent {
+		return false
+	}
+
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+
+	if ctype.IsCompressed(h.Get("Content-Type")) {
+		return false
+	}
+
+	if cl := w.declaredLength(); cl >= 0 {
+		return cl >= w.minSize && cl > 0
+	}
+
+	return streaming || (len(w.buf) > 0 && int64(len(w.buf)) >= w.minSize)
+}
+
+// bodyAllowed checks if a response with the given status can have a body.
+func bodyAllowed(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	default:
+		return true
+	}
+}
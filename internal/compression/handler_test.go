@@ -0,0 +1,343 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+var testModTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+
+	var r io.Reader
+	switch encoding {
+	case Gzip:
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("unable to read gzip body: %v", err)
+		}
+		r = gr
+	case Brotli:
+		r = brotli.NewReader(bytes.NewReader(body))
+	case Zstd:
+		zr, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("unable to read zstd body: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return string(body)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unable to decompress %s body: %v", encoding, err)
+	}
+
+	return string(out)
+}
+
+func TestHandler(t *testing.T) {
+	long := strings.Repeat("hello, world! ", 200)
+
+	tests := []struct {
+		name           string
+		encodings      []string
+		acceptEncoding string
+		handler        http.HandlerFunc
+		wantEncoding   string
+		wantStatus     int
+		wantBody       string
+	}{
+		{
+			name:           "gzip",
+			encodings:      []string{Gzip},
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				io.WriteString(w, long)
+			},
+			wantEncoding: Gzip,
+			wantBody:     long,
+		},
+		{
+			name:           "brotli",
+			encodings:      []string{Gzip, Brotli, Zstd},
+			acceptEncoding: "br",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				io.WriteString(w, long)
+			},
+			wantEncoding: Brotli,
+			wantBody:     long,
+		},
+		{
+			name:           "zstd",
+			encodings:      []string{Zstd},
+			acceptEncoding: "gzip, zstd",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, long)
+			},
+			wantEncoding: Zstd,
+			wantBody:     long,
+		},
+		{
+			name:           "order of encodings breaks ties",
+			encodings:      []string{Zstd, Gzip, Brotli},
+			acceptEncoding: "gzip, br, zstd",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, long)
+			},
+			wantEncoding: Zstd,
+			wantBody:     long,
+		},
+		{
+			name:           "client not accepting any encoding",
+			encodings:      []string{Gzip, Brotli},
+			acceptEncoding: "zstd",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, long)
+			},
+			wantBody: long,
+		},
+		{
+			name:           "small response",
+			encodings:      []string{Gzip},
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "hello")
+			},
+			wantBody: "hello",
+		},
+		{
+			name:           "small response with content length",
+			encodings:      []string{Gzip},
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "5")
+				w.WriteHeader(http.StatusOK)
+				io.WriteString(w, "hello")
+			},
+			wantBody: "hello",
+		},
+		{
+			name:           "already compressed content type",
+			encodings:      []string{Gzip},
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/zip")
+				io.WriteString(w, long)
+			},
+			wantBody: long,
+		},
+		{
+			name:           "already encoded response",
+			encodings:      []string{Gzip, Brotli},
+			acceptEncoding: "gzip, br",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", Zstd)
+				io.WriteString(w, long)
+			},
+			wantEncoding: Zstd,
+			wantBody:     long,
+		},
+		{
+			name:           "partial content",
+			encodings:      []string{Gzip},
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Range", "bytes 0-2799/5000")
+				w.WriteHeader(http.StatusPartialContent)
+				io.WriteString(w, long)
+			},
+			wantStatus: http.StatusPartialContent,
+			wantBody:   long,
+		},
+		{
+			name:           "error pages are compressed too",
+			encodings:      []string{Gzip},
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, long, http.StatusNotFound)
+			},
+			wantEncoding: Gzip,
+			wantStatus:   http.StatusNotFound,
+			wantBody:     long + "\n",
+		},
+		{
+			name:           "response written in small chunks",
+			encodings:      []string{Brotli},
+			acceptEncoding: "br",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < len(long); i += 10 {
+					io.WriteString(w, long[i:min(i+10, len(long))])
+				}
+			},
+			wantEncoding: Brotli,
+			wantBody:     long,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Handler(tt.encodings, 1024)(tt.handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			wantStatus := tt.wantStatus
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+
+			if rec.Code != wantStatus {
+				t.Fatalf("expected status %d, got %d", wantStatus, rec.Code)
+			}
+
+			encoding := rec.Header().Get("Content-Encoding")
+			if encoding != tt.wantEncoding {
+				t.Fatalf("expected encoding %q, got %q", tt.wantEncoding, encoding)
+			}
+
+			if vary := rec.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Errorf("expected \"Vary: Accept-Encoding\" header, got %q", vary)
+			}
+
+			if encoding != "" && rec.Header().Get("Content-Length") != "" {
+				t.Errorf("expected no content length on encoded response, got %q", rec.Header().Get("Content-Length"))
+			}
+
+			// Responses encoded by the handler itself are left untouched
+			got := rec.Body.String()
+			if slices.Contains(tt.encodings, encoding) {
+				got = decompress(t, encoding, rec.Body.Bytes())
+			}
+
+			if got != tt.wantBody {
+				t.Errorf("unexpected body: got %d bytes, want %d bytes", len(got), len(tt.wantBody))
+			}
+		})
+	}
+}
+
+func TestHandlerServeContent(t *testing.T) {
+	content := strings.Repeat("body { color: red; }\n", 100)
+	handler := Handler([]string{Gzip}, 1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		http.ServeContent(w, r, "style.css", testModTime, strings.NewReader(content))
+	}))
+
+	t.Run("full response is compressed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != Gzip {
+			t.Fatalf("expected gzip encoding, got %q", got)
+		}
+
+		if got := rec.Header().Get("ETag"); got != `W/"abc"` {
+			t.Errorf("expected weak etag, got %q", got)
+		}
+
+		if got := rec.Header().Get("Accept-Ranges"); got != "" {
+			t.Errorf("expected no Accept-Ranges header, got %q", got)
+		}
+
+		if got := decompress(t, Gzip, rec.Body.Bytes()); got != content {
+			t.Errorf("unexpected body after decompression")
+		}
+	})
+
+	t.Run("range requests are not compressed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("Range", "bytes=0-9")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusPartialContent {
+			t.Fatalf("expected status 206, got %d", rec.Code)
+		}
+
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Fatalf("expected no encoding, got %q", got)
+		}
+
+		if got := rec.Body.String(); got != content[:10] {
+			t.Errorf("expected %q, got %q", content[:10], got)
+		}
+	})
+
+	t.Run("weak etag matches on revalidation", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("If-None-Match", `W/"abc"`)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotModified {
+			t.Fatalf("expected status 304, got %d", rec.Code)
+		}
+
+		if rec.Body.Len() != 0 {
+			t.Errorf("expected no body, got %d bytes", rec.Body.Len())
+		}
+	})
+
+	t.Run("content length header is sent for plain responses", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(content)) {
+			t.Errorf("expected content length %d, got %q", len(content), got)
+		}
+	})
+}
+
+func TestHandlerFlush(t *testing.T) {
+	handler := Handler([]string{Gzip}, 1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "first")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "second")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !rec.Flushed {
+		t.Errorf("expected the response to be flushed")
+	}
+
+	if got := rec.Header().Get("Content-Encoding"); got != Gzip {
+		t.Fatalf("expected streamed response to be compressed, got encoding %q", got)
+	}
+
+	if got := decompress(t, Gzip, rec.Body.Bytes()); got != "firstsecond" {
+		t.Errorf("expected %q, got %q", "firstsecond", got)
+	}
+}
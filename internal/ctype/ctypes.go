@@ -1,5 +1,7 @@
 package ctype
 
+import "strings"
+
 var ctypes = []struct {
 	Extension   []string
 	ExactNames  []string
@@ -214,3 +216,75 @@ func GetContentTypeForFilename(name string) string {
 
 	return ""
 }
+
+// compressedTypes are content types from the table above whose contents
+// are already compressed, so compressing them again is a waste of time.
+var compressedTypes = map[string]bool{
+	// Archives
+	"application/x-7z-compressed": true,
+	"application/x-freearc":       true,
+	"application/x-bzip":          true,
+	"application/x-bzip2":         true,
+	"application/gzip":            true,
+	"application/x-gzip":          true,
+	"application/vnd.rar":         true,
+	"application/x-xz":            true,
+	"application/x-lzip":          true,
+	"application/zip":             true,
+	"application/x-compress":      true,
+	"application/x-zlib":          true,
+	"application/zstd":            true,
+
+	// Formats stored as zip files
+	"application/vnd.android.package-archive":                                   true,
+	"application/vnd.oasis.opendocument.presentation":                           true,
+	"application/vnd.oasis.opendocument.spreadsheet":                            true,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   true,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.template":   true,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         true,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.template":      true,
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": true,
+	"application/vnd.openxmlformats-officedocument.presentationml.template":     true,
+
+	// Fonts
+	"font/woff":  true,
+	"font/woff2": true,
+}
+
+// uncompressedMedia are audio, video and image content types that, unlike
+// most media formats, aren't compressed.
+var uncompressedMedia = map[string]bool{
+	"audio/aiff":               true,
+	"audio/wav":                true,
+	"audio/midi":               true,
+	"image/bmp":                true,
+	"image/svg+xml":            true,
+	"image/tiff":               true,
+	"image/vnd.microsoft.icon": true,
+	"image/x-icon":             true,
+	"image/x-raw":              true,
+}
+
+// IsCompressed checks if the content type, as sent in a "Content-Type"
+// header, is a format whose contents are already compressed, like zip
+// archives, most audio, video and image formats, and web fonts.
+func IsCompressed(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	if compressedTypes[mediaType] {
+		return true
+	}
+
+	if uncompressedMedia[mediaType] {
+		return false
+	}
+
+	for _, prefix := range []string{"audio/", "video/", "image/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
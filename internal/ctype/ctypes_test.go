@@ -81,3 +81,43 @@ func Test_findNoDuplicatesInContentTypes(t *testing.T) {
 		}
 	}
 }
+
+func TestIsCompressed(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     bool
+	}{
+		{filename: "archive.zip", want: true},
+		{filename: "archive.tar.gz", want: true},
+		{filename: "movie.mp4", want: true},
+		{filename: "picture.png", want: true},
+		{filename: "picture.jpg", want: true},
+		{filename: "font.woff2", want: true},
+		{filename: "report.docx", want: true},
+		{filename: "drawing.svg", want: false},
+		{filename: "favicon.ico", want: false},
+		{filename: "sound.wav", want: false},
+		{filename: "index.html", want: false},
+		{filename: "app.js", want: false},
+		{filename: "data.json", want: false},
+		{filename: "archive.tar", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			ct := GetContentTypeForFilename(tt.filename)
+			if ct == "" {
+				t.Fatalf("no content type found for %q", tt.filename)
+			}
+
+			if got := IsCompressed(ct); got != tt.want {
+				t.Errorf("IsCompressed(%q) = %v, want %v", ct, got, tt.want)
+			}
+		})
+	}
+
+	// Parameters and casing in the header value are ignored
+	if !IsCompressed("Application/ZIP; charset=binary") {
+		t.Errorf("expected content type with parameters to be detected as compressed")
+	}
+}
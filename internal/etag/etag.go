@@ -0,0 +1,126 @@
+// Package etag generates entity tags for files and compares them against
+// the conditional request headers "If-None-Match", "If-Match" and
+// "If-Range".
+package etag
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FromFileInfo generates a strong entity tag from the inode, size and
+// modification time of a file, without reading its content, so it can be
+// used for files of any size. Any change to the file produces a different
+// entity tag.
+func FromFileInfo(fi os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x-%x"`, inode(fi), fi.Size(), fi.ModTime().UnixNano())
+}
+
+// IsWeak checks if the entity tag is a weak one, like `W/"abc"`.
+func IsWeak(etag string) bool {
+	return strings.HasPrefix(etag, "W/")
+}
+
+// opaque returns the entity tag without the weak indicator.
+func opaque(etag string) string {
+	return strings.TrimPrefix(etag, "W/")
+}
+
+// WeakMatch compares two entity tags using the weak comparison, where
+// only the opaque tags need to be equal, regardless of either being weak.
+func WeakMatch(a, b string) bool {
+	return a != "" && opaque(a) == opaque(b)
+}
+
+// StrongMatch compares two entity tags using the strong comparison, where
+// both need to be strong and equal.
+func StrongMatch(a, b string) bool {
+	return a != "" && a == b && !IsWeak(a)
+}
+
+// Parse splits a header value holding a comma-separated list of entity
+// tags, like the value of "If-None-Match", into the individual entity
+// tags. Invalid entries end the parsing, ignoring the rest of the list.
+func Parse(header string) []string {
+	var tags []string
+
+	for {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			return tags
+		}
+
+		if header[0] == '*' {
+			tags = append(tags, "*")
+			header = header[1:]
+			continue
+		}
+
+		tag, rest, ok := scan(header)
+		if !ok {
+			return tags
+		}
+
+		tags = append(tags, tag)
+		header = rest
+	}
+}
+
+// scan reads the entity tag at the beginning of s, returning it and the
+// rest of the string.
+func scan(s string) (string, string, bool) {
+	start := 0
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+
+	if len(s)-start < 2 || s[start] != '"' {
+		return "", "", false
+	}
+
+	// The opaque tag can contain any visible character but quotes
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return s[:i+1], s[i+1:], true
+		case c == 0x21 || (c >= 0x23 && c <= 0x7E) || c >= 0x80:
+		default:
+			return "", "", false
+		}
+	}
+
+	return "", "", false
+}
+
+// NoneMatch evaluates an "If-None-Match" header value against the current
+// entity tag of a resource, returning true if any of the entity tags
+// listed matches it using the weak comparison, or if the list is "*".
+func NoneMatch(header, current string) bool {
+	for _, tag := range Parse(header) {
+		if tag == "*" || WeakMatch(tag, current) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match evaluates an "If-Match" header value against the current entity
+// tag of a resource, returning true if any of the entity tags listed
+// matches it using the strong comparison, or if the list is "*" and the
+// resource has an entity tag.
+func Match(header, current string) bool {
+	for _, tag := range Parse(header) {
+		if tag == "*" && current != "" {
+			return true
+		}
+
+		if StrongMatch(tag, current) {
+			return true
+		}
+	}
+
+	return false
+}
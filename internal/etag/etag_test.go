@@ -0,0 +1,367 @@
+package etag
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{header: `"abc"`, want: []string{`"abc"`}},
+		{header: `W/"abc"`, want: []string{`W/"abc"`}},
+		{header: `"abc", W/"def" ,"ghi"`, want: []string{`"abc"`, `W/"def"`, `"ghi"`}},
+		{header: `*`, want: []string{"*"}},
+		{header: `""`, want: []string{`""`}},
+		{header: `"abc", invalid, "def"`, want: []string{`"abc"`}},
+		{header: `"unterminated`, want: nil},
+		{header: ``, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := Parse(tt.header); !slices.Equal(got, tt.want) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		name          string
+		header        string
+		current       string
+		wantNoneMatch bool
+		wantMatch     bool
+	}{
+		{name: "same strong tag", header: `"abc"`, current: `"abc"`, wantNoneMatch: true, wantMatch: true},
+		{name: "weak tag in header", header: `W/"abc"`, current: `"abc"`, wantNoneMatch: true, wantMatch: false},
+		{name: "weak current tag", header: `"abc"`, current: `W/"abc"`, wantNoneMatch: true, wantMatch: false},
+		{name: "tag in a list", header: `"xyz", "abc"`, current: `"abc"`, wantNoneMatch: true, wantMatch: true},
+		{name: "different tag", header: `"xyz"`, current: `"abc"`, wantNoneMatch: false, wantMatch: false},
+		{name: "wildcard", header: `*`, current: `"abc"`, wantNoneMatch: true, wantMatch: true},
+		{name: "wildcard without current tag", header: `*`, current: ``, wantNoneMatch: true, wantMatch: false},
+		{name: "unquoted tag", header: `abc`, current: `"abc"`, wantNoneMatch: false, wantMatch: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NoneMatch(tt.header, tt.current); got != tt.wantNoneMatch {
+				t.Errorf("NoneMatch(%q, %q) = %v, want %v", tt.header, tt.current, got, tt.wantNoneMatch)
+			}
+
+			if got := Match(tt.header, tt.current); got != tt.wantMatch {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.header, tt.current, got, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestFromFileInfo(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(location, []byte("hello"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	stat := func() os.FileInfo {
+		fi, err := os.Stat(location)
+		if err != nil {
+			t.Fatalf("unable to stat file: %v", err)
+		}
+		return fi
+	}
+
+	first := FromFileInfo(stat())
+	if IsWeak(first) || len(Parse(first)) != 1 {
+		t.Fatalf("expected a single strong entity tag, got %q", first)
+	}
+
+	if again := FromFileInfo(stat()); again != first {
+		t.Errorf("expected the same entity tag for an unchanged file, got %q and %q", first, again)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(location, later, later); err != nil {
+		t.Fatalf("unable to change file times: %v", err)
+	}
+
+	if changed := FromFileInfo(stat()); changed == first {
+		t.Errorf("expected a different entity tag after the file changed")
+	}
+}
+
+func TestHashCache(t *testing.T) {
+	dir := t.TempDir()
+	location := filepath.Join(dir, "file.txt")
+	cacheFile := filepath.Join(dir, "hashes.json")
+
+	if err := os.WriteFile(location, []byte("hello"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	fi, err := os.Stat(location)
+	if err != nil {
+		t.Fatalf("unable to stat file: %v", err)
+	}
+
+	c, err := LoadHashCache(cacheFile)
+	if err != nil {
+		t.Fatalf("unable to load missing cache: %v", err)
+	}
+
+	tag, err := c.ETag(location, fi)
+	if err != nil {
+		t.Fatalf("unable to generate entity tag: %v", err)
+	}
+
+	// The first 16 bytes of the SHA-256 of "hello"
+	if want := `"2cf24dba5fb0a30e26e83b2ac5b9e29e"`; tag != want {
+		t.Errorf("expected entity tag %s, got %s", want, tag)
+	}
+
+	if err := c.Save(); err != nil {
+		t.Fatalf("unable to save cache: %v", err)
+	}
+
+	// Replace the content without changing the metadata, to check the
+	// hash is taken from the persisted cache instead of the file
+	if err := os.WriteFile(location, []byte("HELLO"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+	if err := os.Chtimes(location, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatalf("unable to change file times: %v", err)
+	}
+
+	loaded, err := LoadHashCache(cacheFile)
+	if err != nil {
+		t.Fatalf("unable to load saved cache: %v", err)
+	}
+
+	fi, err = os.Stat(location)
+	if err != nil {
+		t.Fatalf("unable to stat file: %v", err)
+	}
+
+	if got, err := loaded.ETag(location, fi); err != nil || got != tag {
+		t.Errorf("expected persisted entity tag %s, got %s (error: %v)", tag, got, err)
+	}
+
+	// Changing the modification time invalidates the hash
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(location, later, later); err != nil {
+		t.Fatalf("unable to change file times: %v", err)
+	}
+
+	fi, err = os.Stat(location)
+	if err != nil {
+		t.Fatalf("unable to stat file: %v", err)
+	}
+
+	if got, err := loaded.ETag(location, fi); err != nil || got == tag {
+		t.Errorf("expected a new entity tag after the file changed, got %s (error: %v)", got, err)
+	}
+}
+
+func TestHashCacheBackground(t *testing.T) {
+	dir := t.TempDir()
+	location := filepath.Join(dir, "big.bin")
+
+	if err := os.WriteFile(location, []byte("hello"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	fi, err := os.Stat(location)
+	if err != nil {
+		t.Fatalf("unable to stat file: %v", err)
+	}
+
+	c, err := LoadHashCache("")
+	if err != nil {
+		t.Fatalf("unable to load cache: %v", err)
+	}
+	c.SetMaxSyncSize(4)
+
+	if _, err := c.ETag(location, fi); !errors.Is(err, ErrHashPending) {
+		t.Fatalf("expected the hash to be pending, got: %v", err)
+	}
+
+	// The hash is available once the background hashing finishes
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tag, err := c.ETag(location, fi)
+		if err == nil {
+			if want := `"2cf24dba5fb0a30e26e83b2ac5b9e29e"`; tag != want {
+				t.Fatalf("expected entity tag %s, got %s", want, tag)
+			}
+			break
+		}
+
+		if !errors.Is(err, ErrHashPending) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the background hash")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHashCacheBackgroundWorkers(t *testing.T) {
+	dir := t.TempDir()
+
+	c, err := LoadHashCache("")
+	if err != nil {
+		t.Fatalf("unable to load cache: %v", err)
+	}
+	c.SetMaxSyncSize(1)
+
+	// Block the hashing until all the files are queued, keeping track
+	// of how many files are hashed at the same time
+	var (
+		mu            sync.Mutex
+		running, peak int
+		hashed        = make(map[string]int)
+		release       = make(chan struct{})
+	)
+	c.hash = func(location string) (string, error) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		hashed[location]++
+		mu.Unlock()
+
+		<-release
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return "hash", nil
+	}
+
+	type file struct {
+		location string
+		fi       os.FileInfo
+	}
+
+	files := make([]file, 0, backgroundWorkers*3)
+	for i := range backgroundWorkers * 3 {
+		location := filepath.Join(dir, fmt.Sprintf("file-%d.bin", i))
+		if err := os.WriteFile(location, []byte("hello"), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+
+		fi, err := os.Stat(location)
+		if err != nil {
+			t.Fatalf("unable to stat file: %v", err)
+		}
+
+		files = append(files, file{location: location, fi: fi})
+	}
+
+	// Request every file twice while the workers are busy
+	for range 2 {
+		for _, f := range files {
+			if _, err := c.ETag(f.location, f.fi); !errors.Is(err, ErrHashPending) {
+				t.Fatalf("expected the hash of %q to be pending, got: %v", f.location, err)
+			}
+		}
+	}
+	close(release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for _, f := range files {
+		for {
+			if _, err := c.ETag(f.location, f.fi); err == nil {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for the background hash of %q", f.location)
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if peak > backgroundWorkers {
+		t.Fatalf("expected at most %d files hashed at the same time, got %d", backgroundWorkers, peak)
+	}
+
+	for _, f := range files {
+		if hashed[f.location] != 1 {
+			t.Fatalf("expected %q to be hashed once, got %d times", f.location, hashed[f.location])
+		}
+	}
+}
+
+func TestHashCachePrune(t *testing.T) {
+	dir := t.TempDir()
+	cacheFile := filepath.Join(dir, "hashes.json")
+	kept, removed := filepath.Join(dir, "kept.txt"), filepath.Join(dir, "removed.txt")
+
+	c, err := LoadHashCache(cacheFile)
+	if err != nil {
+		t.Fatalf("unable to load missing cache: %v", err)
+	}
+
+	for _, location := range []string{kept, removed} {
+		if err := os.WriteFile(location, []byte(location), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+
+		fi, err := os.Stat(location)
+		if err != nil {
+			t.Fatalf("unable to stat file: %v", err)
+		}
+
+		if _, err := c.ETag(location, fi); err != nil {
+			t.Fatalf("unable to generate entity tag: %v", err)
+		}
+	}
+
+	if err := os.Remove(removed); err != nil {
+		t.Fatalf("unable to remove file: %v", err)
+	}
+
+	if err := c.Save(); err != nil {
+		t.Fatalf("unable to save cache: %v", err)
+	}
+
+	loaded, err := LoadHashCache(cacheFile)
+	if err != nil {
+		t.Fatalf("unable to load saved cache: %v", err)
+	}
+
+	if _, found := loaded.entries[removed]; found {
+		t.Errorf("expected the hash of a removed file to be pruned")
+	}
+
+	if _, found := loaded.entries[kept]; !found {
+		t.Errorf("expected the hash of an existing file to be kept")
+	}
+}
+
+func TestLoadHashCacheInvalid(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "hashes.json")
+	if err := os.WriteFile(cacheFile, []byte("not json"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	if _, err := LoadHashCache(cacheFile); err == nil {
+		t.Errorf("expected an error loading an invalid cache file")
+	}
+}
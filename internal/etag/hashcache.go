@@ -0,0 +1,283 @@
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrHashPending is returned when the file is too big to be hashed while
+// handling a request, and its hash is being computed in the background.
+var ErrHashPending = errors.New("file is being hashed in the background")
+
+// hashLength is the number of bytes of the content hash used in the
+// entity tag, which is plenty to tell versions of a file apart.
+const hashLength = 16
+
+const (
+	// backgroundWorkers is the number of files hashed in the background
+	// at the same time, so a burst of requests for big files doesn't
+	// read all of them from disk at once.
+	backgroundWorkers = 2
+
+	// backgroundQueueSize is the number of files waiting to be hashed
+	// in the background. Once the queue is full, files are left to be
+	// queued again by later requests.
+	backgroundQueueSize = 256
+)
+
+// hashJob is a file waiting to be hashed in the background, alongside
+// its metadata when it was queued.
+type hashJob struct {
+	location string
+	fi       os.FileInfo
+}
+
+// hashEntry is the content hash of a file, alongside the metadata used
+// to know if the file changed since it was hashed.
+type hashEntry struct {
+	Inode   uint64 `json:"inode"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+	Hash    string `json:"hash"`
+}
+
+// matches checks if the entry was computed for the file as it is now.
+func (e hashEntry) matches(fi os.FileInfo) bool {
+	return e.Inode == inode(fi) && e.Size == fi.Size() && e.ModTime == fi.ModTime().UnixNano()
+}
+
+// HashCache generates strong entity tags from the content of files,
+// hashing each file only once per inode, size and modification time. The
+// hashes can be persisted to a file, so they survive restarts and files
+// don't need to be read again.
+type HashCache struct {
+	mu          sync.Mutex
+	path        string
+	entries     map[string]hashEntry
+	pending     map[string]struct{}
+	queue       chan hashJob
+	workers     sync.Once
+	hash        func(location string) (string, error)
+	maxSyncSize int64
+	dirty       bool
+}
+
+// LoadHashCache creates a cache persisted at the given path, loading the
+// hashes previously saved there, if any. An empty path creates a cache
+// that's only kept in memory.
+func LoadHashCache(path string) (*HashCache, error) {
+	c := &HashCache{
+		path:    path,
+		entries: make(map[string]hashEntry),
+		pending: make(map[string]struct{}),
+		queue:   make(chan hashJob, backgroundQueueSize),
+		hash:    hashFile,
+	}
+	if path == "" {
+		return c, nil
+	}
+
+	content, err := os.ReadFile(path) //nolint:gosec // path provided by the operator through configuration
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+
+		return nil, fmt.Errorf("unable to read ETag hash cache %q: %w", path, err)
+	}
+
+	if err := json.Unmarshal(content, &c.entries); err != nil {
+		return nil, fmt.Errorf("unable to parse ETag hash cache %q: %w", path, err)
+	}
+
+	c.prune()
+	return c, nil
+}
+
+// SetMaxSyncSize sets the size of the biggest file hashed while handling
+// a request. Bigger files are hashed in the background, so the request
+// isn't delayed by reading the whole file. Zero means no limit.
+func (c *HashCache) SetMaxSyncSize(size int64) {
+	c.mu.Lock()
+	c.maxSyncSize = size
+	c.mu.Unlock()
+}
+
+// ETag returns the entity tag for the file at the given location, based
+// on the hash of its content. The file is only read if it changed since
+// it was last hashed. Files bigger than the max sync size are hashed in
+// the background, returning ErrHashPending until their hash is ready.
+func (c *HashCache) ETag(location string, fi os.FileInfo) (string, error) {
+	c.mu.Lock()
+	entry, found := c.entries[location]
+	background := c.maxSyncSize > 0 && fi.Size() > c.maxSyncSize
+	c.mu.Unlock()
+
+	if found && entry.matches(fi) {
+		return `"` + entry.Hash + `"`, nil
+	}
+
+	if background {
+		c.hashInBackground(location, fi)
+		return "", ErrHashPending
+	}
+
+	hash, err := c.hash(location)
+	if err != nil {
+		return "", err
+	}
+
+	c.store(location, fi, hash)
+	return `"` + hash + `"`, nil
+}
+
+// hashInBackground queues the file to be hashed by the background
+// workers, unless it's already queued or being hashed. Files are dropped
+// when the queue is full, so a later request queues them again.
+func (c *HashCache) hashInBackground(location string, fi os.FileInfo) {
+	c.workers.Do(func() {
+		for range backgroundWorkers {
+			go c.work()
+		}
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, found := c.pending[location]; found {
+		return
+	}
+
+	select {
+	case c.queue <- hashJob{location: location, fi: fi}:
+		c.pending[location] = struct{}{}
+	default:
+	}
+}
+
+// work hashes the queued files one at a time. The hash is only kept if
+// the file didn't change while reading it.
+func (c *HashCache) work() {
+	for job := range c.queue {
+		hash, err := c.hash(job.location)
+		if err == nil {
+			if current, err := os.Stat(job.location); err == nil && (hashEntry{Inode: inode(job.fi), Size: job.fi.Size(), ModTime: job.fi.ModTime().UnixNano()}).matches(current) {
+				c.store(job.location, job.fi, hash)
+			}
+		}
+
+		c.mu.Lock()
+		delete(c.pending, job.location)
+		c.mu.Unlock()
+	}
+}
+
+// store keeps the hash of the file, as it was when it was hashed.
+func (c *HashCache) store(location string, fi os.FileInfo, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[location] = hashEntry{
+		Inode:   inode(fi),
+		Size:    fi.Size(),
+		ModTime: fi.ModTime().UnixNano(),
+		Hash:    hash,
+	}
+	c.dirty = true
+}
+
+// prune removes the hashes of files that no longer exist, so the cache
+// doesn't grow with every file ever served.
+func (c *HashCache) prune() {
+	c.mu.Lock()
+	locations := make([]string, 0, len(c.entries))
+	for location := range c.entries {
+		locations = append(locations, location)
+	}
+	c.mu.Unlock()
+
+	var removed []string
+	for _, location := range locations {
+		if _, err := os.Stat(location); errors.Is(err, os.ErrNotExist) {
+			removed = append(removed, location)
+		}
+	}
+
+	if len(removed) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	for _, location := range removed {
+		delete(c.entries, location)
+	}
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+// Save persists the hashes to the cache file, if one was configured and
+// there are new hashes since the last save, without the hashes of files
+// that no longer exist. The file is replaced atomically, so it's never
+// left half-written.
+func (c *HashCache) Save() error {
+	if c.path != "" {
+		c.prune()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.path == "" || !c.dirty {
+		return nil
+	}
+
+	content, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("unable to encode ETag hash cache: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create ETag hash cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write ETag hash cache file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write ETag hash cache file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("unable to replace ETag hash cache file %q: %w", c.path, err)
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// hashFile hashes the content of the file at the given location.
+func hashFile(location string) (string, error) {
+	f, err := os.Open(location) //nolint:gosec // file server: location is derived from the serving root, not raw user input
+	if err != nil {
+		return "", fmt.Errorf("unable to open file %q to hash it: %w", location, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("unable to hash file %q: %w", location, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:hashLength]), nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package etag
+
+import "os"
+
+// inode returns 0, since inode numbers aren't available on this platform,
+// leaving the size and modification time to identify the file.
+func inode(_ os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package etag
+
+import (
+	"os"
+	"syscall"
+)
+
+// inode returns the inode number of the file, or 0 if unknown.
+func inode(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino) //nolint:unconvert // the type of the inode number differs between platforms
+	}
+
+	return 0
+}
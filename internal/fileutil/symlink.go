@@ -0,0 +1,25 @@
+package fileutil
+
+import "os"
+
+// SymlinkInfo describes the file a symbolic link points to, keeping track
+// of the target of the link so it can be shown in directory listings.
+type SymlinkInfo struct {
+	os.FileInfo
+	Target string
+}
+
+// SymlinkTarget returns the target of the symbolic link.
+func (s SymlinkInfo) SymlinkTarget() string {
+	return s.Target
+}
+
+// SymlinkTarget returns the target of the symbolic link described by the
+// file information, or an empty string if it's not a symbolic link.
+func SymlinkTarget(fi os.FileInfo) string {
+	if link, ok := fi.(interface{ SymlinkTarget() string }); ok {
+		return link.SymlinkTarget()
+	}
+
+	return ""
+}
@@ -0,0 +1,188 @@
+// Package ignore matches paths against patterns written with the syntax
+// of ".gitignore" files.
+package ignore
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// pattern is a single parsed line of an ignore file.
+type pattern struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// Matcher checks paths against a list of patterns, where the last pattern
+// matching a path decides whether it's excluded or not.
+type Matcher struct {
+	patterns []pattern
+}
+
+// Parse parses patterns using the ".gitignore" syntax:
+//
+//   - Blank lines and lines starting with "#" are ignored.
+//   - Patterns starting with "!" include back paths excluded by a
+//     previous pattern.
+//   - Patterns ending in "/" only match directories.
+//   - Patterns with a "/" at the beginning or in the middle are relative
+//     to the root, while patterns without one match at any level.
+//   - "*", "?" and "[...]" match within a single path segment, while "**"
+//     matches any number of segments, like "**/logs" or "docs/**".
+//
+// Everything below an excluded directory is excluded too. Lines without
+// patterns return an empty matcher, which excludes nothing.
+func Parse(lines []string) (*Matcher, error) {
+	m := &Matcher{}
+
+	for _, line := range lines {
+		p, skip, err := parsePattern(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", strings.TrimSpace(line), err)
+		}
+
+		if !skip {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+
+	return m, nil
+}
+
+// Empty checks if the matcher has no patterns, and so excludes nothing.
+func (m *Matcher) Empty() bool {
+	return m == nil || len(m.patterns) == 0
+}
+
+// parsePattern parses a single line, reporting whether it should be
+// skipped because it's blank or a comment.
+func parsePattern(line string) (pattern, bool, error) {
+	var p pattern
+
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, true, nil
+	}
+
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// Patterns with a slash are relative to the root, otherwise they
+	// match at any level
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	if line == "" {
+		return p, false, errors.New("pattern is empty")
+	}
+
+	p.segments = strings.Split(line, "/")
+	for _, segment := range p.segments {
+		if segment == "" {
+			return p, false, errors.New("pattern has an empty path segment")
+		}
+
+		if _, err := path.Match(segment, ""); err != nil {
+			return p, false, fmt.Errorf("malformed wildcard: %w", err)
+		}
+	}
+
+	if !anchored {
+		p.segments = append([]string{"**"}, p.segments...)
+	}
+
+	return p, false, nil
+}
+
+// Match checks if the path, relative to the root and using forward
+// slashes, is excluded by the patterns. Paths within an excluded
+// directory are excluded too.
+func (m *Matcher) Match(name string, isDir bool) bool {
+	if m.Empty() {
+		return false
+	}
+
+	name = strings.Trim(name, "/")
+	if name == "" || name == "." {
+		return false
+	}
+
+	parts := strings.Split(name, "/")
+
+	// Files within excluded directories can't be included back, so
+	// check the parent directories first
+	for i := 1; i < len(parts); i++ {
+		if m.matches(parts[:i], true) {
+			return true
+		}
+	}
+
+	return m.matches(parts, isDir)
+}
+
+// matches checks if the last pattern matching the path segments excludes
+// them.
+func (m *Matcher) matches(parts []string, isDir bool) bool {
+	excluded := false
+
+	for _, p := range m.patterns {
+		// Only patterns that could change the outcome are checked
+		if p.negate != excluded || (p.dirOnly && !isDir) {
+			continue
+		}
+
+		if matchSegments(p.segments, parts) {
+			excluded = !p.negate
+		}
+	}
+
+	return excluded
+}
+
+// matchSegments matches the segments of a pattern against the segments
+// of a path, where "**" matches any number of segments.
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+
+			// A trailing "**" matches everything within a directory,
+			// but not the directory itself
+			if len(rest) == 0 {
+				return len(parts) > 0
+			}
+
+			for i := range len(parts) {
+				if matchSegments(rest, parts[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+
+		if matched, _ := path.Match(pattern[0], parts[0]); !matched {
+			return false
+		}
+
+		pattern, parts = pattern[1:], parts[1:]
+	}
+
+	return len(parts) == 0
+}
@@ -0,0 +1,97 @@
+package ignore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{name: "filename at the root", patterns: []string{"secret.txt"}, path: "secret.txt", want: true},
+		{name: "filename at any level", patterns: []string{"secret.txt"}, path: "a/b/secret.txt", want: true},
+		{name: "wildcard at any level", patterns: []string{"*.log"}, path: "logs/app.log", want: true},
+		{name: "wildcard matching a parent directory", patterns: []string{"logs*"}, path: "logs/app.txt", want: true},
+		{name: "no match", patterns: []string{"*.log"}, path: "app.txt", want: false},
+		{name: "anchored to the root", patterns: []string{"/build"}, path: "build", isDir: true, want: true},
+		{name: "anchored not matching nested", patterns: []string{"/build"}, path: "src/build", isDir: true, want: false},
+		{name: "pattern with a slash is anchored", patterns: []string{"docs/private"}, path: "docs/private", isDir: true, want: true},
+		{name: "pattern with a slash not matching nested", patterns: []string{"docs/private"}, path: "a/docs/private", isDir: true, want: false},
+		{name: "file within an excluded directory", patterns: []string{"/private"}, path: "private/a/b.txt", want: true},
+		{name: "directory only pattern on a directory", patterns: []string{"node_modules/"}, path: "web/node_modules", isDir: true, want: true},
+		{name: "directory only pattern on a file", patterns: []string{"node_modules/"}, path: "node_modules", want: false},
+		{name: "directory only pattern on contents", patterns: []string{"node_modules/"}, path: "node_modules/pkg/index.js", want: true},
+		{name: "leading double star", patterns: []string{"**/cache"}, path: "a/b/cache", isDir: true, want: true},
+		{name: "trailing double star", patterns: []string{"docs/**"}, path: "docs/a/b.md", want: true},
+		{name: "trailing double star not matching the directory", patterns: []string{"docs/**"}, path: "docs", isDir: true, want: false},
+		{name: "middle double star", patterns: []string{"a/**/z.txt"}, path: "a/z.txt", want: true},
+		{name: "middle double star nested", patterns: []string{"a/**/z.txt"}, path: "a/b/c/z.txt", want: true},
+		{name: "negation", patterns: []string{"*.txt", "!keep.txt"}, path: "keep.txt", want: false},
+		{name: "negation overridden later", patterns: []string{"*.txt", "!keep.txt", "keep.txt"}, path: "keep.txt", want: true},
+		{name: "negation can't include back within excluded directory", patterns: []string{"private/", "!private/keep.txt"}, path: "private/keep.txt", want: true},
+		{name: "escaped hash", patterns: []string{`\#notes`}, path: "#notes", want: true},
+		{name: "escaped exclamation mark", patterns: []string{`\!important`}, path: "!important", want: true},
+		{name: "comments and blank lines", patterns: []string{"# comment", "", "   "}, path: "# comment", want: false},
+		{name: "character class", patterns: []string{"file[0-9].txt"}, path: "file7.txt", want: true},
+		{name: "question mark", patterns: []string{"?.txt"}, path: "ab.txt", want: false},
+		{name: "root is never excluded", patterns: []string{"*"}, path: "", isDir: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(tt.patterns)
+			if err != nil {
+				t.Fatalf("unexpected error parsing patterns: %v", err)
+			}
+
+			if got := m.Match(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Match(%q, %v) with patterns %q = %v, want %v", tt.path, tt.isDir, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     []string
+		wantEmpty bool
+		wantError string
+	}{
+		{name: "no patterns", lines: nil, wantEmpty: true},
+		{name: "only comments", lines: []string{"# nothing", ""}, wantEmpty: true},
+		{name: "valid patterns", lines: []string{"*.log", "/build/", "!keep.log"}},
+		{name: "malformed wildcard", lines: []string{"*.log", "file[.txt"}, wantError: `invalid pattern "file[.txt"`},
+		{name: "empty pattern", lines: []string{"/"}, wantError: "pattern is empty"},
+		{name: "empty segment", lines: []string{"a//b"}, wantError: "empty path segment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(tt.lines)
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if m == nil {
+				t.Fatalf("expected a matcher, got nil")
+			}
+
+			if m.Empty() != tt.wantEmpty {
+				t.Errorf("expected empty matcher: %v, got %v", tt.wantEmpty, m.Empty())
+			}
+		})
+	}
+}
@@ -3,18 +3,17 @@ package middlewares
 import (
 	"fmt"
 	"net/http"
-	"path/filepath"
 	"strings"
 )
 
+// DisableAccessToFile returns a middleware that responds with the given
+// status code to requests whose path, as received, makes the function
+// return true, like paths to hidden files or within hidden directories.
 func DisableAccessToFile(fn func(string) bool, statusCode int) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get the file's name
-			fileName := filepath.Base(r.URL.Path)
-
 			// Check if the function passes
-			if fn(fileName) {
+			if fn(r.URL.Path) {
 				http.Error(w, fmt.Sprintf("%d %s", statusCode, strings.ToLower(http.StatusText(statusCode))), statusCode)
 				return
 			}
@@ -8,11 +8,15 @@ import (
 	"io"
 	"net/http"
 	"sync"
+
+	"github.com/patrickdappollonio/http-server/internal/etag"
 )
 
 // Etag returns a middleware that generates an ETag for responses
-// with a body size less than or equal to maxBodySize. If 'enabled' is false,
-// it simply returns the next handler without wrapping it.
+// with a body size less than or equal to maxBodySize. Responses that
+// already have an ETag, like files, are sent as-is without buffering them.
+// If 'enabled' is false, it simply returns the next handler without
+// wrapping it.
 func Etag(enabled bool, maxBodySize int64) func(http.Handler) http.Handler {
 	if !enabled {
 		// Middleware is disabled; return the next handler as-is.
@@ -66,12 +70,22 @@ func Etag(enabled bool, maxBodySize int64) func(http.Handler) http.Handler {
 				// Only proceed if the response was fully buffered and status code is in the 200 range.
 				if rw.size > 0 && rw.size <= rw.maxSize && rw.err == nil && rw.statusCode >= 200 && rw.statusCode < 300 {
 					// Compute the ETag.
-					etag := fmt.Sprintf("\"%x\"", hasher.Sum(nil))
+					tag := fmt.Sprintf("\"%x\"", hasher.Sum(nil))
 					// Set the ETag header.
-					rw.headers.Set("ETag", etag)
+					rw.headers.Set("ETag", tag)
 
-					// Check If-None-Match header.
-					if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+					// Check If-Match header, which requires a strong match.
+					if match := r.Header.Get("If-Match"); match != "" && !etag.Match(match, tag) {
+						rw.statusCode = http.StatusPreconditionFailed
+						rw.headers.Del("Content-Type")
+						rw.headers.Del("Content-Length")
+						rw.writeHeader()
+						return
+					}
+
+					// Check If-None-Match header, which may list multiple
+					// entity tags and uses the weak comparison.
+					if match := r.Header.Get("If-None-Match"); match != "" && etag.NoneMatch(match, tag) {
 						// Return 304 Not Modified.
 						rw.statusCode = http.StatusNotModified
 						rw.headers.Del("Content-Type")
@@ -108,9 +122,19 @@ type etagResponseWriter struct {
 func (w *etagResponseWriter) WriteHeader(statusCode int) {
 	if !w.headerWritten {
 		w.statusCode = statusCode
+
+		// Responses with their own ETag are sent as-is
+		if w.hasOwnETag() {
+			w.writeHeader()
+		}
 	}
 }
 
+// hasOwnETag checks if the handler set an ETag on its own.
+func (w *etagResponseWriter) hasOwnETag() bool {
+	return w.headers.Get("ETag") != ""
+}
+
 // writeHeader writes the headers to the underlying ResponseWriter.
 func (w *etagResponseWriter) writeHeader() {
 	if !w.headerWritten {
@@ -128,7 +152,7 @@ func (w *etagResponseWriter) Write(p []byte) (int, error) {
 	n := len(p)
 	w.size += int64(n)
 
-	if w.size <= w.maxSize && !w.headerWritten {
+	if w.size <= w.maxSize && !w.headerWritten && !w.hasOwnETag() {
 		// Buffer the data.
 		_, err := w.buf.Write(p)
 		if err != nil {
@@ -76,3 +76,84 @@ func TestEtag_OverLimitNoHeader(t *testing.T) {
 		t.Errorf("unexpected ETag header %q", etag)
 	}
 }
+
+// Test that If-None-Match lists and weak entity tags are matched.
+func TestEtag_IfNoneMatchListAndWeak(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello world")
+	})
+	mw := Etag(true, 64)
+
+	rr1 := httptest.NewRecorder()
+	mw(handler).ServeHTTP(rr1, httptest.NewRequest("GET", "/", nil))
+	etag := rr1.Header().Get("ETag")
+
+	for _, header := range []string{
+		`"other", ` + etag,
+		"W/" + etag,
+		"*",
+	} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-None-Match", header)
+		mw(handler).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %q: status = %d; want %d", header, rr.Code, http.StatusNotModified)
+		}
+	}
+}
+
+// Test that a non-matching If-Match header returns 412 Precondition Failed.
+func TestEtag_IfMatch(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello world")
+	})
+	mw := Etag(true, 64)
+
+	rr1 := httptest.NewRecorder()
+	mw(handler).ServeHTTP(rr1, httptest.NewRequest("GET", "/", nil))
+	etag := rr1.Header().Get("ETag")
+
+	tests := []struct {
+		header string
+		want   int
+	}{
+		{header: etag, want: http.StatusOK},
+		{header: `"other", ` + etag, want: http.StatusOK},
+		{header: "*", want: http.StatusOK},
+		{header: `"other"`, want: http.StatusPreconditionFailed},
+		{header: "W/" + etag, want: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-Match", tt.header)
+		mw(handler).ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("If-Match %q: status = %d; want %d", tt.header, rr.Code, tt.want)
+		}
+	}
+}
+
+// Test that responses with their own ETag are passed through untouched.
+func TestEtag_KeepsHandlerETag(t *testing.T) {
+	body := strings.Repeat("a", 128)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"from-handler"`)
+		io.WriteString(w, body)
+	})
+	mw := Etag(true, 64)
+
+	rr := httptest.NewRecorder()
+	mw(handler).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if got := rr.Header().Get("ETag"); got != `"from-handler"` {
+		t.Errorf("ETag = %q; want %q", got, `"from-handler"`)
+	}
+	if rr.Body.String() != body {
+		t.Errorf("unexpected body of %d bytes", rr.Body.Len())
+	}
+}
@@ -0,0 +1,289 @@
+package redirects
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Kinds of conditions supported by the redirection rules.
+const (
+	ConditionLanguage = "language"
+	ConditionCountry  = "country"
+	ConditionHost     = "host"
+	ConditionHeader   = "header"
+	ConditionCookie   = "cookie"
+	ConditionIP       = "ip"
+)
+
+// countryHeaders is a list of headers commonly set by CDNs and load
+// balancers with the country code of the client, in order of preference.
+var countryHeaders = []string{"CF-IPCountry", "CloudFront-Viewer-Country", "X-Country-Code"}
+
+// Condition restricts a rule to requests with specific properties. A rule
+// matches only when all its conditions match, and a condition matches when
+// any of its values match.
+type Condition struct {
+	Kind     string      // One of the Condition* constants
+	Name     string      // Header or cookie name, for conditions that need one
+	Values   []string    // Accepted values, an empty list means "present"
+	networks []net.IPNet // Parsed values for IP conditions
+}
+
+// String returns the condition as written in the redirections file.
+func (c Condition) String() string {
+	key := strings.ToUpper(c.Kind[:1]) + c.Kind[1:]
+	if c.Name != "" {
+		key += ":" + c.Name
+	}
+
+	if len(c.Values) == 0 {
+		return key
+	}
+
+	return key + "=" + strings.Join(c.Values, ",")
+}
+
+// parseCondition parses a condition in the form "Key=value1,value2",
+// "Header:Name=value" or "Cookie:name".
+func parseCondition(s string) (Condition, error) {
+	key, value, hasValue := strings.Cut(s, "=")
+	kind, name, hasName := strings.Cut(key, ":")
+	kind = strings.ToLower(kind)
+
+	var values []string
+	if hasValue {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+
+	c := Condition{Kind: kind, Name: name, Values: values}
+
+	switch kind {
+	case ConditionHeader, ConditionCookie:
+		if !hasName || name == "" {
+			return c, fmt.Errorf("condition %q requires a name, like \"%s:Name=value\"", s, key)
+		}
+		return c, nil
+
+	case ConditionLanguage, ConditionCountry, ConditionHost, ConditionIP:
+		if hasName {
+			return c, fmt.Errorf("condition %q does not accept a name", s)
+		}
+	default:
+		return c, fmt.Errorf("unsupported condition %q", s)
+	}
+
+	if len(values) == 0 {
+		return c, fmt.Errorf("condition %q requires at least one value", s)
+	}
+
+	if kind == ConditionIP {
+		for _, v := range values {
+			network, err := parseNetwork(v)
+			if err != nil {
+				return c, fmt.Errorf("invalid IP or CIDR %q in condition %q: %w", v, s, err)
+			}
+			c.networks = append(c.networks, network)
+		}
+	}
+
+	return c, nil
+}
+
+// parseNetwork parses either a CIDR or a single IP address into a network.
+func parseNetwork(s string) (net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return net.IPNet{}, err //nolint:wrapcheck // wrapped by the caller
+		}
+		return *network, nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return net.IPNet{}, errors.New("not an IP address")
+	}
+
+	bits := 8 * net.IPv4len
+	if ip.To4() == nil {
+		bits = 8 * net.IPv6len
+	}
+
+	return net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// Matches checks if the request satisfies the condition.
+func (c *Condition) Matches(r *http.Request) bool {
+	switch c.Kind {
+	case ConditionLanguage:
+		return c.matchesLanguage(preferredLanguage(r.Header.Get("Accept-Language")))
+
+	case ConditionCountry:
+		for _, header := range countryHeaders {
+			if country := r.Header.Get(header); country != "" {
+				return c.matchesAnyFold(country)
+			}
+		}
+		return false
+
+	case ConditionHost:
+		return c.matchesHost(requestHost(r))
+
+	case ConditionHeader:
+		values := r.Header.Values(c.Name)
+		if len(c.Values) == 0 {
+			return len(values) > 0
+		}
+
+		for _, v := range values {
+			if c.matchesAny(v) {
+				return true
+			}
+		}
+		return false
+
+	case ConditionCookie:
+		cookie, err := r.Cookie(c.Name)
+		if err != nil {
+			return false
+		}
+		return len(c.Values) == 0 || c.matchesAny(cookie.Value)
+
+	case ConditionIP:
+		ip := clientIP(r)
+		if ip == nil {
+			return false
+		}
+
+		for _, network := range c.networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+
+	default:
+		return false
+	}
+}
+
+// matchesAny checks if the value is one of the condition values.
+func (c *Condition) matchesAny(value string) bool {
+	for _, v := range c.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAnyFold checks if the value is one of the condition values,
+// ignoring case.
+func (c *Condition) matchesAnyFold(value string) bool {
+	for _, v := range c.Values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesLanguage checks if the language tag matches one of the condition
+// values, where a value like "en" matches both "en" and "en-US".
+func (c *Condition) matchesLanguage(tag string) bool {
+	if tag == "" {
+		return false
+	}
+
+	for _, v := range c.Values {
+		v = strings.ToLower(v)
+		if tag == v || strings.HasPrefix(tag, v+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesHost checks if the host matches one of the condition values,
+// where a value like "*.example.com" matches any subdomain of "example.com".
+func (c *Condition) matchesHost(host string) bool {
+	if host == "" {
+		return false
+	}
+
+	for _, v := range c.Values {
+		v = strings.ToLower(v)
+		if suffix, ok := strings.CutPrefix(v, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+
+		if host == v {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredLanguage returns the lowercased language tag the client prefers
+// the most from an "Accept-Language" header, or an empty string.
+func preferredLanguage(header string) string {
+	var (
+		best    string
+		bestQ   = 0.0
+		entries = strings.Split(header, ",")
+	)
+
+	for _, entry := range entries {
+		tag, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		// Only replace on strictly higher preference, so ties
+		// are resolved by the order in which they were sent
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+
+	return best
+}
+
+// requestHost returns the lowercased host of the request, without port.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// clientIP returns the IP address of the client connecting to the server.
+func clientIP(r *http.Request) net.IP {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,205 @@
+package redirects
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConditionalRules(t *testing.T) {
+	rules := `
+		/ /es/ temporary Language=es
+		/ /en/ temporary Language=en
+		/docs/* https://docs.example.com/ permanent Host=old.example.com,*.old.example.com
+		/beta/* /beta-disabled/ temporary Cookie:beta=false
+		/beta/* /beta-enabled/ temporary Cookie:beta
+		/internal/* /internal-only/ temporary IP=10.0.0.0/8,192.168.1.1
+		/api/* /api/v2/ temporary Header:X-Api-Version=2,v2
+		/shop /shop/ca/ temporary Country=ca
+		/shop /shop/us/ temporary Country=us Language=en
+	`
+
+	tests := []struct {
+		name           string
+		path           string
+		headers        map[string]string
+		host           string
+		remoteAddr     string
+		expectLocation string
+	}{
+		{
+			name:           "language - preferred language",
+			path:           "/",
+			headers:        map[string]string{"Accept-Language": "es-MX,en;q=0.8"},
+			expectLocation: "/es/",
+		},
+		{
+			name:           "language - quality values",
+			path:           "/",
+			headers:        map[string]string{"Accept-Language": "es;q=0.5,en-US;q=0.9"},
+			expectLocation: "/en/",
+		},
+		{
+			name:    "language - not preferred",
+			path:    "/",
+			headers: map[string]string{"Accept-Language": "fr,en;q=0.5"},
+		},
+		{
+			name: "language - missing header",
+			path: "/",
+		},
+		{
+			name:           "host - exact",
+			path:           "/docs/intro",
+			host:           "old.example.com:8080",
+			expectLocation: "https://docs.example.com/",
+		},
+		{
+			name:           "host - wildcard subdomain",
+			path:           "/docs/intro",
+			host:           "www.old.example.com",
+			expectLocation: "https://docs.example.com/",
+		},
+		{
+			name: "host - no match",
+			path: "/docs/intro",
+			host: "new.example.com",
+		},
+		{
+			name:           "cookie - with value",
+			path:           "/beta/feature",
+			headers:        map[string]string{"Cookie": "beta=false"},
+			expectLocation: "/beta-disabled/",
+		},
+		{
+			name:           "cookie - presence",
+			path:           "/beta/feature",
+			headers:        map[string]string{"Cookie": "beta=true"},
+			expectLocation: "/beta-enabled/",
+		},
+		{
+			name: "cookie - missing",
+			path: "/beta/feature",
+		},
+		{
+			name:           "ip - within cidr",
+			path:           "/internal/dashboard",
+			remoteAddr:     "10.1.2.3:51234",
+			expectLocation: "/internal-only/",
+		},
+		{
+			name:           "ip - single address",
+			path:           "/internal/dashboard",
+			remoteAddr:     "192.168.1.1:51234",
+			expectLocation: "/internal-only/",
+		},
+		{
+			name:       "ip - outside cidr",
+			path:       "/internal/dashboard",
+			remoteAddr: "172.16.0.1:51234",
+		},
+		{
+			name:           "header - one of many values",
+			path:           "/api/users",
+			headers:        map[string]string{"X-Api-Version": "v2"},
+			expectLocation: "/api/v2/",
+		},
+		{
+			name:    "header - different value",
+			path:    "/api/users",
+			headers: map[string]string{"X-Api-Version": "1"},
+		},
+		{
+			name:           "country - case insensitive",
+			path:           "/shop",
+			headers:        map[string]string{"CF-IPCountry": "CA"},
+			expectLocation: "/shop/ca/",
+		},
+		{
+			name:           "multiple conditions - all match",
+			path:           "/shop",
+			headers:        map[string]string{"X-Country-Code": "US", "Accept-Language": "en-US"},
+			expectLocation: "/shop/us/",
+		},
+		{
+			name:    "multiple conditions - one fails",
+			path:    "/shop",
+			headers: map[string]string{"X-Country-Code": "US", "Accept-Language": "es"},
+		},
+	}
+
+	redirector, err := New(rules)
+	if err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+
+			res, err := redirector.ResolveRequest(req)
+			if tt.expectLocation == "" {
+				if err == nil {
+					t.Fatalf("expecting no rule to match, got rule on line %d", res.Rule.LineNumber)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			if res.Destination != tt.expectLocation {
+				t.Fatalf("expected location %q, got %q", tt.expectLocation, res.Destination)
+			}
+		})
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		condition   string
+		expectError bool
+	}{
+		{condition: "Language=en,es"},
+		{condition: "language=en"},
+		{condition: "Country=us"},
+		{condition: "Host=*.example.com"},
+		{condition: "Header:X-Forwarded-Proto=http"},
+		{condition: "Header:X-Debug"},
+		{condition: "Cookie:session"},
+		{condition: "IP=10.0.0.0/8,::1,2001:db8::/32"},
+		{condition: "Language", expectError: true},
+		{condition: "Language=", expectError: true},
+		{condition: "Language:en=en", expectError: true},
+		{condition: "Header=X-Debug", expectError: true},
+		{condition: "Cookie:=value", expectError: true},
+		{condition: "IP=10.0.0.0/33", expectError: true},
+		{condition: "IP=localhost", expectError: true},
+		{condition: "Role=admin", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			_, err := parseCondition(tt.condition)
+			if tt.expectError && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+
+			if !tt.expectError && err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+		})
+	}
+}
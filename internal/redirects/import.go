@@ -0,0 +1,173 @@
+package redirects
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Formats supported by Import.
+const (
+	FormatNetlify = "netlify"
+	FormatApache  = "apache"
+	FormatNginx   = "nginx"
+)
+
+// ImportFormats lists the formats supported by Import.
+var ImportFormats = []string{FormatNetlify, FormatApache, FormatNginx}
+
+// ImportedRule is a redirection rule converted from another format.
+type ImportedRule struct {
+	Line   int    // Line in the original file
+	Source string // The original line
+	Rule   string // The rule in the redirections syntax
+}
+
+// ImportResult holds the rules converted from another format, as well as
+// the lines that couldn't be converted.
+type ImportResult struct {
+	Rules  []ImportedRule
+	Issues []Issue
+}
+
+// String renders the imported rules as the contents of a redirections
+// file, with the original line as a comment before each of them.
+func (r *ImportResult) String() string {
+	var sb strings.Builder
+	last := 0
+
+	for _, rule := range r.Rules {
+		// Lines converted to multiple rules only get one comment
+		if rule.Line != last {
+			if last != 0 {
+				sb.WriteString("\n")
+			}
+			fmt.Fprintf(&sb, "# line %d: %s\n", rule.Line, strings.ReplaceAll(rule.Source, "\n", " "))
+			last = rule.Line
+		}
+
+		sb.WriteString(rule.Rule)
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// add records the rules converted from a line, skipping those that
+// aren't valid redirection rules.
+func (r *ImportResult) add(line int, source string, rules ...string) {
+	for _, rule := range rules {
+		if _, err := parseRedirectRules(rule); err != nil {
+			r.skip(line, source, fmt.Errorf("converted rule %q is not valid: %w", rule, err))
+			return
+		}
+	}
+
+	for _, rule := range rules {
+		r.Rules = append(r.Rules, ImportedRule{Line: line, Source: source, Rule: rule})
+	}
+}
+
+// skip records a line that couldn't be converted.
+func (r *ImportResult) skip(line int, source string, err error) {
+	r.Issues = append(r.Issues, Issue{
+		Line:    line,
+		Kind:    IssueUntranslatable,
+		Message: fmt.Sprintf("%q: %s", source, err),
+	})
+}
+
+// Import converts redirections written for Netlify, Apache or nginx into
+// redirection rules. Lines that can't be converted are reported as issues
+// instead of failing the whole import.
+func Import(format, content string) (*ImportResult, error) {
+	switch strings.ToLower(format) {
+	case FormatNetlify:
+		return importNetlify(content), nil
+	case FormatApache:
+		return importApache(content), nil
+	case FormatNginx:
+		return importNginx(content)
+	default:
+		return nil, fmt.Errorf("unsupported format %q: must be one of: %s", format, strings.Join(ImportFormats, ", "))
+	}
+}
+
+// statusKeyword returns the status used in a rule for the given
+// redirection or error status code.
+func statusKeyword(code int) (string, error) {
+	switch code {
+	case http.StatusMovedPermanently:
+		return "permanent", nil
+	case http.StatusFound:
+		return "temporary", nil
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect, http.StatusGone, http.StatusUnavailableForLegalReasons:
+		return fmt.Sprintf("%d", code), nil
+	default:
+		return "", fmt.Errorf("status code %d is not supported", code)
+	}
+}
+
+// buildRule joins the parts of a rule, skipping empty ones.
+func buildRule(parts ...string) string {
+	fields := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			fields = append(fields, part)
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// isAbsoluteURL checks if the destination is an HTTP or HTTPS URL.
+func isAbsoluteURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// errUnsupportedFragment is returned for destinations with a fragment,
+// since anything after a "#" is a comment in a redirections file.
+var errUnsupportedFragment = errors.New("destinations with a \"#\" fragment are not supported")
+
+// escapePathPattern escapes a literal path so it can be used as the
+// source of a rule.
+func escapePathPattern(p string) (string, error) {
+	if strings.ContainsAny(p, "*[]#") {
+		return "", fmt.Errorf("path %q contains characters with a special meaning in redirection rules", p)
+	}
+	return strings.ReplaceAll(p, ":", `\:`), nil
+}
+
+// regexLiteralPrefix returns the literal text a regular expression
+// anchored with "^" always starts with.
+func regexLiteralPrefix(re string) string {
+	re, anchored := strings.CutPrefix(re, "^")
+	if !anchored {
+		return ""
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(re); i++ {
+		c := re[i]
+
+		switch {
+		case c == '\\' && i+1 < len(re) && strings.IndexByte(`.+*?()[]{}|^$/\-`, re[i+1]) >= 0:
+			sb.WriteByte(re[i+1])
+			i++
+
+		case strings.IndexByte(`\.+*?()[]{}|^$`, c) >= 0:
+			// A quantifier applies to the previous character,
+			// which is then not part of the literal prefix
+			prefix := sb.String()
+			if strings.IndexByte("*?{", c) >= 0 && prefix != "" {
+				prefix = prefix[:len(prefix)-1]
+			}
+			return prefix
+
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String()
+}
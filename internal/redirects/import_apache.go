@@ -0,0 +1,383 @@
+package redirects
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// apacheBackReference matches "$1" style back-references in Apache
+// substitutions and redirection targets.
+var apacheBackReference = regexp.MustCompile(`\$([0-9])`)
+
+// apacheVariable matches server variables like "%{HTTP_HOST}" and
+// "%1" style back-references to RewriteCond patterns.
+var apacheVariable = regexp.MustCompile(`%(\{|[0-9])`)
+
+// importApache converts mod_alias "Redirect" and "RedirectMatch" lines,
+// as well as mod_rewrite "RewriteRule" lines, from an Apache configuration
+// or ".htaccess" file.
+func importApache(content string) *ImportResult {
+	result := &ImportResult{}
+	base := "/"
+
+	// Rules depending on "RewriteCond" lines can't be converted, and
+	// neither can the conditions themselves
+	var conditionLines []int
+
+	for lineNum, line := range strings.Split(content, "\n") {
+		source := strings.TrimSpace(line)
+		if source == "" || strings.HasPrefix(source, "#") {
+			continue
+		}
+
+		// Sections like "<IfModule mod_rewrite.c>" only wrap directives
+		if strings.HasPrefix(source, "<") {
+			continue
+		}
+
+		args, err := splitApacheArgs(source)
+		if err != nil {
+			result.skip(lineNum+1, source, err)
+			continue
+		}
+
+		directive, args := strings.ToLower(args[0]), args[1:]
+
+		var rules []string
+		switch directive {
+		case "rewriteengine", "rewriteoptions", "options":
+			continue
+
+		case "rewritebase":
+			if len(args) != 1 {
+				err = errors.New("RewriteBase requires a single path")
+				break
+			}
+			base = strings.TrimSuffix(args[0], "/") + "/"
+			continue
+
+		case "rewritecond":
+			conditionLines = append(conditionLines, lineNum+1)
+			err = errors.New("RewriteCond conditions are not supported")
+
+		case "rewriterule":
+			if len(conditionLines) > 0 {
+				err = fmt.Errorf("rule depends on the RewriteCond conditions on lines %s", joinInts(conditionLines))
+				conditionLines = nil
+				break
+			}
+			rules, err = convertApacheRewriteRule(args, base)
+
+		case "redirect", "redirectpermanent", "redirecttemp":
+			rules, err = convertApacheRedirect(directive, args)
+
+		case "redirectmatch":
+			rules, err = convertApacheRedirectMatch(args)
+
+		default:
+			err = fmt.Errorf("directive %q is not supported", directive)
+		}
+
+		if err != nil {
+			result.skip(lineNum+1, source, err)
+			continue
+		}
+
+		result.add(lineNum+1, source, rules...)
+	}
+
+	return result
+}
+
+// splitApacheArgs splits a directive into its arguments, honoring
+// double quotes.
+func splitApacheArgs(line string) ([]string, error) {
+	var args []string
+	var sb strings.Builder
+	inQuotes, hasArg := false, false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case c == '\\' && i+1 < len(line) && line[i+1] == '"':
+			sb.WriteByte('"')
+			hasArg = true
+			i++
+
+		case c == '"':
+			inQuotes = !inQuotes
+			hasArg = true
+
+		case (c == ' ' || c == '\t') && !inQuotes:
+			if hasArg {
+				args = append(args, sb.String())
+				sb.Reset()
+				hasArg = false
+			}
+
+		default:
+			sb.WriteByte(c)
+			hasArg = true
+		}
+	}
+
+	if inQuotes {
+		return nil, errors.New("unterminated quoted argument")
+	}
+
+	if hasArg {
+		args = append(args, sb.String())
+	}
+
+	return args, nil
+}
+
+// apacheRedirectStatus parses the optional status of a mod_alias directive,
+// returning the status code and the remaining arguments.
+func apacheRedirectStatus(args []string, defaultCode int) (int, []string) {
+	if len(args) == 0 {
+		return defaultCode, args
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "permanent":
+		return http.StatusMovedPermanently, args[1:]
+	case "temp":
+		return http.StatusFound, args[1:]
+	case "seeother":
+		return http.StatusSeeOther, args[1:]
+	case "gone":
+		return http.StatusGone, args[1:]
+	}
+
+	if code, err := strconv.Atoi(args[0]); err == nil {
+		return code, args[1:]
+	}
+
+	return defaultCode, args
+}
+
+// convertApacheRedirect converts a "Redirect" directive, which matches
+// a path and everything under it, keeping the rest of the path.
+func convertApacheRedirect(directive string, args []string) ([]string, error) {
+	defaultCode := http.StatusFound
+	if directive == "redirectpermanent" {
+		defaultCode = http.StatusMovedPermanently
+	}
+
+	code, args := apacheRedirectStatus(args, defaultCode)
+	status, err := statusKeyword(code)
+	if err != nil {
+		return nil, err
+	}
+
+	isStatus := code == http.StatusGone || code == http.StatusUnavailableForLegalReasons
+	if len(args) != 2 && !(isStatus && len(args) == 1) {
+		return nil, errors.New("expecting a path and a destination")
+	}
+
+	from, err := escapePathPattern(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	to := "-"
+	if !isStatus {
+		to = args[1]
+	}
+
+	if strings.Contains(to, "#") {
+		return nil, errUnsupportedFragment
+	}
+
+	// The path itself, unless it ends with a slash, and everything
+	// under it, which is appended to the destination
+	var rules []string
+	keepQuery := "?!"
+	if isStatus {
+		keepQuery = ""
+	}
+
+	if !strings.HasSuffix(from, "/") {
+		rules = append(rules, buildRule(from+keepQuery, to, status))
+	}
+
+	splatTo := to
+	if !isStatus {
+		if strings.HasSuffix(from, "/") {
+			splatTo += ":splat"
+		} else {
+			splatTo += "/:splat"
+		}
+	}
+
+	rules = append(rules, buildRule(strings.TrimSuffix(from, "/")+"/:splat"+keepQuery, splatTo, status))
+	return rules, nil
+}
+
+// convertApacheRedirectMatch converts a "RedirectMatch" directive, which
+// matches a regular expression.
+func convertApacheRedirectMatch(args []string) ([]string, error) {
+	code, args := apacheRedirectStatus(args, http.StatusFound)
+	if len(args) == 0 {
+		return nil, errors.New("expecting a regular expression and a destination")
+	}
+
+	status, err := statusKeyword(code)
+	if err != nil {
+		return nil, err
+	}
+
+	to := "-"
+	switch {
+	case code == http.StatusGone || code == http.StatusUnavailableForLegalReasons:
+	case len(args) != 2:
+		return nil, errors.New("expecting a regular expression and a destination")
+	default:
+		to, err = convertApacheSubstitution(args[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return []string{buildRule("~"+args[0], to, status)}, nil
+}
+
+// convertApacheRewriteRule converts a "RewriteRule" directive.
+func convertApacheRewriteRule(args []string, base string) ([]string, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, errors.New("expecting a pattern, a substitution and optional flags")
+	}
+
+	pattern, substitution := args[0], args[1]
+	if strings.HasPrefix(pattern, "!") {
+		return nil, errors.New("negated patterns are not supported")
+	}
+
+	// Patterns in ".htaccess" files are matched without the leading slash
+	if rest, ok := strings.CutPrefix(pattern, "^"); ok && !strings.HasPrefix(rest, "/") {
+		pattern = "^/" + rest
+	}
+
+	var flags []string
+	if len(args) == 3 {
+		flags = strings.Split(strings.Trim(args[2], "[]"), ",")
+	}
+
+	code, action, caseInsensitive, keepQuery := 0, "", false, !strings.Contains(substitution, "?")
+	for _, flag := range flags {
+		name, value, _ := strings.Cut(strings.TrimSpace(flag), "=")
+
+		switch strings.ToUpper(name) {
+		case "R", "REDIRECT":
+			action, code = "redirect", http.StatusFound
+			if value != "" {
+				c, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid redirection status %q", value)
+				}
+				code = c
+			}
+
+		case "P", "PROXY":
+			action = "proxy"
+
+		case "G", "GONE":
+			action, code = "status", http.StatusGone
+
+		case "F", "FORBIDDEN":
+			return nil, errors.New("forbidden responses are not supported")
+
+		case "NC", "NOCASE":
+			caseInsensitive = true
+
+		case "QSA", "QSAPPEND":
+			keepQuery = true
+
+		case "QSD", "QSDISCARD":
+			keepQuery = false
+
+		case "L", "LAST", "END", "NE", "NOESCAPE", "PT", "PASSTHROUGH":
+			// Rules always stop at the first match, and destinations are never escaped
+
+		default:
+			return nil, fmt.Errorf("flag %q is not supported", flag)
+		}
+	}
+
+	from := "~" + pattern
+	if caseInsensitive {
+		from = "~*" + pattern
+	}
+
+	if action == "status" {
+		status, _ := statusKeyword(code)
+		return []string{buildRule(from, "-", status)}, nil
+	}
+
+	if substitution == "-" {
+		return nil, errors.New("rules without a substitution are not supported")
+	}
+
+	to, err := convertApacheSubstitution(substitution)
+	if err != nil {
+		return nil, err
+	}
+
+	// Relative substitutions are relative to the RewriteBase
+	if !strings.HasPrefix(to, "/") && !isAbsoluteURL(to) {
+		to = base + to
+	}
+
+	var status string
+	switch {
+	case action == "proxy":
+		status = "proxy"
+
+	case action == "redirect":
+		if status, err = statusKeyword(code); err != nil {
+			return nil, err
+		}
+
+	case isAbsoluteURL(to):
+		// Substitutions to another server are always redirections
+		status = "temporary"
+
+	default:
+		status = "rewrite"
+	}
+
+	if keepQuery {
+		from += "?!"
+	}
+
+	return []string{buildRule(from, to, status)}, nil
+}
+
+// convertApacheSubstitution converts the back-references in a destination
+// to placeholders, failing on server variables.
+func convertApacheSubstitution(s string) (string, error) {
+	if apacheVariable.MatchString(s) {
+		return "", errors.New("server variables and RewriteCond back-references are not supported")
+	}
+
+	if strings.Contains(s, "#") {
+		return "", errUnsupportedFragment
+	}
+
+	return apacheBackReference.ReplaceAllString(s, ":$1"), nil
+}
+
+// joinInts joins line numbers in a human readable list.
+func joinInts(values []int) string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		s = append(s, strconv.Itoa(v))
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,154 @@
+package redirects
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// importNetlify converts a Netlify "_redirects" file, where each line
+// follows the format "from [query...] to [status][!] [conditions...]".
+func importNetlify(content string) *ImportResult {
+	result := &ImportResult{}
+
+	for lineNum, line := range strings.Split(content, "\n") {
+		source := strings.TrimSpace(line)
+		if source == "" || strings.HasPrefix(source, "#") {
+			continue
+		}
+
+		rule, err := convertNetlifyRule(strings.Fields(source))
+		if err != nil {
+			result.skip(lineNum+1, source, err)
+			continue
+		}
+
+		result.add(lineNum+1, source, rule)
+	}
+
+	return result
+}
+
+// convertNetlifyRule converts the fields of a single Netlify rule.
+func convertNetlifyRule(fields []string) (string, error) {
+	from, rest := fields[0], fields[1:]
+	var conditions []string
+
+	// Rules for a specific domain become a host condition
+	if isAbsoluteURL(from) {
+		u, err := url.Parse(from)
+		if err != nil {
+			return "", fmt.Errorf("invalid source URL %q: %w", from, err)
+		}
+
+		conditions = append(conditions, "Host="+u.Hostname())
+		from = u.EscapedPath()
+		if from == "" {
+			from = "/"
+		}
+	}
+
+	if !strings.HasPrefix(from, "/") {
+		return "", fmt.Errorf("source %q must be a path or an absolute URL", from)
+	}
+
+	// Query parameters to match are listed between the source and the destination
+	var query []string
+	for len(rest) > 0 && !strings.HasPrefix(rest[0], "/") && !isAbsoluteURL(rest[0]) && strings.Contains(rest[0], "=") {
+		query = append(query, rest[0])
+		rest = rest[1:]
+	}
+
+	if len(rest) == 0 {
+		return "", errors.New("missing destination")
+	}
+
+	to, rest := rest[0], rest[1:]
+	if strings.Contains(to, "#") {
+		return "", errUnsupportedFragment
+	}
+
+	// Netlify defaults to permanent redirects, and a "!" forcing the rule
+	// even if a file exists is the default for redirections
+	code := http.StatusMovedPermanently
+	if len(rest) > 0 && rest[0] != "" && rest[0][0] >= '0' && rest[0][0] <= '9' {
+		c, err := strconv.Atoi(strings.TrimSuffix(rest[0], "!"))
+		if err != nil {
+			return "", fmt.Errorf("invalid status code %q", rest[0])
+		}
+		code = c
+		rest = rest[1:]
+	}
+
+	for _, field := range rest {
+		condition, err := convertNetlifyCondition(field)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, condition)
+	}
+
+	status, err := netlifyStatus(code, to)
+	if err != nil {
+		return "", err
+	}
+
+	if len(query) > 0 {
+		from += "?" + strings.Join(query, "&")
+	}
+
+	switch status {
+	case "410", "451":
+		to = "-"
+	default:
+		// Netlify passes querystrings through to the destination
+		from += "?!"
+	}
+
+	return buildRule(append([]string{from, to, status}, conditions...)...), nil
+}
+
+// netlifyStatus returns the rule status for a Netlify status code.
+func netlifyStatus(code int, to string) (string, error) {
+	switch code {
+	case http.StatusOK:
+		if isAbsoluteURL(to) {
+			return "proxy", nil
+		}
+		return "rewrite", nil
+
+	case http.StatusNotFound:
+		return "", errors.New("custom 404 pages are not supported: use --custom-404 instead")
+
+	default:
+		return statusKeyword(code)
+	}
+}
+
+// convertNetlifyCondition converts a Netlify condition, like "Country=us",
+// to its equivalent redirection condition.
+func convertNetlifyCondition(field string) (string, error) {
+	key, value, found := strings.Cut(field, "=")
+	if !found || value == "" {
+		return "", fmt.Errorf("invalid condition %q", field)
+	}
+
+	switch strings.ToLower(key) {
+	case ConditionCountry, ConditionLanguage:
+		return field, nil
+
+	case ConditionCookie:
+		// Netlify matches any of the cookies, while a single rule
+		// can only require the presence of one of them
+		if strings.Contains(value, ",") {
+			return "", fmt.Errorf("condition %q matches multiple cookies, which is not supported", field)
+		}
+		return "Cookie:" + value, nil
+
+	default:
+		return "", fmt.Errorf("condition %q is not supported", field)
+	}
+}
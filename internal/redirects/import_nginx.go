@@ -0,0 +1,508 @@
+package redirects
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// nginxVariable matches variables and captures in nginx destinations,
+// like "$1", "$uri" or "${name}".
+var nginxVariable = regexp.MustCompile(`\$(\{[A-Za-z0-9_]+\}|[A-Za-z0-9_]+)`)
+
+// nginxNamedGroup matches named groups in nginx regular expressions.
+var nginxNamedGroup = regexp.MustCompile(`\(\?P?<([A-Za-z][A-Za-z0-9_]*)>`)
+
+// nginxDirective is a single directive in an nginx configuration file,
+// with the directives in its block, if any.
+type nginxDirective struct {
+	line     int
+	args     []string
+	block    []*nginxDirective
+	hasBlock bool
+}
+
+// String renders the directive as written in the configuration.
+func (d *nginxDirective) String() string {
+	if d.hasBlock {
+		return strings.Join(d.args, " ") + " { ... }"
+	}
+	return strings.Join(d.args, " ") + ";"
+}
+
+// nginxLocation is the "location" block a directive is in.
+type nginxLocation struct {
+	modifier string // One of "", "=", "^~", "~" or "~*"
+	path     string
+}
+
+// nginxContext holds what applies to a directive from its parent blocks.
+type nginxContext struct {
+	hosts    []string
+	location *nginxLocation
+	inIf     bool
+}
+
+// importNginx converts "rewrite" and "return" directives from an nginx
+// configuration file. Other directives are ignored, since configuration
+// files usually contain much more than redirections.
+func importNginx(content string) (*ImportResult, error) {
+	directives, err := parseNginx(content)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	convertNginxDirectives(result, directives, nginxContext{})
+	return result, nil
+}
+
+// convertNginxDirectives converts the directives in a block, recursing
+// into nested blocks.
+func convertNginxDirectives(result *ImportResult, directives []*nginxDirective, ctx nginxContext) {
+	for _, d := range directives {
+		name := d.args[0]
+
+		switch {
+		case name == "server" && d.hasBlock:
+			inner := ctx
+			inner.hosts = nginxServerNames(d.block)
+			convertNginxDirectives(result, d.block, inner)
+
+		case name == "location" && d.hasBlock:
+			location, err := parseNginxLocation(d.args[1:])
+			if err != nil {
+				result.skip(d.line, d.String(), err)
+				continue
+			}
+
+			inner := ctx
+			inner.location = location
+			convertNginxDirectives(result, d.block, inner)
+
+		case name == "if" && d.hasBlock:
+			inner := ctx
+			inner.inIf = true
+			convertNginxDirectives(result, d.block, inner)
+
+		case d.hasBlock:
+			convertNginxDirectives(result, d.block, ctx)
+
+		case name == "rewrite" || name == "return":
+			if ctx.inIf {
+				result.skip(d.line, d.String(), errors.New("directives inside \"if\" blocks are not supported"))
+				continue
+			}
+
+			var rules []string
+			var err error
+			if name == "rewrite" {
+				rules, err = convertNginxRewrite(d.args[1:], ctx)
+			} else {
+				rules, err = convertNginxReturn(d.args[1:], ctx)
+			}
+
+			if err != nil {
+				result.skip(d.line, d.String(), err)
+				continue
+			}
+
+			result.add(d.line, d.String(), rules...)
+		}
+	}
+}
+
+// nginxServerNames returns the host names of a "server" block usable in
+// a host condition, skipping the catch-all "_" and regular expressions.
+func nginxServerNames(block []*nginxDirective) []string {
+	var hosts []string
+	for _, d := range block {
+		if d.args[0] != "server_name" || d.hasBlock {
+			continue
+		}
+
+		for _, host := range d.args[1:] {
+			if host == "_" || host == "" || strings.HasPrefix(host, "~") {
+				continue
+			}
+			hosts = append(hosts, strings.TrimPrefix(host, "."))
+		}
+	}
+	return hosts
+}
+
+// parseNginxLocation parses the arguments of a "location" block.
+func parseNginxLocation(args []string) (*nginxLocation, error) {
+	switch {
+	case len(args) == 1 && strings.HasPrefix(args[0], "@"):
+		return nil, errors.New("named locations are not supported")
+
+	case len(args) == 1:
+		return &nginxLocation{path: args[0]}, nil
+
+	case len(args) == 2:
+		switch args[0] {
+		case "=", "^~", "~", "~*":
+			return &nginxLocation{modifier: args[0], path: args[1]}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported location %q", strings.Join(args, " "))
+}
+
+// conditions returns the host condition for the rules in a server block.
+func (ctx nginxContext) conditions() []string {
+	if len(ctx.hosts) == 0 {
+		return nil
+	}
+	return []string{"Host=" + strings.Join(ctx.hosts, ",")}
+}
+
+// convertNginxRewrite converts a "rewrite regex replacement [flag]" directive.
+func convertNginxRewrite(args []string, ctx nginxContext) ([]string, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, errors.New("expecting a regular expression, a replacement and an optional flag")
+	}
+
+	pattern, replacement := args[0], args[1]
+
+	// A rewrite inside a location only applies to requests in that location,
+	// so the regular expression must not match anything outside of it
+	if ctx.location != nil && ctx.location.path != "/" {
+		if ctx.location.modifier == "~" || ctx.location.modifier == "~*" || !strings.HasPrefix(regexLiteralPrefix(pattern), ctx.location.path) {
+			return nil, fmt.Errorf("rewrite may match requests outside of location %q", ctx.location.path)
+		}
+	}
+
+	status := ""
+	if len(args) == 3 {
+		switch args[2] {
+		case "last", "break":
+			status = "rewrite"
+		case "redirect":
+			status = "temporary"
+		case "permanent":
+			status = "permanent"
+		default:
+			return nil, fmt.Errorf("unsupported flag %q", args[2])
+		}
+	}
+
+	// A replacement ending with "?" drops the original querystring
+	keepQuery := "?!"
+	if r, ok := strings.CutSuffix(replacement, "?"); ok {
+		replacement, keepQuery = r, ""
+	}
+
+	to, err := convertNginxDestination(replacement, pattern, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if status == "" {
+		// Replacements to another server are always redirections
+		status = "rewrite"
+		if isAbsoluteURL(to) {
+			status = "temporary"
+		}
+	}
+
+	return []string{buildRule(append([]string{"~" + pattern + keepQuery, to, status}, ctx.conditions()...)...)}, nil
+}
+
+// convertNginxReturn converts a "return code [URL]" directive, which
+// applies to every request in its location or server block.
+func convertNginxReturn(args []string, ctx nginxContext) ([]string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return nil, errors.New("expecting a status code and an optional URL")
+	}
+
+	// "return URL" is a temporary redirection
+	if len(args) == 1 && (isAbsoluteURL(args[0]) || strings.HasPrefix(args[0], "$scheme")) {
+		args = []string{strconv.Itoa(http.StatusFound), args[0]}
+	}
+
+	code, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid status code %q", args[0])
+	}
+
+	status, err := statusKeyword(code)
+	if err != nil {
+		return nil, err
+	}
+
+	isStatus := code == http.StatusGone || code == http.StatusUnavailableForLegalReasons
+	if !isStatus && len(args) != 2 {
+		return nil, errors.New("expecting a URL to redirect to")
+	}
+
+	location := ctx.location
+	if location == nil {
+		location = &nginxLocation{path: "/"}
+	}
+
+	var rules []string
+	for _, source := range nginxLocationSources(location) {
+		to := "-"
+		from := source.pattern
+
+		if !isStatus {
+			to, err = convertNginxDestination(args[1], location.regex(), source.path)
+			if err != nil {
+				return nil, err
+			}
+
+			// The original querystring is only kept if it's used
+			if strings.Contains(args[1], "$request_uri") || strings.Contains(args[1], "$args") || strings.Contains(args[1], "$query_string") || strings.Contains(args[1], "$is_args") {
+				from += "?!"
+			}
+		}
+
+		rules = append(rules, buildRule(append([]string{from, to, status}, ctx.conditions()...)...))
+	}
+
+	return rules, nil
+}
+
+// nginxSource is a rule source equivalent to a location, alongside the
+// value of the request path in the destination.
+type nginxSource struct {
+	pattern string
+	path    string
+}
+
+// regex returns the regular expression of the location, if any.
+func (l *nginxLocation) regex() string {
+	if l.modifier == "~" || l.modifier == "~*" {
+		return l.path
+	}
+	return ""
+}
+
+// nginxLocationSources returns the rule sources matching the same requests
+// as the location. Prefix locations not ending in a slash are converted to
+// match the path itself and the paths under it.
+func nginxLocationSources(l *nginxLocation) []nginxSource {
+	switch l.modifier {
+	case "~", "~*":
+		return []nginxSource{{pattern: l.modifier + l.path}}
+	}
+
+	escaped, err := escapePathPattern(l.path)
+	if err != nil {
+		// Use the location as a regular expression instead
+		return []nginxSource{{pattern: "~^" + regexp.QuoteMeta(l.path)}}
+	}
+
+	if l.modifier == "=" {
+		return []nginxSource{{pattern: escaped, path: escaped}}
+	}
+
+	if strings.HasSuffix(escaped, "/") {
+		return []nginxSource{{pattern: escaped + ":splat", path: escaped + ":splat"}}
+	}
+
+	return []nginxSource{
+		{pattern: escaped, path: escaped},
+		{pattern: escaped + "/:splat", path: escaped + "/:splat"},
+	}
+}
+
+// convertNginxDestination converts the captures and variables in a
+// destination to placeholders. Only captures from the regular expression,
+// and the request path when it's known, are supported.
+func convertNginxDestination(s, pattern, requestPath string) (string, error) {
+	if strings.Contains(s, "#") {
+		return "", errUnsupportedFragment
+	}
+
+	named := make(map[string]bool)
+	for _, m := range nginxNamedGroup.FindAllStringSubmatch(pattern, -1) {
+		named[m[1]] = true
+	}
+
+	var unsupported []string
+	converted := nginxVariable.ReplaceAllStringFunc(s, func(v string) string {
+		name := strings.Trim(v[1:], "{}")
+
+		switch {
+		case pattern != "" && (named[name] || isDigits(name)):
+			return ":" + name
+
+		case requestPath != "" && (name == "uri" || name == "request_uri"):
+			return requestPath
+
+		case requestPath != "" && (name == "args" || name == "query_string" || name == "is_args"):
+			// The querystring is kept by the rule itself
+			return ""
+
+		default:
+			unsupported = append(unsupported, v)
+			return v
+		}
+	})
+
+	if len(unsupported) > 0 {
+		return "", fmt.Errorf("unsupported variables: %s", strings.Join(unsupported, ", "))
+	}
+
+	return converted, nil
+}
+
+// isDigits checks if the string is a non-empty sequence of digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseNginx parses an nginx configuration file into its directives.
+func parseNginx(content string) ([]*nginxDirective, error) {
+	tokens, err := tokenizeNginx(content)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &nginxDirective{hasBlock: true}
+	stack := []*nginxDirective{root}
+	var current *nginxDirective
+
+	for _, tok := range tokens {
+		parent := stack[len(stack)-1]
+
+		switch {
+		case tok.special && tok.value == ";":
+			if current == nil {
+				continue
+			}
+			parent.block = append(parent.block, current)
+			current = nil
+
+		case tok.special && tok.value == "{":
+			if current == nil {
+				return nil, fmt.Errorf("unexpected \"{\" on line %d", tok.line)
+			}
+			current.hasBlock = true
+			parent.block = append(parent.block, current)
+			stack = append(stack, current)
+			current = nil
+
+		case tok.special && tok.value == "}":
+			if current != nil {
+				return nil, fmt.Errorf("missing \";\" before line %d", tok.line)
+			}
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("unexpected \"}\" on line %d", tok.line)
+			}
+			stack = stack[:len(stack)-1]
+
+		default:
+			if current == nil {
+				current = &nginxDirective{line: tok.line}
+			}
+			current.args = append(current.args, tok.value)
+		}
+	}
+
+	if current != nil {
+		return nil, fmt.Errorf("missing \";\" after the directive on line %d", current.line)
+	}
+
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("missing \"}\" for the block on line %d", stack[len(stack)-1].line)
+	}
+
+	return root.block, nil
+}
+
+// nginxToken is a single word, quoted string or one of ";", "{" or "}".
+type nginxToken struct {
+	value   string
+	line    int
+	special bool
+}
+
+// tokenizeNginx splits an nginx configuration file into tokens, removing
+// comments and quotes.
+func tokenizeNginx(content string) ([]nginxToken, error) {
+	var tokens []nginxToken
+	var sb strings.Builder
+	line, start := 1, 1
+	var quote byte
+	hasToken := false
+
+	flush := func() {
+		if hasToken {
+			tokens = append(tokens, nginxToken{value: sb.String(), line: start})
+			sb.Reset()
+			hasToken = false
+		}
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+
+		if c == '\n' {
+			line++
+		}
+
+		switch {
+		case quote != 0:
+			switch {
+			case c == '\\' && i+1 < len(content) && content[i+1] == quote:
+				sb.WriteByte(quote)
+				i++
+			case c == quote:
+				quote = 0
+			default:
+				sb.WriteByte(c)
+			}
+
+		case c == '"' || c == '\'':
+			if !hasToken {
+				start = line
+			}
+			quote = c
+			hasToken = true
+
+		case c == '#':
+			flush()
+			for i+1 < len(content) && content[i+1] != '\n' {
+				i++
+			}
+
+		case c == ';' || c == '{' || c == '}':
+			flush()
+			tokens = append(tokens, nginxToken{value: string(c), line: line, special: true})
+
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+
+		default:
+			if !hasToken {
+				start = line
+			}
+			sb.WriteByte(c)
+			hasToken = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quoted string")
+	}
+
+	flush()
+	return tokens, nil
+}
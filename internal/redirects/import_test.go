@@ -0,0 +1,239 @@
+package redirects
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		content     string
+		expectRules []string
+		expectLines []int // Lines reported as untranslatable
+		expectError bool
+	}{
+		{
+			name:        "unsupported format",
+			format:      "caddy",
+			expectError: true,
+		},
+		{
+			name:   "netlify - redirects",
+			format: FormatNetlify,
+			content: `
+				# comments are ignored
+				/old /new
+				/blog/* /news/:splat 302
+				/store id=:id /products/:id 301
+				/legal /terms 307!`,
+			expectRules: []string{
+				"/old?! /new permanent",
+				"/blog/*?! /news/:splat temporary",
+				"/store?id=:id?! /products/:id permanent",
+				"/legal?! /terms 307",
+			},
+		},
+		{
+			name:   "netlify - rewrites, proxies and status codes",
+			format: FormatNetlify,
+			content: `
+				/app/* /index.html 200
+				/api/* https://api.example.com/:splat 200
+				/removed /nothing 410`,
+			expectRules: []string{
+				"/app/*?! /index.html rewrite",
+				"/api/*?! https://api.example.com/:splat proxy",
+				"/removed - 410",
+			},
+		},
+		{
+			name:   "netlify - conditions and domains",
+			format: FormatNetlify,
+			content: `
+				/ /es/ 302 Language=es Country=es,mx
+				/beta/* /beta-app/index.html 200 Cookie=beta
+				https://old.example.com/* https://example.com/:splat 301!`,
+			expectRules: []string{
+				"/?! /es/ temporary Language=es Country=es,mx",
+				"/beta/*?! /beta-app/index.html rewrite Cookie:beta",
+				"/*?! https://example.com/:splat permanent Host=old.example.com",
+			},
+		},
+		{
+			name:   "netlify - untranslatable lines",
+			format: FormatNetlify,
+			content: `
+				/admin/* /login 302 Role=admin
+				/missing /404.html 404
+				/ok /fine
+				/nowhere
+				/both /cookies 302 Cookie=a,b
+				/fragment /page#section 301`,
+			expectRules: []string{"/ok?! /fine permanent"},
+			expectLines: []int{2, 3, 5, 6, 7},
+		},
+		{
+			name:   "apache - mod_alias",
+			format: FormatApache,
+			content: `
+				Redirect 301 /old-page.html /new-page.html
+				Redirect /docs/ https://docs.example.com/
+				RedirectPermanent /team /about
+				Redirect gone /removed
+				RedirectMatch 301 ^/blog/(\d+)/(.*)$ /posts/$2?year=$1`,
+			expectRules: []string{
+				"/old-page.html?! /new-page.html permanent",
+				"/old-page.html/:splat?! /new-page.html/:splat permanent",
+				"/docs/:splat?! https://docs.example.com/:splat temporary",
+				"/team?! /about permanent",
+				"/team/:splat?! /about/:splat permanent",
+				"/removed - 410",
+				"/removed/:splat - 410",
+				"~^/blog/(\\d+)/(.*)$ /posts/:2?year=:1 permanent",
+			},
+		},
+		{
+			name:   "apache - mod_rewrite",
+			format: FormatApache,
+			content: `
+				<IfModule mod_rewrite.c>
+				RewriteEngine On
+				RewriteBase /site/
+				RewriteRule ^about-us/?$ about [R=301,L,NC]
+				RewriteRule ^app/(.*)$ /index.html?app=1 [L,QSA]
+				RewriteRule ^feed$ /rss.xml?format=rss [L]
+				RewriteRule ^api/(.*)$ https://api.example.com/$1 [P]
+				RewriteRule ^shop/(.*)$ https://shop.example.com/$1
+				RewriteRule ^old$ - [G]
+				</IfModule>`,
+			expectRules: []string{
+				"~*^/about-us/?$?! /site/about permanent",
+				"~^/app/(.*)$?! /index.html?app=1 rewrite",
+				"~^/feed$ /rss.xml?format=rss rewrite",
+				"~^/api/(.*)$?! https://api.example.com/:1 proxy",
+				"~^/shop/(.*)$?! https://shop.example.com/:1 temporary",
+				"~^/old$ - 410",
+			},
+		},
+		{
+			name:   "apache - untranslatable lines",
+			format: FormatApache,
+			content: `
+				RewriteCond %{HTTP_HOST} ^www\.example\.com$ [NC]
+				RewriteRule ^(.*)$ https://example.com/$1 [R=301,L]
+				RewriteRule ^secret$ - [F]
+				RewriteRule ^page$ /page.php?host=%{HTTP_HOST} [L]
+				RewriteRule !^public/ /login [R]
+				RewriteRule ^lookahead/(?!x)$ /y [R]
+				ErrorDocument 404 /404.html
+				Redirect 303 /other /place
+				Redirect /ok /fine`,
+			expectRules: []string{
+				"/ok?! /fine temporary",
+				"/ok/:splat?! /fine/:splat temporary",
+			},
+			expectLines: []int{2, 3, 4, 5, 6, 7, 8, 9},
+		},
+		{
+			name:   "nginx - server and location blocks",
+			format: FormatNginx,
+			content: `
+				server {
+					listen 80;
+					server_name old.example.com www.old.example.com;
+					return 301 https://example.com$request_uri;
+				}
+
+				server {
+					server_name example.com;
+					root /srv/www; # comments are ignored
+
+					rewrite ^/blog/(\d+)/(?<slug>.+)$ /posts/$slug?year=$1 permanent;
+					rewrite ^/feed$ /rss.xml? redirect;
+					rewrite "^/app/.*$" /index.html last;
+
+					location = /legacy { return 301 /modern; }
+					location /docs/ {
+						rewrite ^/docs/v1/(.*)$ /docs/v2/$1 permanent;
+					}
+					location /gone { return 410; }
+					location ~ ^/user/(\d+)$ { return 302 /profile/$1; }
+				}`,
+			expectRules: []string{
+				"/:splat?! https://example.com/:splat permanent Host=old.example.com,www.old.example.com",
+				"~^/blog/(\\d+)/(?<slug>.+)$?! /posts/:slug?year=:1 permanent Host=example.com",
+				"~^/feed$ /rss.xml temporary Host=example.com",
+				"~^/app/.*$?! /index.html rewrite Host=example.com",
+				"/legacy /modern permanent Host=example.com",
+				"~^/docs/v1/(.*)$?! /docs/v2/:1 permanent Host=example.com",
+				"/gone - 410 Host=example.com",
+				"/gone/:splat - 410 Host=example.com",
+				"~^/user/(\\d+)$ /profile/:1 temporary Host=example.com",
+			},
+		},
+		{
+			name:   "nginx - untranslatable directives",
+			format: FormatNginx,
+			content: `
+				location /docs/ {
+					rewrite ^(.*)$ /other permanent;
+				}
+				location ~* \.php$ { return 404; }
+				location /x {
+					if ($http_user_agent ~ bot) { return 403; }
+					return 301 $scheme://$host/y;
+				}
+				location @fallback { return 302 /; }
+				return 301 /everything;`,
+			expectRules: []string{"/:splat /everything permanent"},
+			expectLines: []int{3, 5, 7, 8, 10},
+		},
+		{
+			name:        "nginx - invalid configuration",
+			format:      FormatNginx,
+			content:     "server { return 301 /new;",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Import(tt.format, tt.content)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("expecting error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			rules := make([]string, 0, len(result.Rules))
+			for _, rule := range result.Rules {
+				rules = append(rules, rule.Rule)
+			}
+
+			if !slices.Equal(rules, tt.expectRules) {
+				t.Fatalf("expected rules:\n%q\ngot:\n%q", tt.expectRules, rules)
+			}
+
+			lines := make([]int, 0, len(result.Issues))
+			for _, issue := range result.Issues {
+				lines = append(lines, issue.Line)
+			}
+
+			if !slices.Equal(lines, tt.expectLines) && (len(lines) > 0 || len(tt.expectLines) > 0) {
+				t.Fatalf("expected untranslatable lines %v, got: %v", tt.expectLines, result.Issues)
+			}
+
+			// The imported rules must be usable as-is
+			if _, err := New(result.String()); err != nil {
+				t.Fatalf("imported rules are not valid: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package redirects
+
+import (
+	"slices"
+	"strings"
+)
+
+// ruleIndex is a trie of path segments used to narrow down which rules
+// could match a request path. Each rule is stored under the literal
+// segments at the start of its pattern, so a request only needs to be
+// checked against the rules found while walking its own path.
+type ruleIndex struct {
+	exact  *indexNode // Rules matched respecting case
+	folded *indexNode // Case insensitive rules, stored by lowercase segments
+}
+
+// indexNode is a single segment in the trie.
+type indexNode struct {
+	children map[string]*indexNode
+	rules    []int // Positions of the rules whose literal prefix ends here
+}
+
+// newRuleIndex builds the trie for the given rules.
+func newRuleIndex(rules []RedirectRule) *ruleIndex {
+	idx := &ruleIndex{exact: &indexNode{}}
+
+	for i := range rules {
+		rule := &rules[i]
+
+		// Regular expressions can match anything, so they're
+		// checked for every request
+		if rule.regex != nil {
+			idx.exact.rules = append(idx.exact.rules, i)
+			continue
+		}
+
+		root := idx.exact
+		if rule.CaseInsensitive {
+			if idx.folded == nil {
+				idx.folded = &indexNode{}
+			}
+			root = idx.folded
+		}
+
+		node := root
+		for _, segment := range literalPrefix(rule) {
+			if rule.CaseInsensitive {
+				segment = strings.ToLower(segment)
+			}
+			node = node.child(segment)
+		}
+		node.rules = append(node.rules, i)
+	}
+
+	return idx
+}
+
+// literalPrefix returns the segments at the start of the rule pattern
+// that must be matched literally, stopping at the first placeholder,
+// wildcard or optional segment.
+func literalPrefix(rule *RedirectRule) []string {
+	segments := rule.segments
+	if segments == nil {
+		segments = compilePathPattern(rule.FromPath)
+	}
+
+	for i, segment := range segments {
+		if _, ok := optionalSegment(segment); ok || strings.HasPrefix(segment, ":") || strings.Contains(segment, "*") {
+			return segments[:i]
+		}
+	}
+
+	return segments
+}
+
+// child returns the child node for the segment, creating it if needed.
+func (n *indexNode) child(segment string) *indexNode {
+	if n.children == nil {
+		n.children = make(map[string]*indexNode)
+	}
+
+	c, ok := n.children[segment]
+	if !ok {
+		c = &indexNode{}
+		n.children[segment] = c
+	}
+
+	return c
+}
+
+// candidates returns the positions of the rules that could match the
+// path segments, sorted so the first rule defined is checked first.
+func (idx *ruleIndex) candidates(pathSegments []string) []int {
+	var found []int
+	found = idx.exact.collect(pathSegments, false, found)
+	if idx.folded != nil {
+		found = idx.folded.collect(pathSegments, true, found)
+	}
+
+	slices.Sort(found)
+	return found
+}
+
+// collect appends the rules of every node visited while walking the path
+// segments down from this node.
+func (n *indexNode) collect(pathSegments []string, caseInsensitive bool, found []int) []int {
+	node := n
+	found = append(found, node.rules...)
+
+	for _, segment := range pathSegments {
+		if caseInsensitive {
+			segment = strings.ToLower(segment)
+		}
+
+		next, ok := node.children[segment]
+		if !ok {
+			break
+		}
+
+		node = next
+		found = append(found, node.rules...)
+	}
+
+	return found
+}
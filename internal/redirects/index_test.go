@@ -0,0 +1,74 @@
+package redirects
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRuleIndexKeepsFirstMatchWins(t *testing.T) {
+	rules := `
+		/* /maintenance temporary Header:X-Maintenance=on
+		~^/legacy/(.+)$ /archive/:1 permanent
+		/docs/:page /documentation/:page permanent
+		/docs/intro /getting-started permanent
+		/DOCS/Setup /setup permanent nocase
+		/docs/setup/advanced /advanced permanent
+		/blog/*.html /blog temporary
+		/blog/[posts]/:slug /articles/:slug permanent
+		/[:lang]/about /about/:lang permanent
+		/en/about /english permanent
+		/* /fallback temporary`
+
+	engine, err := New(rules)
+	if err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	// The same engine without the index checks every rule in order
+	linear := &Engine{Rules: engine.Rules}
+
+	paths := []string{
+		"/",
+		"/docs/intro",
+		"/docs/setup",
+		"/Docs/SETUP",
+		"/docs/setup/advanced",
+		"/DOCS/setup/advanced",
+		"/legacy/docs/intro",
+		"/blog/hello.html",
+		"/blog/posts/hello",
+		"/blog/hello",
+		"/en/about",
+		"/about",
+		"/unknown/path",
+	}
+
+	for _, p := range paths {
+		for _, maintenance := range []bool{false, true} {
+			req, err := http.NewRequest(http.MethodGet, p, nil)
+			if err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			if maintenance {
+				req.Header.Set("X-Maintenance", "on")
+			}
+
+			want, wantErr := linear.ResolveRequest(req)
+			got, gotErr := engine.ResolveRequest(req)
+
+			if !errors.Is(gotErr, wantErr) {
+				t.Fatalf("path %q: expected error %v, got %v", p, wantErr, gotErr)
+			}
+
+			if wantErr != nil {
+				continue
+			}
+
+			if got.Rule.LineNumber != want.Rule.LineNumber || got.Destination != want.Destination {
+				t.Fatalf("path %q: expected rule on line %d to %q, got rule on line %d to %q", p, want.Rule.LineNumber, want.Destination, got.Rule.LineNumber, got.Destination)
+			}
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package redirects
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// maxRedirectHops is the maximum amount of redirections followed when
+// looking for chains and loops.
+const maxRedirectHops = 10
+
+// samplePlaceholder is the value used for placeholders and splats when
+// generating sample requests for a rule.
+const samplePlaceholder = "sample"
+
+// Kinds of issues reported by Lint and Import.
+const (
+	IssueShadowed       = "shadowed"
+	IssueLoop           = "loop"
+	IssueChain          = "chain"
+	IssueUntranslatable = "untranslatable"
+)
+
+// Issue is a potential problem found in the redirection rules.
+type Issue struct {
+	Line    int    // Line of the rule with the issue
+	Kind    string // One of the Issue* constants
+	Message string
+}
+
+// String implements the fmt.Stringer interface.
+func (i Issue) String() string {
+	return fmt.Sprintf("line %d: %s: %s", i.Line, i.Kind, i.Message)
+}
+
+// Lint looks for rules that can never be reached because a previous rule
+// always matches first, as well as redirections that lead to another
+// redirection (chains) or back to themselves (loops). Rules with
+// conditions are only analyzed for shadowing, since whether they
+// chain depends on the request.
+func (e *Engine) Lint() []Issue {
+	issues := make([]Issue, 0)
+
+	for j := range e.Rules {
+		for i := 0; i < j; i++ {
+			if e.Rules[i].covers(&e.Rules[j]) {
+				issues = append(issues, Issue{
+					Line:    e.Rules[j].LineNumber,
+					Kind:    IssueShadowed,
+					Message: fmt.Sprintf("rule is unreachable: every request it matches is handled first by the rule on line %d", e.Rules[i].LineNumber),
+				})
+				break
+			}
+		}
+	}
+
+	for i := range e.Rules {
+		if issue, found := e.followRedirects(&e.Rules[i]); found {
+			issues = append(issues, issue)
+		}
+	}
+
+	sort.SliceStable(issues, func(a, b int) bool { return issues[a].Line < issues[b].Line })
+	return issues
+}
+
+// followRedirects follows the redirections starting at the given rule,
+// reporting whether they end up in a loop or a chain of redirections.
+func (e *Engine) followRedirects(rule *RedirectRule) (Issue, bool) {
+	// There's no reliable way to generate a sample request for a regular
+	// expression, so those rules are only analyzed as destinations
+	if rule.Action != ActionRedirect || len(rule.Conditions) > 0 || rule.regex != nil {
+		return Issue{}, false
+	}
+
+	sample := rule.sampleRequestURI()
+	u, err := url.ParseRequestURI(sample)
+	if err != nil {
+		return Issue{}, false
+	}
+
+	params, ok := rule.Match(u.Path, u.RawQuery)
+	if !ok {
+		return Issue{}, false
+	}
+
+	destination := rule.buildDestination(params, u.RawQuery, rule.KeepQueryParams)
+	hops := []string{fmt.Sprintf("%s (line %d)", sample, rule.LineNumber)}
+	visited := map[*RedirectRule]bool{rule: true}
+
+	for len(hops) <= maxRedirectHops {
+		// Only local destinations can be followed
+		if !strings.HasPrefix(destination, "/") || strings.HasPrefix(destination, "//") {
+			break
+		}
+
+		res, err := e.Resolve(destination)
+		if err != nil || res.Rule.Action != ActionRedirect {
+			break
+		}
+
+		hops = append(hops, fmt.Sprintf("%s (line %d)", destination, res.Rule.LineNumber))
+
+		if visited[res.Rule] {
+			return Issue{
+				Line:    rule.LineNumber,
+				Kind:    IssueLoop,
+				Message: "redirections never end: " + strings.Join(hops, " -> "),
+			}, true
+		}
+
+		visited[res.Rule] = true
+		destination = res.Destination
+	}
+
+	if len(hops) > 1 {
+		return Issue{
+			Line:    rule.LineNumber,
+			Kind:    IssueChain,
+			Message: fmt.Sprintf("request is redirected %d times: %s -> %s", len(hops), strings.Join(hops, " -> "), destination),
+		}, true
+	}
+
+	return Issue{}, false
+}
+
+// sampleRequestURI generates a request URI matching the rule, using
+// sample values for placeholders and splats.
+func (rule *RedirectRule) sampleRequestURI() string {
+	segments := splitPathSegments(rule.FromPath)
+	for i, segment := range segments {
+		if inner, ok := optionalSegment(segment); ok {
+			segment = inner
+		}
+
+		if segment == "*" || strings.HasPrefix(segment, ":") {
+			segments[i] = samplePlaceholder
+			continue
+		}
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(segment, "*", samplePlaceholder), colonPlaceholder, ":")
+	}
+
+	sample := strings.Join(segments, "/")
+	if len(rule.FromParams) == 0 {
+		return sample
+	}
+
+	keys := make([]string, 0, len(rule.FromParams))
+	for key := range rule.FromParams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	query := make(url.Values, len(keys))
+	for _, key := range keys {
+		value := strings.ReplaceAll(rule.FromParams[key], colonPlaceholder, ":")
+		if strings.HasPrefix(value, ":") {
+			value = samplePlaceholder
+		}
+		query.Set(strings.ReplaceAll(key, colonPlaceholder, ":"), value)
+	}
+
+	return sample + "?" + query.Encode()
+}
+
+// covers reports whether every request matching the other rule is also
+// matched by this rule.
+func (rule *RedirectRule) covers(other *RedirectRule) bool {
+	// Rewrites might be skipped if the file exists, so they
+	// can't be assumed to always match first
+	if rule.Action == ActionRewrite {
+		return false
+	}
+
+	// Regular expressions can't be compared, and a case sensitive rule
+	// never covers a case insensitive one
+	if rule.regex != nil || other.regex != nil || (other.CaseInsensitive && !rule.CaseInsensitive) {
+		return false
+	}
+
+	// Every condition of this rule must also be required by the other rule
+	for _, c := range rule.Conditions {
+		found := false
+		for _, oc := range other.Conditions {
+			if c.String() == oc.String() {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	// Every query parameter of this rule must be required by the other rule
+	// with either the same literal value or any value for a placeholder
+	for key, value := range rule.FromParams {
+		otherValue, ok := other.FromParams[key]
+		if !ok {
+			return false
+		}
+
+		if !strings.HasPrefix(value, ":") && value != otherValue {
+			return false
+		}
+	}
+
+	return pathCovers(splitPathSegments(rule.FromPath), splitPathSegments(other.FromPath))
+}
+
+// pathCovers reports whether every path matched by the pattern segments
+// in other is also matched by the pattern segments in pattern.
+func pathCovers(pattern, other []string) bool {
+	for i, segment := range pattern {
+		if i >= len(other) {
+			return false
+		}
+
+		otherSegment := other[i]
+		isLast := i == len(pattern)-1
+		otherIsSplat := i == len(other)-1 && (otherSegment == "*" || otherSegment == ":splat")
+		_, otherIsOptional := optionalSegment(otherSegment)
+
+		switch {
+		case isLast && (segment == "*" || segment == ":splat"):
+			// Splats match one or more remaining segments
+			return true
+
+		case otherIsSplat:
+			// A single segment can't cover a splat
+			return false
+
+		case segment == otherSegment:
+			// Identical segments, including optional ones and wildcards
+
+		case otherIsOptional, isGlobSegment(otherSegment):
+			// Only an identical segment is known to cover these
+			return false
+
+		case segment == "*" || strings.HasPrefix(segment, ":"):
+			// Placeholders and wildcards match any non-empty segment
+			if otherSegment == "" {
+				return false
+			}
+
+		default:
+			// Different literals, optional segments and wildcards
+			// within a segment only cover identical segments
+			return false
+		}
+	}
+
+	return len(pattern) == len(other)
+}
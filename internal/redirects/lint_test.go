@@ -0,0 +1,149 @@
+package redirects
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name         string
+		rules        string
+		expectIssues []string
+	}{
+		{
+			name: "no issues",
+			rules: `
+				/old /new permanent
+				/posts/:id /articles/:id permanent
+				/docs/* https://docs.example.com/ temporary`,
+		},
+		{
+			name: "shadowed by splat",
+			rules: `
+				/blog/* /news temporary
+				/blog/2024/hello /news/hello permanent`,
+			expectIssues: []string{"line 3: shadowed"},
+		},
+		{
+			name: "shadowed by placeholder",
+			rules: `
+				/posts/:id /articles/:id permanent
+				/posts/123 /featured permanent`,
+			expectIssues: []string{"line 3: shadowed"},
+		},
+		{
+			name: "duplicated rule",
+			rules: `
+				/old /new permanent
+				/old /newer permanent`,
+			expectIssues: []string{"line 3: shadowed"},
+		},
+		{
+			name: "placeholder does not shadow splat",
+			rules: `
+				/posts/:id /articles/:id permanent
+				/posts/* /articles temporary`,
+		},
+		{
+			name: "shadowed by wildcard in the middle of the path",
+			rules: `
+				/docs/*/old /docs temporary
+				/docs/v1/old /docs/v1 temporary`,
+			expectIssues: []string{"line 3: shadowed"},
+		},
+		{
+			name: "wildcard in the middle of the path does not shadow splat",
+			rules: `
+				/docs/*/old /docs temporary
+				/docs/* /documentation temporary`,
+		},
+		{
+			name: "regular expressions are not compared",
+			rules: `
+				~^/docs/.*$ /documentation temporary
+				/docs/intro /documentation/intro temporary`,
+		},
+		{
+			name: "case sensitive rule does not shadow case insensitive rule",
+			rules: `
+				/about /about-us permanent
+				/about /about-us permanent nocase`,
+		},
+		{
+			name: "conditions narrow the rule",
+			rules: `
+				/ /es/ temporary Language=es
+				/ /en/ temporary`,
+		},
+		{
+			name: "rule without conditions shadows conditional rule",
+			rules: `
+				/ /en/ temporary
+				/ /es/ temporary Language=es`,
+			expectIssues: []string{"line 3: shadowed"},
+		},
+		{
+			name: "query parameters narrow the rule",
+			rules: `
+				/posts?id=1 /first permanent
+				/posts?id=:id /posts/:id permanent`,
+		},
+		{
+			name: "rewrites never shadow",
+			rules: `
+				/* /index.html rewrite
+				/old /new permanent`,
+		},
+		{
+			name: "chain",
+			rules: `
+				/a /b permanent
+				/b /c permanent`,
+			expectIssues: []string{"line 2: chain"},
+		},
+		{
+			name: "chain with placeholders",
+			rules: `
+				/posts/:id /articles/:id permanent
+				/articles/:id /blog/:id permanent`,
+			expectIssues: []string{"line 2: chain"},
+		},
+		{
+			name: "loop",
+			rules: `
+				/a /b permanent
+				/b /a permanent`,
+			expectIssues: []string{"line 2: loop", "line 3: loop"},
+		},
+		{
+			name:         "self loop",
+			rules:        `/docs/:splat /docs/:splat permanent`,
+			expectIssues: []string{"line 1: loop"},
+		},
+		{
+			name:  "absolute destinations are not followed",
+			rules: `/:splat https://www.example.com/:splat permanent`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := New(tt.rules)
+			if err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			issues := engine.Lint()
+			if len(issues) != len(tt.expectIssues) {
+				t.Fatalf("expected %d issues, got %d: %v", len(tt.expectIssues), len(issues), issues)
+			}
+
+			for i, expected := range tt.expectIssues {
+				if !strings.HasPrefix(issues[i].String(), expected) {
+					t.Fatalf("expected issue %d to start with %q, got %q", i, expected, issues[i].String())
+				}
+			}
+		})
+	}
+}
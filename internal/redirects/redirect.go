@@ -1,26 +1,102 @@
 package redirects
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// optionNoCase is the rule option to match paths ignoring case.
+const optionNoCase = "nocase"
+
+// optionForwardCredentials is the rule option to forward the credentials
+// of the client, in the "Authorization" and "Cookie" headers, to the
+// upstream of a proxy rule.
+const optionForwardCredentials = "forward-credentials"
+
+// Action represents what the engine does with a request that matched a rule.
+type Action int
+
+const (
+	// ActionRedirect sends the client a redirection to the destination.
+	ActionRedirect Action = iota
+	// ActionRewrite serves the destination path internally, without
+	// the client noticing a redirection happened.
+	ActionRewrite
+	// ActionProxy forwards the request to an upstream URL and returns
+	// the upstream response to the client.
+	ActionProxy
+	// ActionStatus replies with the rule's status code and no destination,
+	// useful for "410 Gone" or "451 Unavailable For Legal Reasons".
+	ActionStatus
+)
+
+// String implements the fmt.Stringer interface.
+func (a Action) String() string {
+	switch a {
+	case ActionRedirect:
+		return "redirect"
+	case ActionRewrite:
+		return "rewrite"
+	case ActionProxy:
+		return "proxy"
+	case ActionStatus:
+		return "status"
+	default:
+		return "unknown"
+	}
+}
+
 // RedirectRule represents a single redirect rule.
 type RedirectRule struct {
-	FromPath        string            // The path part of the 'From' pattern
-	FromParams      map[string]string // Query parameters with optional placeholders
-	To              string            // The 'To' path
-	StatusCode      int
-	KeepQueryParams bool // Whether to keep original query parameters
+	FromPath           string            // The path part of the 'From' pattern
+	FromParams         map[string]string // Query parameters with optional placeholders
+	To                 string            // The 'To' path
+	StatusCode         int
+	Action             Action      // What to do when the rule matches
+	KeepQueryParams    bool        // Whether to keep original query parameters
+	Conditions         []Condition // Conditions the request must satisfy
+	LineNumber         int         // Line in the redirections file where the rule was defined
+	Source             string      // The rule as written in the redirections file
+	CaseInsensitive    bool        // Whether the path is matched ignoring case
+	ForwardCredentials bool        // Whether proxy rules forward the client credentials upstream
+	regex              *regexp.Regexp
+	segments           []string // Precompiled segments of the 'FromPath' pattern
 }
 
-// Engine holds the parsed redirect rules.
+// Result is the outcome of matching a request against the engine rules.
+type Result struct {
+	Rule        *RedirectRule
+	Destination string
+	StatusCode  int
+}
+
+// Engine holds the parsed redirect rules. Rules must not be modified
+// after the engine is created with New.
 type Engine struct {
 	Rules []RedirectRule
+	index *ruleIndex
+
+	// FileExists, if set, reports whether a request path maps to an
+	// existing file or directory. Rewrite rules are skipped for those
+	// paths so real files are never shadowed by a rewrite.
+	FileExists func(requestPath string) bool
+
+	// Authenticate, if set, wraps the handling of rewrite and proxy
+	// rules, so they require the same authentication as the files
+	// they stand in for.
+	Authenticate func(http.Handler) http.Handler
 }
 
 const colonPlaceholder = "\x00"
@@ -31,14 +107,14 @@ func New(content string) (*Engine, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Engine{Rules: rules}, nil
+	return &Engine{Rules: rules, index: newRuleIndex(rules)}, nil
 }
 
 // Middleware is an HTTP middleware that applies redirect rules.
 func (e *Engine) Middleware(logger io.Writer) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			destination, statusCode, err := e.DereferenceDestination(r.URL.RequestURI())
+			res, err := e.ResolveRequest(r)
 			if err != nil {
 				if errors.Is(err, ErrNoMatchingRule) {
 					next.ServeHTTP(w, r)
@@ -49,31 +125,169 @@ func (e *Engine) Middleware(logger io.Writer) func(http.Handler) http.Handler {
 				return
 			}
 
-			fmt.Fprintf(logger, "REDIR %q -> %q (status: %d)\n", r.URL.RequestURI(), destination, statusCode)
-			http.Redirect(w, r, destination, statusCode)
+			switch res.Rule.Action {
+			case ActionRewrite:
+				e.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintf(logger, "REWRITE %q -> %q\n", r.URL.RequestURI(), res.Destination)
+					rewritten, err := rewriteRequest(r, res.Destination)
+					if err != nil {
+						http.Error(w, "error: "+err.Error(), http.StatusInternalServerError)
+						return
+					}
+					next.ServeHTTP(w, rewritten)
+				})).ServeHTTP(w, r)
+
+			case ActionProxy:
+				e.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintf(logger, "PROXY %q -> %q\n", r.URL.RequestURI(), res.Destination)
+					target, err := url.Parse(res.Destination)
+					if err != nil {
+						http.Error(w, "error: "+err.Error(), http.StatusInternalServerError)
+						return
+					}
+					reverseProxy(logger, target, res.Rule.ForwardCredentials).ServeHTTP(w, r)
+				})).ServeHTTP(w, r)
+
+			case ActionStatus:
+				fmt.Fprintf(logger, "STATUS %q (status: %d)\n", r.URL.RequestURI(), res.StatusCode)
+				http.Error(w, fmt.Sprintf("%d %s", res.StatusCode, strings.ToLower(http.StatusText(res.StatusCode))), res.StatusCode)
+
+			default:
+				fmt.Fprintf(logger, "REDIR %q -> %q (status: %d)\n", r.URL.RequestURI(), res.Destination, res.StatusCode)
+				http.Redirect(w, r, res.Destination, res.StatusCode)
+			}
 		})
 	}
 }
 
+// authenticate wraps the handler with the authentication of the engine,
+// if any.
+func (e *Engine) authenticate(next http.Handler) http.Handler {
+	if e.Authenticate == nil {
+		return next
+	}
+
+	return e.Authenticate(next)
+}
+
+// rewrittenKey is the context key holding the original request URI
+// of a request that was rewritten by the engine.
+type rewrittenKey struct{}
+
+// IsRewritten returns true if the request was internally rewritten by a
+// "rewrite" rule.
+func IsRewritten(r *http.Request) bool {
+	_, ok := r.Context().Value(rewrittenKey{}).(string)
+	return ok
+}
+
+// rewriteRequest returns a copy of the request pointing to the given
+// local destination, keeping track of the original request URI.
+func rewriteRequest(r *http.Request, destination string) (*http.Request, error) {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rewrite destination %q: %w", destination, err)
+	}
+
+	ctx := context.WithValue(r.Context(), rewrittenKey{}, r.URL.RequestURI())
+	rewritten := r.Clone(ctx)
+	rewritten.URL.Path = u.Path
+	rewritten.URL.RawPath = u.RawPath
+	rewritten.URL.RawQuery = u.RawQuery
+	rewritten.RequestURI = u.RequestURI()
+	return rewritten, nil
+}
+
+// reverseProxy returns a handler forwarding requests to the exact target
+// URL. The credentials of the client, which are meant for this server,
+// are only forwarded when allowed by the rule.
+func reverseProxy(logger io.Writer, target *url.URL, forwardCredentials bool) http.Handler {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			out := *target
+			pr.Out.URL = &out
+			pr.Out.Host = ""
+			pr.SetXForwarded()
+
+			if !forwardCredentials {
+				pr.Out.Header.Del("Authorization")
+				pr.Out.Header.Del("Cookie")
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			fmt.Fprintf(logger, "PROXY ERROR %q -> %q: %s\n", r.URL.RequestURI(), target.String(), err)
+			http.Error(w, "502 bad gateway", http.StatusBadGateway)
+		},
+	}
+}
+
 var ErrNoMatchingRule = errors.New("no matching rule")
 
 // DereferenceDestination returns the destination URL and status code for a given request URI.
 func (e *Engine) DereferenceDestination(requestURI string) (string, int, error) {
-	u, err := url.ParseRequestURI(requestURI)
+	res, err := e.Resolve(requestURI)
+	if err != nil {
+		return "", 0, err
+	}
+	return res.Destination, res.StatusCode, nil
+}
+
+// Resolve finds the first rule matching the given request URI and returns
+// it alongside the computed destination and status code. Since there's no
+// request to check against, rules with conditions never match.
+func (e *Engine) Resolve(requestURI string) (*Result, error) {
+	if _, err := url.ParseRequestURI(requestURI); err != nil {
+		return nil, fmt.Errorf("invalid request URI %q: %w", requestURI, err)
+	}
+
+	r, err := http.NewRequestWithContext(context.Background(), http.MethodGet, requestURI, nil)
 	if err != nil {
-		return "", 0, fmt.Errorf("invalid request URI %q: %w", requestURI, err)
+		return nil, fmt.Errorf("invalid request URI %q: %w", requestURI, err)
+	}
+
+	return e.ResolveRequest(r)
+}
+
+// ResolveRequest finds the first rule matching the given request, including
+// any conditions on its headers, cookies, host or client IP, and returns it
+// alongside the computed destination and status code.
+func (e *Engine) ResolveRequest(r *http.Request) (*Result, error) {
+	u := r.URL
+
+	// The path is split once and only the rules that could
+	// match it are checked, in the order they were defined
+	pathSegments := splitPathSegments(u.Path)
+
+	var candidates []int
+	if e.index != nil {
+		candidates = e.index.candidates(pathSegments)
+	} else {
+		candidates = make([]int, len(e.Rules))
+		for i := range candidates {
+			candidates[i] = i
+		}
 	}
 
-	for _, rule := range e.Rules {
+	for _, i := range candidates {
+		rule := &e.Rules[i]
+
+		// Rewrites never shadow files that exist on disk
+		if rule.Action == ActionRewrite && e.FileExists != nil && e.FileExists(u.Path) {
+			continue
+		}
+
 		// Copy of request query parameters to avoid modifying the original
 		requestQueryParams := u.RawQuery
 
-		if params, ok := rule.Match(u.Path, requestQueryParams); ok {
-			destination := rule.buildDestination(params, requestQueryParams, rule.KeepQueryParams)
-			return destination, rule.StatusCode, nil
+		if params, ok := rule.match(u.Path, pathSegments, requestQueryParams); ok && rule.MatchConditions(r) {
+			destination := ""
+			if rule.Action != ActionStatus {
+				destination = rule.buildDestination(params, requestQueryParams, rule.KeepQueryParams)
+			}
+			return &Result{Rule: rule, Destination: destination, StatusCode: rule.StatusCode}, nil
 		}
 	}
-	return "", 0, ErrNoMatchingRule
+	return nil, ErrNoMatchingRule
 }
 
 // parseRedirectRules parses the redirect file content into a slice of RedirectRule structs.
@@ -93,19 +307,49 @@ func parseRedirectRules(content string) ([]RedirectRule, error) {
 			line = line[:idx]
 		}
 
-		// Split the line into parts
+		// Split the line into parts, where anything after
+		// the status is a condition
 		parts := strings.Fields(line)
-		if len(parts) != 3 {
+		if len(parts) < 3 {
 			return nil, fmt.Errorf("invalid redirect rule on line %d: %q", lineNum+1, line)
 		}
 
 		from, to, statusStr := parts[0], parts[1], parts[2]
 
-		statusCode, err := parseStatusCode(statusStr)
+		caseInsensitive, forwardCredentials := false, false
+		conditions := make([]Condition, 0, len(parts)-3)
+		for _, part := range parts[3:] {
+			// The "nocase" option is not a condition but a matching option
+			if strings.EqualFold(part, optionNoCase) {
+				caseInsensitive = true
+				continue
+			}
+
+			if strings.EqualFold(part, optionForwardCredentials) {
+				forwardCredentials = true
+				continue
+			}
+
+			condition, err := parseCondition(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid condition on line %d: %w", lineNum+1, err)
+			}
+			conditions = append(conditions, condition)
+		}
+
+		action, statusCode, err := parseStatusCode(statusStr)
 		if err != nil {
 			return nil, fmt.Errorf("invalid status code on line %d: %w", lineNum+1, err)
 		}
 
+		if err := validateDestination(action, to); err != nil {
+			return nil, fmt.Errorf("invalid destination on line %d: %w", lineNum+1, err)
+		}
+
+		if forwardCredentials && action != ActionProxy {
+			return nil, fmt.Errorf("invalid option on line %d: %q only applies to proxy rules", lineNum+1, optionForwardCredentials)
+		}
+
 		keepQueryParams := false
 		// Check if the 'From' path ends with '?!'
 		if strings.HasSuffix(from, "?!") {
@@ -113,32 +357,60 @@ func parseRedirectRules(content string) ([]RedirectRule, error) {
 			from = strings.TrimSuffix(from, "?!")
 		}
 
-		// Separate path and query parameters in the 'From' pattern
+		// Regular expressions are matched against the path as-is, with
+		// "~*" making them case insensitive, and can't match querystrings
 		var fromPath string
+		var regex *regexp.Regexp
 		fromParams := make(map[string]string)
 
-		if idx := strings.Index(from, "?"); idx != -1 {
-			fromPath = from[:idx]
-			queryStr := from[idx+1:]
-			fromParams = parseQueryParameters(queryStr)
+		if pattern, isRegex := strings.CutPrefix(from, "~"); isRegex {
+			if p, found := strings.CutPrefix(pattern, "*"); found {
+				pattern = p
+				caseInsensitive = true
+			}
+
+			regex, err = compileRegexPattern(pattern, caseInsensitive)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression on line %d: %w", lineNum+1, err)
+			}
+
+			fromPath = pattern
 		} else {
-			fromPath = from
-		}
+			// Separate path and query parameters in the 'From' pattern
+			if idx := strings.Index(from, "?"); idx != -1 {
+				fromPath = from[:idx]
+				queryStr := from[idx+1:]
+				fromParams = parseQueryParameters(queryStr)
+			} else {
+				fromPath = from
+			}
 
-		// Unescape colons in the 'FromPath' pattern
-		fromPath = unescapeColons(fromPath)
+			// Unescape colons in the 'FromPath' pattern
+			fromPath = unescapeColons(fromPath)
 
-		// Validate the 'FromPath' pattern
-		if err := validateFromPathPattern(fromPath, lineNum+1); err != nil {
-			return nil, err
+			// Validate the 'FromPath' pattern
+			if err := validateFromPathPattern(fromPath, lineNum+1); err != nil {
+				return nil, err
+			}
 		}
 
 		rule := RedirectRule{
-			FromPath:        fromPath,
-			FromParams:      fromParams,
-			To:              to,
-			StatusCode:      statusCode,
-			KeepQueryParams: keepQueryParams,
+			FromPath:           fromPath,
+			FromParams:         fromParams,
+			To:                 to,
+			StatusCode:         statusCode,
+			Action:             action,
+			KeepQueryParams:    keepQueryParams,
+			Conditions:         conditions,
+			LineNumber:         lineNum + 1,
+			Source:             strings.Join(parts, " "),
+			CaseInsensitive:    caseInsensitive,
+			ForwardCredentials: forwardCredentials,
+			regex:              regex,
+		}
+
+		if regex == nil {
+			rule.segments = compilePathPattern(fromPath)
 		}
 
 		rules = append(rules, rule)
@@ -154,18 +426,57 @@ func unescapeColons(s string) string {
 	return s
 }
 
-// parseStatusCode parses 'temporary' or 'permanent' to HTTP status codes.
-func parseStatusCode(s string) (int, error) {
+// parseStatusCode parses 'temporary', 'permanent', 'rewrite', 'proxy' or one
+// of the supported explicit status codes into an action and HTTP status code.
+func parseStatusCode(s string) (Action, int, error) {
 	switch strings.ToLower(s) {
-	case "permanent":
-		return http.StatusMovedPermanently, nil // 301
-	case "temporary":
-		return http.StatusFound, nil // 302
+	case "permanent", "301":
+		return ActionRedirect, http.StatusMovedPermanently, nil // 301
+	case "temporary", "302":
+		return ActionRedirect, http.StatusFound, nil // 302
+	case "307":
+		return ActionRedirect, http.StatusTemporaryRedirect, nil // 307
+	case "308":
+		return ActionRedirect, http.StatusPermanentRedirect, nil // 308
+	case "rewrite":
+		return ActionRewrite, http.StatusOK, nil
+	case "proxy":
+		return ActionProxy, http.StatusOK, nil
+	case "410":
+		return ActionStatus, http.StatusGone, nil // 410
+	case "451":
+		return ActionStatus, http.StatusUnavailableForLegalReasons, nil // 451
 	default:
-		return 0, fmt.Errorf("unsupported redirection status: %q", s)
+		return 0, 0, fmt.Errorf("unsupported redirection status: %q", s)
 	}
 }
 
+// validateDestination checks that the destination makes sense for the
+// given action: rewrites must stay within the server, while proxies must
+// point to an absolute HTTP or HTTPS URL.
+func validateDestination(action Action, to string) error {
+	switch action {
+	case ActionRewrite:
+		if !strings.HasPrefix(to, "/") {
+			return fmt.Errorf("rewrite destination %q must be a path starting with \"/\"", to)
+		}
+
+	case ActionProxy:
+		u, err := url.Parse(to)
+		if err != nil {
+			return fmt.Errorf("unable to parse proxy destination %q: %w", to, err)
+		}
+
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("proxy destination %q must be an absolute \"http\" or \"https\" URL", to)
+		}
+
+	case ActionRedirect, ActionStatus:
+	}
+
+	return nil
+}
+
 // parseQueryParameters parses query parameters into a map.
 func parseQueryParameters(queryStr string) map[string]string {
 	params := make(map[string]string)
@@ -193,11 +504,29 @@ func parseQueryParameters(queryStr string) map[string]string {
 
 // Match checks if the request path and query parameters match the rule.
 func (rule *RedirectRule) Match(requestPath, requestRawQuery string) (map[string]string, bool) {
+	return rule.match(requestPath, splitPathSegments(requestPath), requestRawQuery)
+}
+
+// match checks if the request path, already split in segments, and
+// query parameters match the rule.
+func (rule *RedirectRule) match(requestPath string, pathSegments []string, requestRawQuery string) (map[string]string, bool) {
 	params := make(map[string]string)
 
 	// Match the path
-	if !pathMatch(rule.FromPath, requestPath, params) {
-		return nil, false
+	if rule.regex != nil {
+		if !regexMatch(rule.regex, requestPath, params) {
+			return nil, false
+		}
+	} else {
+		// Rules not created through New are compiled on the fly
+		segments := rule.segments
+		if segments == nil {
+			segments = compilePathPattern(rule.FromPath)
+		}
+
+		if !matchSegments(segments, pathSegments, params, rule.CaseInsensitive) {
+			return nil, false
+		}
 	}
 
 	// Parse request query parameters
@@ -211,68 +540,187 @@ func (rule *RedirectRule) Match(requestPath, requestRawQuery string) (map[string
 	return params, true
 }
 
-// pathMatch checks if the request path matches the 'From' pattern.
-func pathMatch(pattern, path string, params map[string]string) bool {
-	// Split the pattern and the path into segments
-	patternSegments := splitPathSegments(pattern)
-	pathSegments := splitPathSegments(path)
-
-	i, j := 0, 0
-	for i < len(patternSegments) {
-		if j >= len(pathSegments) {
-			// Not enough segments in the path
+// MatchConditions checks if the request satisfies all the rule's conditions.
+func (rule *RedirectRule) MatchConditions(r *http.Request) bool {
+	for i := range rule.Conditions {
+		if !rule.Conditions[i].Matches(r) {
 			return false
 		}
+	}
+	return true
+}
 
-		patternSegment := patternSegments[i]
-		pathSegment := pathSegments[j]
-
+// compilePathPattern splits the 'From' pattern into segments, with escaped
+// colons already replaced back, so they're not split on every request.
+func compilePathPattern(pattern string) []string {
+	segments := splitPathSegments(pattern)
+	for i, segment := range segments {
 		// Replace colonPlaceholder back to ':'
-		patternSegment = strings.ReplaceAll(patternSegment, colonPlaceholder, ":")
-		pathSegment = strings.ReplaceAll(pathSegment, colonPlaceholder, ":")
+		segments[i] = strings.ReplaceAll(segment, colonPlaceholder, ":")
+	}
+	return segments
+}
 
-		switch {
-		case patternSegment == "*":
-			// Splat matches the rest of the path
-			params["splat"] = strings.Join(pathSegments[j:], "/")
-			return true
-
-		case strings.HasPrefix(patternSegment, ":"):
-			paramName := patternSegment[1:]
-
-			switch {
-			case paramName == "splat" && i == len(patternSegments)-1:
-				// ':splat' at the end captures the rest of the path
-				params["splat"] = strings.Join(pathSegments[j:], "/")
-				return true
+// matchSegments matches the compiled pattern segments against the path
+// segments, backtracking when optional segments are found.
+func matchSegments(patternSegments, pathSegments []string, params map[string]string, caseInsensitive bool) bool {
+	if len(patternSegments) == 0 {
+		// Check if all path segments have been matched
+		return len(pathSegments) == 0
+	}
 
-			case paramName == "splat":
-				// ':splat' used not at the end (should have been caught during parsing)
-				return false
+	patternSegment := patternSegments[0]
 
-			default:
-				// Regular placeholder
-				if pathSegment == "" {
-					// Do not match empty segments to parameters
-					return false
-				}
-				params[paramName] = pathSegment
+	// Optional segments are first attempted as if they were required,
+	// and if that fails, the pattern is attempted without them
+	if inner, ok := optionalSegment(patternSegment); ok {
+		if len(pathSegments) > 0 {
+			attempt := copyParams(params)
+			withSegment := append([]string{inner}, patternSegments[1:]...)
+			if matchSegments(withSegment, pathSegments, attempt, caseInsensitive) {
+				maps.Copy(params, attempt)
+				return true
 			}
+		}
 
-		case patternSegment == pathSegment:
-			// Exact match
+		// Placeholders in skipped segments are set to empty
+		if strings.HasPrefix(inner, ":") {
+			params[inner[1:]] = ""
+		}
 
-		default:
-			// No match
+		return matchSegments(patternSegments[1:], pathSegments, params, caseInsensitive)
+	}
+
+	if len(pathSegments) == 0 {
+		// Not enough segments in the path
+		return false
+	}
+
+	pathSegment := strings.ReplaceAll(pathSegments[0], colonPlaceholder, ":")
+	isLast := len(patternSegments) == 1
+
+	switch {
+	case (patternSegment == "*" || patternSegment == ":splat") && isLast:
+		// Splat at the end matches the rest of the path
+		params["splat"] = strings.Join(pathSegments, "/")
+		return true
+
+	case patternSegment == ":splat":
+		// ':splat' used not at the end (should have been caught during parsing)
+		return false
+
+	case patternSegment == "*":
+		// Wildcard in the middle of the path matches a single, non-empty segment
+		if pathSegment == "" {
 			return false
 		}
 
-		i++
-		j++
+	case strings.HasPrefix(patternSegment, ":"):
+		// Regular placeholder
+		if pathSegment == "" {
+			// Do not match empty segments to parameters
+			return false
+		}
+		params[patternSegment[1:]] = pathSegment
+
+	case isGlobSegment(patternSegment):
+		// Wildcards within a segment, like "*.html"
+		if caseInsensitive {
+			patternSegment, pathSegment = strings.ToLower(patternSegment), strings.ToLower(pathSegment)
+		}
+
+		if matched, err := path.Match(patternSegment, pathSegment); err != nil || !matched {
+			return false
+		}
+
+	case patternSegment == pathSegment, caseInsensitive && strings.EqualFold(patternSegment, pathSegment):
+		// Exact match
+
+	default:
+		// No match
+		return false
+	}
+
+	return matchSegments(patternSegments[1:], pathSegments[1:], params, caseInsensitive)
+}
+
+// optionalParams returns the names of the placeholders within optional
+// segments of the rule, which are empty when the segment is skipped.
+func (rule *RedirectRule) optionalParams() map[string]bool {
+	params := make(map[string]bool)
+	if rule.regex != nil {
+		return params
+	}
+
+	for _, segment := range splitPathSegments(rule.FromPath) {
+		if inner, ok := optionalSegment(segment); ok && strings.HasPrefix(inner, ":") {
+			params[inner[1:]] = true
+		}
 	}
 
-	// Check if all path segments have been matched
-	return j == len(pathSegments)
+	return params
+}
+
+// optionalSegment returns the contents of a segment wrapped in square
+// brackets, which marks it as optional.
+func optionalSegment(segment string) (string, bool) {
+	if len(segment) > 2 && strings.HasPrefix(segment, "[") && strings.HasSuffix(segment, "]") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// isGlobSegment checks if the segment contains wildcards within other
+// characters, like "*.html" or "report-*".
+func isGlobSegment(segment string) bool {
+	return segment != "*" && strings.Contains(segment, "*")
+}
+
+// copyParams returns a copy of the params map.
+func copyParams(params map[string]string) map[string]string {
+	c := make(map[string]string, len(params))
+	maps.Copy(c, params)
+	return c
+}
+
+// compileRegexPattern compiles a regular expression 'From' pattern.
+func compileRegexPattern(pattern string, caseInsensitive bool) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, errors.New("empty regular expression")
+	}
+
+	if caseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // wrapped by the caller with line information
+	}
+
+	return re, nil
+}
+
+// regexMatch checks if the request path matches the regular expression,
+// capturing named groups by name and all groups by their position.
+func regexMatch(re *regexp.Regexp, path string, params map[string]string) bool {
+	matches := re.FindStringSubmatch(path)
+	if matches == nil {
+		return false
+	}
+
+	for i, name := range re.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+
+		params[strconv.Itoa(i)] = matches[i]
+		if name != "" {
+			params[name] = matches[i]
+		}
+	}
+
+	return true
 }
 
 // splitPathSegments splits a path into segments, handling escaped characters.
@@ -342,12 +790,25 @@ func (rule *RedirectRule) buildDestination(params map[string]string, requestRawQ
 		destination = strings.ReplaceAll(destination, ":splat", splatValue)
 	}
 
-	// Replace other placeholders
-	for key, value := range params {
+	// Replace other placeholders, longest names first so a placeholder
+	// like ":id" doesn't replace part of ":identifier"
+	keys := slices.Collect(maps.Keys(params))
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	optional := rule.optionalParams()
+
+	for _, key := range keys {
 		if key == "splat" {
 			continue
 		}
+
 		placeholder := ":" + key
+		value := params[key]
+
+		// Skipped optional segments should not leave an empty path segment behind
+		if value == "" && optional[key] {
+			destination = strings.ReplaceAll(destination, "/"+placeholder+"/", "/")
+		}
+
 		destination = strings.ReplaceAll(destination, placeholder, value)
 	}
 
@@ -415,8 +876,27 @@ func extractParamNamesFromDestination(rule *RedirectRule, destQueryParams []Quer
 // FromPathParams extracts parameter names from the 'FromPath' pattern.
 func (rule *RedirectRule) FromPathParams() map[string]bool {
 	params := make(map[string]bool)
+
+	if rule.regex != nil {
+		for i, name := range rule.regex.SubexpNames() {
+			if i == 0 {
+				continue
+			}
+
+			params[strconv.Itoa(i)] = true
+			if name != "" {
+				params[name] = true
+			}
+		}
+		return params
+	}
+
 	patternSegments := splitPathSegments(rule.FromPath)
 	for _, segment := range patternSegments {
+		if inner, ok := optionalSegment(segment); ok {
+			segment = inner
+		}
+
 		if strings.HasPrefix(segment, ":") {
 			paramName := segment[1:]
 			params[paramName] = true
@@ -481,17 +961,25 @@ func encodeQueryParams(params []QueryParam) string {
 	return strings.Join(parts, "&")
 }
 
-// validateFromPathPattern checks that 'splat' is only used at the end of the pattern.
+// validateFromPathPattern checks that 'splat' is only used at the end of the pattern,
+// and that optional segments and wildcards within segments are valid.
 func validateFromPathPattern(fromPath string, lineNum int) error {
 	patternSegments := splitPathSegments(fromPath)
 	for i, segment := range patternSegments {
 		// Do not replace colonPlaceholder back to ':'
 
-		if segment == "*" {
-			if i != len(patternSegments)-1 {
-				return fmt.Errorf("invalid use of wildcard on line %d: \"*\" can only be used at the end of a path", lineNum)
+		if inner, ok := optionalSegment(segment); ok {
+			if inner == "*" || inner == ":splat" {
+				return fmt.Errorf("invalid optional segment %q on line %d: splats can't be optional", segment, lineNum)
 			}
+
+			if strings.ContainsAny(inner, "[]") {
+				return fmt.Errorf("invalid optional segment %q on line %d: optional segments can't be nested", segment, lineNum)
+			}
+
+			segment = inner
 		}
+
 		if strings.HasPrefix(segment, ":") {
 			paramName := segment[1:]
 			if paramName == "splat" {
@@ -500,6 +988,13 @@ func validateFromPathPattern(fromPath string, lineNum int) error {
 				}
 			}
 		}
+
+		if isGlobSegment(segment) {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid wildcard pattern %q on line %d: %w", strings.ReplaceAll(segment, colonPlaceholder, ":"), lineNum, err)
+			}
+		}
+
 		// Check for unescaped colons in the middle of the segment
 		startIdx := 1
 		if !strings.HasPrefix(segment, ":") {
@@ -508,7 +1003,7 @@ func validateFromPathPattern(fromPath string, lineNum int) error {
 		for idx := startIdx; idx < len(segment); idx++ {
 			c := segment[idx]
 			if c == ':' {
-				return fmt.Errorf("invalid use of \":\" in segment \"%s\" on line %d: \":\" can only be used at the beginning of a path section", strings.ReplaceAll(segment, colonPlaceholder, ":"), lineNum+1)
+				return fmt.Errorf("invalid use of \":\" in segment \"%s\" on line %d: \":\" can only be used at the beginning of a path section", strings.ReplaceAll(segment, colonPlaceholder, ":"), lineNum)
 			}
 		}
 	}
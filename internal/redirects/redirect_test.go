@@ -1,9 +1,12 @@
 package redirects
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -15,6 +18,7 @@ func TestRedirectionEngine(t *testing.T) {
 		expectHittingHandler bool
 		expectStatusCode     int
 		expectLocation       string
+		expectHandlerPath    string
 		expectError          bool
 	}{
 		{
@@ -285,9 +289,144 @@ func TestRedirectionEngine(t *testing.T) {
 			expectError: true,
 		},
 		{
-			name:        "splat wildcard used not at the end",
-			rules:       "/foo/*/bar /posts temporary",
-			visitedPath: "/foo/123/bar",
+			name:             "wildcard used not at the end",
+			rules:            "/foo/*/bar /posts temporary",
+			visitedPath:      "/foo/123/bar",
+			expectStatusCode: http.StatusFound,
+			expectLocation:   "/posts",
+		},
+		{
+			name:                 "wildcard used not at the end - matches a single segment",
+			rules:                "/foo/*/bar /posts temporary",
+			visitedPath:          "/foo/123/456/bar",
+			expectHittingHandler: true,
+			expectStatusCode:     http.StatusOK,
+		},
+		{
+			name:             "wildcard used not at the end - with splat at the end",
+			rules:            "/docs/*/old/* /archive/:splat permanent",
+			visitedPath:      "/docs/v1/old/guides/intro",
+			expectStatusCode: http.StatusMovedPermanently,
+			expectLocation:   "/archive/guides/intro",
+		},
+		{
+			name:             "wildcard within a segment",
+			rules:            "/blog/*.html /blog temporary",
+			visitedPath:      "/blog/hello-world.html",
+			expectStatusCode: http.StatusFound,
+			expectLocation:   "/blog",
+		},
+		{
+			name:                 "wildcard within a segment - no match",
+			rules:                "/blog/*.html /blog temporary",
+			visitedPath:          "/blog/hello-world.htm",
+			expectHittingHandler: true,
+			expectStatusCode:     http.StatusOK,
+		},
+		{
+			name:        "wildcard within a segment - invalid pattern",
+			rules:       "/blog/[a-*.html /blog temporary",
+			expectError: true,
+		},
+		{
+			name:             "optional segment - present",
+			rules:            "/[:lang]/docs/:page /docs/:lang/:page permanent",
+			visitedPath:      "/en/docs/intro",
+			expectStatusCode: http.StatusMovedPermanently,
+			expectLocation:   "/docs/en/intro",
+		},
+		{
+			name:             "optional segment - missing",
+			rules:            "/[:lang]/docs/:page /docs/:lang/:page permanent",
+			visitedPath:      "/docs/intro",
+			expectStatusCode: http.StatusMovedPermanently,
+			expectLocation:   "/docs/intro",
+		},
+		{
+			name:             "optional literal segment",
+			rules:            "/blog/[posts]/:slug /articles/:slug permanent",
+			visitedPath:      "/blog/hello",
+			expectStatusCode: http.StatusMovedPermanently,
+			expectLocation:   "/articles/hello",
+		},
+		{
+			name:             "optional segment - missing in the middle of the destination",
+			rules:            "/[:lang]/docs/:page /docs/:lang/:page/index permanent",
+			visitedPath:      "/docs/intro",
+			expectStatusCode: http.StatusMovedPermanently,
+			expectLocation:   "/docs/intro/index",
+		},
+		{
+			name:             "empty query placeholder keeps its segment",
+			rules:            "/search?q=:q https://example.com/results/:q/all temporary",
+			visitedPath:      "/search?q=",
+			expectStatusCode: http.StatusFound,
+			expectLocation:   "https://example.com/results//all",
+		},
+		{
+			name:        "optional splat",
+			rules:       "/blog/[*] /articles permanent",
+			expectError: true,
+		},
+		{
+			name:             "placeholders sharing a prefix",
+			rules:            "/:id/:identifier /items/:identifier/:id temporary",
+			visitedPath:      "/1/abc",
+			expectStatusCode: http.StatusFound,
+			expectLocation:   "/items/abc/1",
+		},
+		{
+			name:             "case insensitive",
+			rules:            "/About-Us /about temporary nocase",
+			visitedPath:      "/ABOUT-us",
+			expectStatusCode: http.StatusFound,
+			expectLocation:   "/about",
+		},
+		{
+			name:                 "case sensitive by default",
+			rules:                "/About-Us /about temporary",
+			visitedPath:          "/about-us",
+			expectHittingHandler: true,
+			expectStatusCode:     http.StatusOK,
+		},
+		{
+			name:             "regular expression - named captures",
+			rules:            `~^/v(?P<ver>\d+)/docs/(?P<page>.+)$ /docs/:page?version=:ver permanent`,
+			visitedPath:      "/v2/docs/getting-started",
+			expectStatusCode: http.StatusMovedPermanently,
+			expectLocation:   "/docs/getting-started?version=2",
+		},
+		{
+			name:             "regular expression - numbered captures",
+			rules:            `~^/(\d{4})/(\d{2})/(.+)$ /posts/:3?year=:1&month=:2 permanent`,
+			visitedPath:      "/2024/12/hello-world",
+			expectStatusCode: http.StatusMovedPermanently,
+			expectLocation:   "/posts/hello-world?year=2024&month=12",
+		},
+		{
+			name:             "regular expression - keep query parameters",
+			rules:            `~^/old/(.+)$?! /new/:1 permanent`,
+			visitedPath:      "/old/page?name=foo",
+			expectStatusCode: http.StatusMovedPermanently,
+			expectLocation:   "/new/page?name=foo",
+		},
+		{
+			name:                 "regular expression - case sensitive",
+			rules:                `~^/old$ /new permanent`,
+			visitedPath:          "/OLD",
+			expectHittingHandler: true,
+			expectStatusCode:     http.StatusOK,
+		},
+		{
+			name:             "regular expression - case insensitive",
+			rules:            `~*^/old$ /new permanent`,
+			visitedPath:      "/OLD",
+			expectStatusCode: http.StatusMovedPermanently,
+			expectLocation:   "/new",
+		},
+		{
+			name:        "regular expression - invalid",
+			rules:       `~^/old/(.+$ /new permanent`,
 			expectError: true,
 		},
 		{
@@ -367,6 +506,70 @@ func TestRedirectionEngine(t *testing.T) {
 			expectHittingHandler: true,
 			expectStatusCode:     http.StatusOK,
 		},
+		{
+			name:             "explicit status code - 307",
+			rules:            "/old /new 307",
+			visitedPath:      "/old",
+			expectStatusCode: http.StatusTemporaryRedirect,
+			expectLocation:   "/new",
+		},
+		{
+			name:             "explicit status code - 308",
+			rules:            "/old/:id /new/:id 308",
+			visitedPath:      "/old/123",
+			expectStatusCode: http.StatusPermanentRedirect,
+			expectLocation:   "/new/123",
+		},
+		{
+			name:             "explicit status code - 301 and 302",
+			rules:            "/old /new 301\n/old2 /new2 302",
+			visitedPath:      "/old2",
+			expectStatusCode: http.StatusFound,
+			expectLocation:   "/new2",
+		},
+		{
+			name:             "gone",
+			rules:            "/removed/* - 410",
+			visitedPath:      "/removed/page",
+			expectStatusCode: http.StatusGone,
+		},
+		{
+			name:             "unavailable for legal reasons",
+			rules:            "/blocked - 451",
+			visitedPath:      "/blocked",
+			expectStatusCode: http.StatusUnavailableForLegalReasons,
+		},
+		{
+			name:                 "rewrite - single page application",
+			rules:                "/* /index.html rewrite",
+			visitedPath:          "/app/users/123",
+			expectHittingHandler: true,
+			expectStatusCode:     http.StatusOK,
+			expectHandlerPath:    "/index.html",
+		},
+		{
+			name:                 "rewrite - with parameters",
+			rules:                "/posts/:id /posts?id=:id rewrite",
+			visitedPath:          "/posts/123",
+			expectHittingHandler: true,
+			expectStatusCode:     http.StatusOK,
+			expectHandlerPath:    "/posts?id=123",
+		},
+		{
+			name:        "rewrite - absolute destination",
+			rules:       "/* https://www.example.com/ rewrite",
+			expectError: true,
+		},
+		{
+			name:        "proxy - relative destination",
+			rules:       "/api/* /backend proxy",
+			expectError: true,
+		},
+		{
+			name:        "forward credentials - not a proxy",
+			rules:       "/old /new permanent forward-credentials",
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -390,6 +593,9 @@ func TestRedirectionEngine(t *testing.T) {
 				}
 
 				handlerHit = true
+				if tt.expectHandlerPath != "" && r.URL.RequestURI() != tt.expectHandlerPath {
+					t.Fatalf("expected handler to receive %q, got %q", tt.expectHandlerPath, r.URL.RequestURI())
+				}
 				w.WriteHeader(http.StatusOK)
 			}))
 
@@ -416,3 +622,190 @@ func TestRedirectionEngine(t *testing.T) {
 		})
 	}
 }
+
+func TestRewriteSkipsExistingFiles(t *testing.T) {
+	redirector, err := New("/* /index.html rewrite")
+	if err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	redirector.FileExists = func(requestPath string) bool {
+		return requestPath == "/app.js"
+	}
+
+	for visited, expected := range map[string]string{
+		"/app.js":   "/app.js",
+		"/settings": "/index.html",
+	} {
+		var got string
+		handler := redirector.Middleware(io.Discard)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			got = r.URL.Path
+			if rewritten := IsRewritten(r); rewritten != (visited != expected) {
+				t.Fatalf("expected IsRewritten to be %v for %q", !rewritten, visited)
+			}
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, visited, nil))
+
+		if got != expected {
+			t.Fatalf("expected %q to be served as %q, got %q", visited, expected, got)
+		}
+	}
+}
+
+func TestProxyRule(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Path", r.URL.RequestURI())
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "from upstream")
+	}))
+	defer upstream.Close()
+
+	redirector, err := New("/api/:splat " + upstream.URL + "/v1/:splat proxy")
+	if err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	handler := redirector.Middleware(io.Discard)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Fatalf("not expecting hitting the handler, it should've been proxied")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users/123", nil))
+
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("expected status code %d, got %d", http.StatusTeapot, rec.Code)
+	}
+
+	if got := rec.Header().Get("X-Upstream-Path"); got != "/v1/users/123" {
+		t.Fatalf("expected upstream to receive %q, got %q", "/v1/users/123", got)
+	}
+
+	if got := rec.Body.String(); got != "from upstream" {
+		t.Fatalf("expected body %q, got %q", "from upstream", got)
+	}
+}
+
+func TestProxyRuleCredentials(t *testing.T) {
+	var gotAuthorization, gotCookie string
+	upstream := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		gotAuthorization, gotCookie = r.Header.Get("Authorization"), r.Header.Get("Cookie")
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name      string
+		rule      string
+		forwarded bool
+	}{
+		{name: "removed by default", rule: "/api/* " + upstream.URL + " proxy"},
+		{name: "forwarded when allowed", rule: "/api/* " + upstream.URL + " proxy forward-credentials", forwarded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redirector, err := New(tt.rule)
+			if err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+			req.SetBasicAuth("user", "pass")
+			req.Header.Set("Cookie", "session=abc")
+
+			redirector.Middleware(io.Discard)(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+			if forwarded := gotAuthorization != "" && gotCookie != ""; forwarded != tt.forwarded {
+				t.Fatalf("expected credentials forwarded: %v, got Authorization %q and Cookie %q", tt.forwarded, gotAuthorization, gotCookie)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRules(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "from upstream")
+	}))
+	defer upstream.Close()
+
+	redirector, err := New("/api/* " + upstream.URL + " proxy\n/app/* /index.html rewrite\n/old /new permanent")
+	if err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	redirector.Authenticate = func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+	}
+
+	handler := redirector.Middleware(io.Discard)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Fatalf("not expecting hitting the handler without authentication")
+	}))
+
+	tests := map[string]int{
+		"/api/users": http.StatusUnauthorized,
+		"/app/users": http.StatusUnauthorized,
+		"/old":       http.StatusMovedPermanently,
+	}
+
+	for visited, expected := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, visited, nil))
+
+		if rec.Code != expected {
+			t.Errorf("expected status code %d for %q, got %d", expected, visited, rec.Code)
+		}
+	}
+}
+
+// legacyRules generates a redirections file similar to those of sites
+// migrated from another platform, with thousands of literal rules.
+func legacyRules(count int) string {
+	var sb strings.Builder
+	for i := range count {
+		fmt.Fprintf(&sb, "/blog/%d/%d/legacy-post-%d /posts/legacy-post-%d permanent\n", 2000+i%25, i%12+1, i, i)
+	}
+	sb.WriteString("/docs/:version/* /documentation/:splat permanent\n")
+	sb.WriteString("/* /index.html rewrite\n")
+	return sb.String()
+}
+
+func BenchmarkResolve(b *testing.B) {
+	engine, err := New(legacyRules(20000))
+	if err != nil {
+		b.Fatalf("not expecting error, got: %v", err)
+	}
+
+	benchmarks := []struct {
+		name       string
+		requestURI string
+	}{
+		{name: "first rule", requestURI: "/blog/2000/1/legacy-post-0"},
+		{name: "last literal rule", requestURI: "/blog/2024/12/legacy-post-19999"},
+		{name: "placeholder and splat", requestURI: "/docs/v2/guides/intro"},
+		{name: "catch-all", requestURI: "/app/users/123"},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := engine.Resolve(bm.requestURI); err != nil {
+					b.Fatalf("not expecting error, got: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkNew(b *testing.B) {
+	rules := legacyRules(20000)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := New(rules); err != nil {
+			b.Fatalf("not expecting error, got: %v", err)
+		}
+	}
+}
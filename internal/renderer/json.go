@@ -8,15 +8,18 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/patrickdappollonio/http-server/internal/fileutil"
 )
 
 // fileInfo represents a file's metadata for JSON output.
 type fileInfo struct {
-	Name        string `json:"name"`
-	Size        int64  `json:"size"`
-	IsDirectory bool   `json:"is_directory"`
-	ModTime     string `json:"mod_time"`
-	Path        string `json:"path"`
+	Name          string `json:"name"`
+	Size          int64  `json:"size"`
+	IsDirectory   bool   `json:"is_directory"`
+	ModTime       string `json:"mod_time"`
+	Path          string `json:"path"`
+	SymlinkTarget string `json:"symlink_target,omitempty"`
 }
 
 // jsonResponse represents the JSON response structure.
@@ -39,6 +42,11 @@ func (r *JSONRenderer) Format() string {
 	return "json"
 }
 
+// ContentType returns the content type of the rendered output.
+func (r *JSONRenderer) ContentType() string {
+	return "application/json"
+}
+
 // Render renders a directory listing in JSON format.
 func (r *JSONRenderer) Render(config Config, w http.ResponseWriter, files []os.FileInfo) error {
 	fileList := make([]fileInfo, 0, len(files))
@@ -50,11 +58,12 @@ func (r *JSONRenderer) Render(config Config, w http.ResponseWriter, files []os.F
 		}
 
 		fileList = append(fileList, fileInfo{
-			Name:        file.Name(),
-			Size:        file.Size(),
-			IsDirectory: file.IsDir(),
-			ModTime:     file.ModTime().Format(time.RFC3339),
-			Path:        filePath,
+			Name:          file.Name(),
+			Size:          file.Size(),
+			IsDirectory:   file.IsDir(),
+			ModTime:       file.ModTime().Format(time.RFC3339),
+			Path:          filePath,
+			SymlinkTarget: fileutil.SymlinkTarget(file),
 		})
 	}
 
@@ -64,7 +73,7 @@ func (r *JSONRenderer) Render(config Config, w http.ResponseWriter, files []os.F
 		Files:       fileList,
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", r.ContentType())
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		return fmt.Errorf("encoding JSON response: %w", err)
 	}
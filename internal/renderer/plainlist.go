@@ -20,6 +20,11 @@ func (r *PlainListRenderer) Format() string {
 	return "plain-list"
 }
 
+// ContentType returns the content type of the rendered output.
+func (r *PlainListRenderer) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
 // Render renders a directory listing as a simple list of filenames.
 func (r *PlainListRenderer) Render(_ Config, w http.ResponseWriter, files []os.FileInfo) error {
 	var output strings.Builder
@@ -32,7 +37,7 @@ func (r *PlainListRenderer) Render(_ Config, w http.ResponseWriter, files []os.F
 		output.WriteString(name + "\n")
 	}
 
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Type", r.ContentType())
 	_, err := w.Write([]byte(output.String()))
 	if err != nil {
 		return fmt.Errorf("writing plain list output: %w", err)
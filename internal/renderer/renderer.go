@@ -14,6 +14,8 @@ import (
 type Renderer interface {
 	// Format returns the format identifier for this renderer.
 	Format() string
+	// ContentType returns the content type of the rendered output.
+	ContentType() string
 	// Render renders the directory listing to the response writer.
 	Render(config Config, w http.ResponseWriter, files []os.FileInfo) error
 }
@@ -54,6 +56,18 @@ func Render(format string, config Config, w http.ResponseWriter, files []os.File
 	return UnsupportedFormatError{Format: format}
 }
 
+// ContentType returns the content type of the output rendered in the
+// specified format, or an empty string if the format is unsupported.
+func ContentType(format string) string {
+	for _, r := range renderers {
+		if r.Format() == format {
+			return r.ContentType()
+		}
+	}
+
+	return ""
+}
+
 // GetSupportedFormats returns a list of supported formats.
 func GetSupportedFormats() []string {
 	formats := make([]string, 0, len(renderers))
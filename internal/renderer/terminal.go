@@ -22,6 +22,11 @@ func (r *TerminalRenderer) Format() string {
 	return "terminal"
 }
 
+// ContentType returns the content type of the rendered output.
+func (r *TerminalRenderer) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
 // Render renders a directory listing in terminal-friendly format.
 func (r *TerminalRenderer) Render(config Config, w http.ResponseWriter, files []os.FileInfo) error {
 	var buf bytes.Buffer
@@ -56,7 +61,7 @@ func (r *TerminalRenderer) Render(config Config, w http.ResponseWriter, files []
 		return fmt.Errorf("flushing tabwriter: %w", err)
 	}
 
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Type", r.ContentType())
 	_, err := w.Write(buf.Bytes())
 	if err != nil {
 		return fmt.Errorf("writing terminal output: %w", err)
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/patrickdappollonio/http-server/internal/cache"
+	"github.com/patrickdappollonio/http-server/internal/common"
+	"github.com/patrickdappollonio/http-server/internal/ctype"
+	"github.com/saintfish/chardet"
+)
+
+// Kinds of entries kept in the in-memory cache for each file.
+const (
+	cacheKindFile     = "file"
+	cacheKindMarkdown = "markdown"
+)
+
+// cachedFile is what's kept in memory about a file: its content type
+// and, for files small enough, its content. For markdown entries, the
+// content is the rendered HTML.
+type cachedFile struct {
+	contentType string
+	content     []byte
+}
+
+// validateCache parses the size of the in-memory cache and creates it.
+func (s *Server) validateCache() error {
+	if s.CacheSize == "" {
+		return nil
+	}
+
+	size, err := common.ParseSize(s.CacheSize)
+	if err != nil {
+		return fmt.Errorf("unable to parse cache size: %w", err)
+	}
+
+	s.cache = cache.New[cachedFile](size)
+	return nil
+}
+
+// cacheKey generates the key for an entry of the given kind about a file.
+func cacheKey(kind, location string, fi os.FileInfo) cache.Key {
+	return cache.Key{Kind: kind, Path: location, ModTime: fi.ModTime(), Size: fi.Size()}
+}
+
+// fileDetails returns the content type of the file and, if it's small
+// enough to be cached, its content, using the cached version if the file
+// hasn't changed. When no content is returned, the file is positioned at
+// its beginning so it can be served from disk.
+func (s *Server) fileDetails(location string, f *os.File, fi os.FileInfo) (cachedFile, error) {
+	key := cacheKey(cacheKindFile, location, fi)
+	if entry, found := s.cache.Get(key); found {
+		return entry, nil
+	}
+
+	contentType, err := detectContentType(location, f)
+	if err != nil {
+		return cachedFile{}, err
+	}
+
+	// Reset file position to beginning after reading first bytes for content detection
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return cachedFile{}, fmt.Errorf("unable to seek file %q: %w", location, err)
+	}
+
+	entry := cachedFile{contentType: contentType}
+	overhead := int64(len(location) + len(contentType))
+
+	// Files too big for the cache only get their content type cached
+	if s.cache == nil || fi.Size()+overhead > s.cache.MaxEntrySize() {
+		s.cache.Add(key, entry, overhead)
+		return entry, nil
+	}
+
+	content, err := io.ReadAll(io.LimitReader(f, fi.Size()))
+	if err != nil {
+		return cachedFile{}, fmt.Errorf("unable to read file %q: %w", location, err)
+	}
+
+	// The file changed while reading it, so serve it without caching it
+	if int64(len(content)) != fi.Size() {
+		return cachedFile{contentType: contentType, content: content}, nil
+	}
+
+	entry.content = content
+	s.cache.Add(key, entry, int64(len(content))+overhead)
+	return entry, nil
+}
+
+// detectContentType detects the content type of a file from its name
+// or, if unknown, from its first bytes, including its charset for text
+// files.
+func detectContentType(location string, f *os.File) (string, error) {
+	var contentType string
+	if local := ctype.GetContentTypeForFilename(filepath.Base(location)); local != "" {
+		contentType = local
+	}
+
+	var data [512]byte
+	n, err := f.Read(data[:])
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("unable to read file %q: %w", location, err)
+	}
+
+	// Only detect content type if we have content to examine
+	if contentType == "" && n > 0 {
+		if local := http.DetectContentType(data[:n]); local != "application/octet-stream" {
+			contentType = local
+		}
+	}
+
+	// Only attempt charset detection if we have content to examine
+	charset := ""
+	if n > 0 && contentType != "" && !strings.HasPrefix(contentType, "application/octet-stream") {
+		if utf8.Valid(data[:n]) {
+			charset = "utf-8"
+		} else {
+			res, err := chardet.NewTextDetector().DetectBest(data[:n])
+			if err == nil && res.Confidence > 50 && res.Charset != "" {
+				charset = res.Charset
+			}
+		}
+	}
+
+	// Add charset for text-based content types only
+	if contentType != "" && charset != "" && contentType != "application/octet-stream" {
+		contentType += "; charset=" + charset
+	}
+
+	return contentType, nil
+}
+
+// reader returns a reader for the content of a cached file.
+func (c cachedFile) reader() *bytes.Reader {
+	return bytes.NewReader(c.content)
+}
+
+// printCacheStats prints the counters of the in-memory cache, if enabled.
+func (s *Server) printCacheStats() {
+	if s.cache == nil {
+		return
+	}
+
+	stats := s.cache.Stats()
+	fmt.Fprintf(
+		s.LogOutput,
+		"Cache stats: %d hits, %d misses, %d evictions, %d invalidations, %d entries using %s of %s\n",
+		stats.Hits, stats.Misses, stats.Evictions, stats.Invalidations, stats.Entries,
+		common.Humansize(stats.Size), common.Humansize(stats.MaxSize),
+	)
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInMemoryCache(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"hello.txt":      "hello world",
+		"big.txt":        strings.Repeat("a", 2048),
+		"docs/README.md": "# Title\n\nSome *content*.",
+	}
+
+	for name, content := range files {
+		location := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+		if err := os.WriteFile(location, []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	s := &Server{Path: dir, PathPrefix: "/", LogOutput: io.Discard, ETagDisabled: true, CacheSize: "8K"}
+	if err := s.validateCache(); err != nil {
+		t.Fatalf("unable to configure cache: %v", err)
+	}
+
+	templates, err := s.generateTemplates()
+	if err != nil {
+		t.Fatalf("unable to generate templates: %v", err)
+	}
+	s.templates = templates
+
+	handler := s.router()
+
+	get := func(t *testing.T, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200 for %q, got %d", path, rec.Code)
+		}
+		return rec
+	}
+
+	t.Run("files are served from memory once cached", func(t *testing.T) {
+		before := s.cache.Stats()
+
+		first := get(t, "/hello.txt")
+		second := get(t, "/hello.txt")
+
+		if first.Body.String() != "hello world" || second.Body.String() != "hello world" {
+			t.Fatalf("unexpected bodies: %q and %q", first.Body.String(), second.Body.String())
+		}
+
+		if got := second.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+			t.Errorf("expected cached content type, got %q", got)
+		}
+
+		after := s.cache.Stats()
+		if after.Misses-before.Misses != 1 || after.Hits-before.Hits != 1 {
+			t.Errorf("expected 1 miss and 1 hit, got %d misses and %d hits", after.Misses-before.Misses, after.Hits-before.Hits)
+		}
+	})
+
+	t.Run("changed files are read again", func(t *testing.T) {
+		location := filepath.Join(dir, "hello.txt")
+		if err := os.WriteFile(location, []byte("hello again, world"), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+
+		// Make sure the modification time changes even on filesystems
+		// with a coarse resolution
+		later := time.Now().Add(time.Minute)
+		if err := os.Chtimes(location, later, later); err != nil {
+			t.Fatalf("unable to change file times: %v", err)
+		}
+
+		before := s.cache.Stats()
+		if got := get(t, "/hello.txt").Body.String(); got != "hello again, world" {
+			t.Errorf("expected updated content, got %q", got)
+		}
+
+		if after := s.cache.Stats(); after.Invalidations-before.Invalidations != 1 {
+			t.Errorf("expected 1 invalidation, got %d", after.Invalidations-before.Invalidations)
+		}
+	})
+
+	t.Run("big files are served from disk", func(t *testing.T) {
+		if got := get(t, "/big.txt").Body.String(); got != files["big.txt"] {
+			t.Errorf("unexpected body of %d bytes", len(got))
+		}
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/big.txt", nil)
+		req.Header.Set("Range", "bytes=0-9")
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusPartialContent || rec.Body.String() != strings.Repeat("a", 10) {
+			t.Errorf("unexpected range response: %d %q", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("rendered markdown is cached", func(t *testing.T) {
+		first := get(t, "/docs/")
+
+		before := s.cache.Stats()
+		second := get(t, "/docs/")
+		after := s.cache.Stats()
+
+		if !strings.Contains(second.Body.String(), "<em>content</em>") {
+			t.Errorf("expected rendered markdown in the listing")
+		}
+
+		if first.Body.String() != second.Body.String() {
+			t.Errorf("expected the same listing when served from the cache")
+		}
+
+		if after.Hits-before.Hits != 1 {
+			t.Errorf("expected the markdown to be served from the cache, got %d hits", after.Hits-before.Hits)
+		}
+	})
+}
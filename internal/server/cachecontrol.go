@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/patrickdappollonio/http-server/internal/cachecontrol"
+)
+
+// validateCacheControl parses the rules used to set the "Cache-Control"
+// header of responses.
+func (s *Server) validateCacheControl() error {
+	policy, err := cachecontrol.Parse(s.CacheControl)
+	if err != nil {
+		return err //nolint:wrapcheck // error already includes the rule at fault
+	}
+
+	s.cacheControl = policy
+	return nil
+}
+
+// setCacheControl sets the "Cache-Control" header of a response for the
+// given path, relative to the root of the served files, and content type,
+// if any rule matches them.
+func (s *Server) setCacheControl(w http.ResponseWriter, requestPath, contentType string) {
+	if value := s.cacheControl.Header(requestPath, contentType); value != "" {
+		w.Header().Set("Cache-Control", value)
+	}
+}
+
+// servedPath returns the path of a file relative to the root of the served
+// files, falling back to the path of the request, without the path
+// prefix, for files outside of it, like a custom 404 page.
+func (s *Server) servedPath(location string, r *http.Request) string {
+	if root, err := filepath.Abs(s.Path); err == nil {
+		if rel, inside := relativePath(root, location); inside {
+			return path.Join("/", filepath.ToSlash(rel))
+		}
+	}
+
+	return s.requestPath(r)
+}
+
+// requestPath returns the path of the request without the path prefix.
+func (s *Server) requestPath(r *http.Request) string {
+	return path.Join("/", strings.TrimPrefix(r.URL.Path, s.PathPrefix))
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheControl(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"index.html":             "home",
+		"assets/app.3f9a1c2e.js": "fingerprinted",
+		"assets/app.js":          "not fingerprinted",
+		"docs/README.md":         "# Docs",
+		"notes/todo.md":          "# Todo",
+		"private/secret.txt":     "secret",
+	}
+
+	for name, content := range files {
+		location := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+		if err := os.WriteFile(location, []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	s := &Server{
+		Path:               dir,
+		PathPrefix:         "/files/",
+		LogOutput:          io.Discard,
+		FullMarkdownRender: true,
+		CacheControl: []string{
+			"fingerprinted",
+			"/private/** no-store",
+			"*.html no-cache",
+			"text/html max-age=60",
+			"application/json max-age=5",
+		},
+	}
+
+	if err := s.validateCacheControl(); err != nil {
+		t.Fatalf("unable to parse cache control rules: %v", err)
+	}
+
+	templates, err := s.generateTemplates()
+	if err != nil {
+		t.Fatalf("unable to generate templates: %v", err)
+	}
+	s.templates = templates
+
+	handler := s.router()
+
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		wantStatus int
+		want       string
+	}{
+		{name: "fingerprinted file", path: "/files/assets/app.3f9a1c2e.js", wantStatus: http.StatusOK, want: "max-age=31536000, immutable"},
+		{name: "file without rules", path: "/files/assets/app.js", wantStatus: http.StatusOK, want: ""},
+		{name: "path rule", path: "/files/private/secret.txt", wantStatus: http.StatusOK, want: "no-store"},
+		{name: "index file of a directory", path: "/files/", wantStatus: http.StatusOK, want: "no-cache, max-age=0"},
+		{name: "directory listing", path: "/files/assets/", wantStatus: http.StatusOK, want: "max-age=60"},
+		{name: "directory listing as json", path: "/files/assets/?output=json", wantStatus: http.StatusOK, want: "max-age=5"},
+		{name: "listing with readme", path: "/files/docs/", wantStatus: http.StatusOK, want: "max-age=60"},
+		{name: "rendered markdown", path: "/files/notes/todo.md", wantStatus: http.StatusOK, want: "max-age=60"},
+		{name: "revalidated file", path: "/files/assets/app.3f9a1c2e.js", header: "If-Modified-Since", wantStatus: http.StatusNotModified, want: "max-age=31536000, immutable"},
+		{name: "not found", path: "/files/missing.html", wantStatus: http.StatusNotFound, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header == "If-Modified-Since" {
+				req.Header.Set("If-Modified-Since", "Mon, 01 Jan 2999 00:00:00 GMT")
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+
+			if got := rec.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("expected Cache-Control %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
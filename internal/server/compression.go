@@ -0,0 +1,51 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/patrickdappollonio/http-server/internal/common"
+	"github.com/patrickdappollonio/http-server/internal/compression"
+)
+
+// compressionEncodings returns the encodings enabled for dynamic
+// compression, in order of preference. The "--gzip" flag is kept
+// as a shorthand for enabling gzip.
+func (s *Server) compressionEncodings() []string {
+	encodings := make([]string, 0, len(s.Compression)+1)
+	for _, encoding := range s.Compression {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if !slices.Contains(encodings, encoding) {
+			encodings = append(encodings, encoding)
+		}
+	}
+
+	if s.GzipEnabled && !slices.Contains(encodings, compression.Gzip) {
+		encodings = append(encodings, compression.Gzip)
+	}
+
+	return encodings
+}
+
+// validateCompression checks the encodings and the minimum size
+// used to compress responses.
+func (s *Server) validateCompression() error {
+	for _, encoding := range s.compressionEncodings() {
+		if !slices.Contains(compression.Supported, encoding) {
+			return fmt.Errorf("unsupported compression %q: must be one of: %s", encoding, strings.Join(compression.Supported, ", "))
+		}
+	}
+
+	if s.CompressionMinSize == "" {
+		return nil
+	}
+
+	size, err := common.ParseSize(s.CompressionMinSize)
+	if err != nil {
+		return fmt.Errorf("unable to parse compression min size: %w", err)
+	}
+
+	s.compressionMinSizeBytes = size
+	return nil
+}
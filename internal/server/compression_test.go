@@ -0,0 +1,94 @@
+package server
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestValidateCompression(t *testing.T) {
+	tests := []struct {
+		name          string
+		compression   []string
+		gzip          bool
+		minSize       string
+		wantEncodings []string
+		wantMinSize   int64
+		wantErr       bool
+	}{
+		{
+			name:          "disabled",
+			minSize:       "1K",
+			wantEncodings: []string{},
+			wantMinSize:   1024,
+		},
+		{
+			name:          "all encodings in order",
+			compression:   []string{"zstd", "br", "gzip"},
+			minSize:       "512",
+			wantEncodings: []string{"zstd", "br", "gzip"},
+			wantMinSize:   512,
+		},
+		{
+			name:          "gzip flag as shorthand",
+			gzip:          true,
+			minSize:       "1K",
+			wantEncodings: []string{"gzip"},
+			wantMinSize:   1024,
+		},
+		{
+			name:          "gzip flag with other encodings",
+			compression:   []string{"br"},
+			gzip:          true,
+			minSize:       "1K",
+			wantEncodings: []string{"br", "gzip"},
+			wantMinSize:   1024,
+		},
+		{
+			name:          "duplicated and uppercase encodings",
+			compression:   []string{"BR", "gzip", "br"},
+			gzip:          true,
+			minSize:       "1K",
+			wantEncodings: []string{"br", "gzip"},
+			wantMinSize:   1024,
+		},
+		{
+			name:        "unsupported encoding",
+			compression: []string{"deflate"},
+			minSize:     "1K",
+			wantErr:     true,
+		},
+		{
+			name:        "invalid min size",
+			compression: []string{"gzip"},
+			minSize:     "1X",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Compression:        tt.compression,
+				GzipEnabled:        tt.gzip,
+				CompressionMinSize: tt.minSize,
+			}
+
+			err := s.validateCompression()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateCompression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got := s.compressionEncodings(); !slices.Equal(got, tt.wantEncodings) {
+				t.Errorf("compressionEncodings() = %v, want %v", got, tt.wantEncodings)
+			}
+
+			if s.compressionMinSizeBytes != tt.wantMinSize {
+				t.Errorf("compressionMinSizeBytes = %d, want %d", s.compressionMinSizeBytes, tt.wantMinSize)
+			}
+		})
+	}
+}
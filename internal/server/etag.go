@@ -0,0 +1,73 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/patrickdappollonio/http-server/internal/etag"
+)
+
+// validateETagHashCache loads the content hashes used to generate entity
+// tags for files, if a cache file was configured, and prevents the cache
+// file from being served if it lives within the served path.
+func (s *Server) validateETagHashCache() error {
+	if s.ETagHashCache == "" || s.ETagDisabled {
+		return nil
+	}
+
+	hashes, err := etag.LoadHashCache(s.ETagHashCache)
+	if err != nil {
+		return err //nolint:wrapcheck // error already includes the file path
+	}
+
+	// Files bigger than --etag-max-size are hashed in the background,
+	// rather than while handling the request that first asks for them
+	hashes.SetMaxSyncSize(s.etagMaxSizeBytes)
+	s.etagHashes = hashes
+
+	if validateIsFileInPath(s.Path, s.ETagHashCache) {
+		s.forbiddenMatches = append(s.forbiddenMatches, filepath.Base(s.ETagHashCache))
+	}
+
+	return nil
+}
+
+// fileETag returns the entity tag for a file, built from the hash of its
+// content if a hash cache is configured, or from its metadata otherwise.
+// An empty string is returned if ETags are disabled.
+func (s *Server) fileETag(location string, fi os.FileInfo) string {
+	if s.ETagDisabled {
+		return ""
+	}
+
+	if s.etagHashes != nil {
+		tag, err := s.etagHashes.ETag(location, fi)
+		if err == nil {
+			return tag
+		}
+
+		if errors.Is(err, etag.ErrHashPending) {
+			return etag.FromFileInfo(fi)
+		}
+
+		s.printWarningf("unable to hash file for its ETag, using its metadata instead: %s", err)
+	}
+
+	return etag.FromFileInfo(fi)
+}
+
+// saveETagHashes persists the content hashes, if a cache file was configured.
+func (s *Server) saveETagHashes() {
+	if s.etagHashes == nil {
+		return
+	}
+
+	if err := s.etagHashes.Save(); err != nil {
+		s.printWarningf("%s", err)
+		return
+	}
+
+	fmt.Fprintln(s.LogOutput, "Saved ETag hashes to:", s.ETagHashCache)
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patrickdappollonio/http-server/internal/etag"
+)
+
+func TestFileETags(t *testing.T) {
+	dir := t.TempDir()
+	big := strings.Repeat("0123456789", 1024)
+
+	if err := os.WriteFile(filepath.Join(dir, "big.txt"), []byte(big), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	for _, hashed := range []bool{false, true} {
+		name := "metadata"
+		if hashed {
+			name = "content hash"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			// Files bigger than the maximum size for buffered responses
+			// still get an ETag
+			s := &Server{Path: dir, PathPrefix: "/", LogOutput: io.Discard, etagMaxSizeBytes: 1024}
+			if hashed {
+				s.ETagHashCache = filepath.Join(dir, "hashes.json")
+				if err := s.validateETagHashCache(); err != nil {
+					t.Fatalf("unable to load hash cache: %v", err)
+				}
+			}
+
+			handler := s.router()
+
+			request := func(headers map[string]string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, "/big.txt", nil)
+				for k, v := range headers {
+					req.Header.Set(k, v)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			tag := request(nil).Header().Get("ETag")
+			if tag == "" || strings.HasPrefix(tag, "W/") {
+				t.Fatalf("expected a strong ETag, got %q", tag)
+			}
+
+			// The file is bigger than the maximum size, so it's hashed in
+			// the background, keeping the metadata ETag in the meantime
+			if hashed {
+				fi, err := os.Stat(filepath.Join(dir, "big.txt"))
+				if err != nil {
+					t.Fatalf("unable to stat file: %v", err)
+				}
+
+				deadline := time.Now().Add(5 * time.Second)
+				for tag == etag.FromFileInfo(fi) {
+					if time.Now().After(deadline) {
+						t.Fatalf("timed out waiting for the content hash ETag")
+					}
+
+					time.Sleep(10 * time.Millisecond)
+					tag = request(nil).Header().Get("ETag")
+				}
+			}
+
+			tests := []struct {
+				name       string
+				headers    map[string]string
+				wantStatus int
+				wantBody   string
+			}{
+				{
+					name:       "if-none-match with the current tag",
+					headers:    map[string]string{"If-None-Match": tag},
+					wantStatus: http.StatusNotModified,
+				},
+				{
+					name:       "if-none-match with a list",
+					headers:    map[string]string{"If-None-Match": `"old", ` + tag},
+					wantStatus: http.StatusNotModified,
+				},
+				{
+					name:       "if-none-match with a weak tag",
+					headers:    map[string]string{"If-None-Match": "W/" + tag},
+					wantStatus: http.StatusNotModified,
+				},
+				{
+					name:       "if-none-match with another tag",
+					headers:    map[string]string{"If-None-Match": `"old"`},
+					wantStatus: http.StatusOK,
+					wantBody:   big,
+				},
+				{
+					name:       "if-match with the current tag",
+					headers:    map[string]string{"If-Match": `"old", ` + tag},
+					wantStatus: http.StatusOK,
+					wantBody:   big,
+				},
+				{
+					name:       "if-match with another tag",
+					headers:    map[string]string{"If-Match": `"old"`},
+					wantStatus: http.StatusPreconditionFailed,
+				},
+				{
+					name:       "if-range with the current tag",
+					headers:    map[string]string{"Range": "bytes=0-4", "If-Range": tag},
+					wantStatus: http.StatusPartialContent,
+					wantBody:   "01234",
+				},
+				{
+					name:       "if-range with another tag",
+					headers:    map[string]string{"Range": "bytes=0-4", "If-Range": `"old"`},
+					wantStatus: http.StatusOK,
+					wantBody:   big,
+				},
+				{
+					name:       "if-range with a weak tag",
+					headers:    map[string]string{"Range": "bytes=0-4", "If-Range": "W/" + tag},
+					wantStatus: http.StatusOK,
+					wantBody:   big,
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					rec := request(tt.headers)
+					if rec.Code != tt.wantStatus {
+						t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+					}
+
+					if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+						t.Errorf("unexpected body of %d bytes", rec.Body.Len())
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestETagHashCacheIsNotServed(t *testing.T) {
+	dir := t.TempDir()
+	s := &Server{Path: dir, PathPrefix: "/", LogOutput: io.Discard, ETagHashCache: filepath.Join(dir, "etags.json")}
+	if err := s.validateETagHashCache(); err != nil {
+		t.Fatalf("unable to load hash cache: %v", err)
+	}
+
+	if !s.isFiltered("etags.json") {
+		t.Errorf("expected the hash cache file to be filtered")
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/patrickdappollonio/http-server/internal/ignore"
+)
+
+// ignoreFileName is the name of the file, at the root of the served path,
+// with patterns of files to exclude using the ".gitignore" syntax.
+const ignoreFileName = ".httpserverignore"
+
+// wellKnownDir is the directory for site-wide metadata, like the
+// challenges used to issue TLS certificates, which is kept visible even
+// when dotfiles are hidden.
+const wellKnownDir = ".well-known"
+
+// validateExclusions parses the patterns of files to exclude, from the
+// ignore file at the root of the served path, if any, followed by the
+// ones given through flags, so the latter can override the former.
+func (s *Server) validateExclusions() error {
+	var patterns []string
+
+	content, err := os.ReadFile(filepath.Join(s.Path, ignoreFileName)) //nolint:gosec // fixed filename within the serving root
+	switch {
+	case err == nil:
+		patterns = strings.Split(string(content), "\n")
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("unable to read ignore file %q: %w", ignoreFileName, err)
+	}
+
+	if _, err := ignore.Parse(patterns); err != nil {
+		return fmt.Errorf("unable to parse ignore file %q: %w", ignoreFileName, err)
+	}
+
+	matcher, err := ignore.Parse(append(patterns, s.Exclude...))
+	if err != nil {
+		return fmt.Errorf("unable to parse exclude patterns: %w", err)
+	}
+
+	s.excludes = matcher
+	return nil
+}
+
+// isHidden checks if the file or directory at the given path, relative to
+// the root of the served files, must be hidden from listings and direct
+// access.
+func (s *Server) isHidden(relpath string, isDir bool) bool {
+	relpath = path.Clean("/" + filepath.ToSlash(relpath))
+	if relpath == "/" {
+		return false
+	}
+
+	if s.isFiltered(path.Base(relpath)) {
+		return true
+	}
+
+	if s.HideDotfiles && hasDotSegment(relpath) {
+		return true
+	}
+
+	return s.excludes.Match(relpath, isDir)
+}
+
+// isForbiddenPath checks if the request for the given URL path targets a
+// hidden file or directory, or a file within a hidden directory.
+func (s *Server) isForbiddenPath(urlPath string) bool {
+	relpath := path.Clean("/" + strings.TrimPrefix(urlPath, s.PathPrefix))
+
+	// Requests don't always say if they're for a directory, so check on
+	// disk when there are patterns that only match directories
+	isDir := strings.HasSuffix(urlPath, "/")
+	if !isDir && !s.excludes.Empty() {
+		if fi, err := os.Stat(filepath.Join(s.Path, filepath.FromSlash(relpath))); err == nil { //nolint:gosec // path is cleaned and joined to the serving root
+			isDir = fi.IsDir()
+		}
+	}
+
+	return s.isHidden(relpath, isDir)
+}
+
+// hasDotSegment checks if any segment of the path starts with a dot,
+// except for the ".well-known" directory.
+func hasDotSegment(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if strings.HasPrefix(segment, ".") && segment != "." && segment != ".." && segment != wellKnownDir {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExclusions(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		".env":                          "secret",
+		".git/config":                   "config",
+		".well-known/security.txt":      "contact",
+		ignoreFileName:                  "*.log\n/docs/private/\n",
+		"app.log":                       "log",
+		"index.txt":                     "index",
+		"docs/guide.txt":                "guide",
+		"docs/private/notes.txt":        "notes",
+		"docs/nested/private/notes.txt": "nested notes",
+		"build/out.txt":                 "out",
+		"tmp":                           "a file, not a directory",
+	}
+
+	for name, content := range files {
+		location := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+		if err := os.WriteFile(location, []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		hideDotfiles bool
+		exclude      []string
+		path         string
+		expectCode   int
+		expectFiles  []string
+	}{
+		{name: "dotfiles visible by default", path: "/.env", expectCode: http.StatusOK},
+		{name: "dotfile hidden", hideDotfiles: true, path: "/.env", expectCode: http.StatusNotFound},
+		{name: "file within dot directory hidden", hideDotfiles: true, path: "/.git/config", expectCode: http.StatusNotFound},
+		{name: "dot directory hidden", hideDotfiles: true, path: "/.git/", expectCode: http.StatusNotFound},
+		{name: "well-known directory visible", hideDotfiles: true, path: "/.well-known/security.txt", expectCode: http.StatusOK},
+		{name: "ignore file never served", path: "/" + ignoreFileName, expectCode: http.StatusNotFound},
+		{name: "pattern from ignore file", path: "/app.log", expectCode: http.StatusNotFound},
+		{name: "directory from ignore file", path: "/docs/private/notes.txt", expectCode: http.StatusNotFound},
+		{name: "directory from ignore file without trailing slash", path: "/docs/private", expectCode: http.StatusNotFound},
+		{name: "anchored pattern not matching nested directory", path: "/docs/nested/private/notes.txt", expectCode: http.StatusOK},
+		{name: "pattern from flags", exclude: []string{"out.txt"}, path: "/build/out.txt", expectCode: http.StatusNotFound},
+		{name: "directory pattern from flags", exclude: []string{"/build/"}, path: "/build/out.txt", expectCode: http.StatusNotFound},
+		{name: "directory pattern not matching files", exclude: []string{"tmp/"}, path: "/tmp", expectCode: http.StatusOK},
+		{name: "flags override the ignore file", exclude: []string{"!app.log"}, path: "/app.log", expectCode: http.StatusOK},
+		{name: "not excluded", path: "/docs/guide.txt", expectCode: http.StatusOK},
+		{
+			name:         "listing of the root",
+			hideDotfiles: true,
+			path:         "/?output=json",
+			expectCode:   http.StatusOK,
+			expectFiles:  []string{".well-known", "build", "docs", "index.txt", "tmp"},
+		},
+		{
+			name:        "listing of a directory",
+			path:        "/docs/?output=json",
+			expectCode:  http.StatusOK,
+			expectFiles: []string{"guide.txt", "nested"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Path:         dir,
+				PathPrefix:   "/",
+				LogOutput:    io.Discard,
+				HideDotfiles: tt.hideDotfiles,
+				Exclude:      tt.exclude,
+			}
+
+			if err := s.validateExclusions(); err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+			s.router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectCode {
+				t.Fatalf("expected status code %d, got %d - response: %s", tt.expectCode, rec.Code, rec.Body.String())
+			}
+
+			if tt.expectFiles == nil {
+				return
+			}
+
+			var listing struct {
+				Files []FileInfo `json:"files"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+				t.Fatalf("unable to parse listing %q: %v", rec.Body.String(), err)
+			}
+
+			names := make([]string, 0, len(listing.Files))
+			for _, f := range listing.Files {
+				names = append(names, f.Name)
+			}
+			slices.Sort(names)
+
+			if !slices.Equal(names, tt.expectFiles) {
+				t.Fatalf("expected files %v, got %v", tt.expectFiles, names)
+			}
+		})
+	}
+}
+
+func TestValidateExclusions(t *testing.T) {
+	tests := []struct {
+		name       string
+		ignoreFile string
+		exclude    []string
+		wantError  string
+	}{
+		{name: "no patterns"},
+		{name: "valid patterns", ignoreFile: "# comment\n*.log\n", exclude: []string{"/build/"}},
+		{name: "invalid pattern in ignore file", ignoreFile: "file[.txt\n", wantError: "unable to parse ignore file"},
+		{name: "invalid pattern in flags", exclude: []string{"file[.txt"}, wantError: "unable to parse exclude patterns"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.ignoreFile != "" {
+				if err := os.WriteFile(filepath.Join(dir, ignoreFileName), []byte(tt.ignoreFile), 0o600); err != nil {
+					t.Fatalf("unable to write file: %v", err)
+				}
+			}
+
+			s := &Server{Path: dir, Exclude: tt.exclude}
+			err := s.validateExclusions()
+
+			if tt.wantError == "" {
+				if err != nil {
+					t.Fatalf("not expecting error, got: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Fatalf("expected error containing %q, got: %v", tt.wantError, err)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 // served.
 var forbiddenMatches = []string{
 	"_redirects",
+	ignoreFileName,
 }
 
 // forbiddenPrefixes and forbiddenSuffixes are a list of prefixes that are
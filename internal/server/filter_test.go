@@ -1,6 +1,10 @@
 package server
 
-import "testing"
+import (
+	"io"
+	"path/filepath"
+	"testing"
+)
 
 func Test_isFiltered(t *testing.T) {
 	tests := []struct {
@@ -51,3 +55,28 @@ func Test_isFiltered(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigFileIsNotServed(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(dir string) string
+		want   bool
+	}{
+		{name: "within the served path", config: func(dir string) string { return filepath.Join(dir, "settings.toml") }, want: true},
+		{name: "outside the served path", config: func(string) string { return filepath.Join(t.TempDir(), "settings.toml") }, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := &Server{Port: 5000, Path: dir, PathPrefix: "/", LogOutput: io.Discard, ETagMaxSize: "5M", ConfigFile: tt.config(dir)}
+			if err := s.Validate(); err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			if got := s.isFiltered("settings.toml"); got != tt.want {
+				t.Errorf("isFiltered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
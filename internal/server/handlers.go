@@ -12,13 +12,11 @@ import (
 	"slices"
 	"sort"
 	"strings"
-	"unicode/utf8"
 
-	"github.com/patrickdappollonio/http-server/internal/ctype"
+	"github.com/patrickdappollonio/http-server/internal/compression"
 	"github.com/patrickdappollonio/http-server/internal/fileutil"
 	"github.com/patrickdappollonio/http-server/internal/renderer"
 	isort "github.com/patrickdappollonio/http-server/internal/sort"
-	"github.com/saintfish/chardet"
 )
 
 const (
@@ -41,10 +39,25 @@ func (s *Server) showOrRender(w http.ResponseWriter, r *http.Request) {
 
 	// Stat the current path
 	info, err := os.Stat(currentPath) //nolint:gosec // path is sanitized via filepath.Abs and constrained to the serving root
+
+	// Paths going through symbolic links forbidden by the symlink policy
+	// are handled as if they didn't exist
+	if err == nil && !s.symlinkAllowed(currentPath) {
+		s.printWarningf("symbolic link in path %q is forbidden by the %q symlink policy", currentPath, s.Symlinks)
+		err = os.ErrNotExist
+	}
+
 	if err != nil {
 		// If the path doesn't exist, return the 404 error but also print in the log
 		// of the app the full path to the given location
 		if os.IsNotExist(err) {
+			// Client-side routes of single-page applications don't
+			// exist on disk, so they're served the fallback file
+			if s.shouldServeSPAFallback(r) {
+				s.serveFile(0, s.spaFallbackPath, w, r)
+				return
+			}
+
 			s.printWarningf("attempted to access non-existent path: %s", currentPath)
 
 			// Overwrite custom page if one was set
@@ -117,6 +130,10 @@ func (s *Server) serveMarkdown(requestedPath string, w http.ResponseWriter, r *h
 	// Define the parent directory
 	parent := getParentURL(s.PathPrefix, r.URL.Path)
 
+	// Set the caching policy for the rendered markdown
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	s.setCacheControl(w, s.requestPath(r), "text/html; charset=utf-8")
+
 	// Render the directory listing
 	content := map[string]any{
 		"DirectoryRootPath":      s.PathPrefix,
@@ -144,11 +161,12 @@ func (s *Server) serveMarkdown(requestedPath string, w http.ResponseWriter, r *h
 
 // FileInfo represents a file's metadata for JSON output
 type FileInfo struct {
-	Name        string `json:"name"`
-	Size        int64  `json:"size"`
-	IsDirectory bool   `json:"is_directory"`
-	ModTime     string `json:"mod_time"`
-	Path        string `json:"path"`
+	Name          string `json:"name"`
+	Size          int64  `json:"size"`
+	IsDirectory   bool   `json:"is_directory"`
+	ModTime       string `json:"mod_time"`
+	Path          string `json:"path"`
+	SymlinkTarget string `json:"symlink_target,omitempty"`
 }
 
 func (s *Server) walk(requestedPath string, w http.ResponseWriter, r *http.Request) {
@@ -156,15 +174,21 @@ func (s *Server) walk(requestedPath string, w http.ResponseWriter, r *http.Reque
 	// file exists, if so, return it instead
 	for _, index := range []string{"index.html", "index.htm"} {
 		indexPath := filepath.Join(requestedPath, index)
-		if _, err := os.Stat(indexPath); err == nil { //nolint:gosec // index filename is hardcoded, not user-controlled
+		if _, err := os.Stat(indexPath); err == nil && s.symlinkAllowed(indexPath) { //nolint:gosec // index filename is hardcoded, not user-controlled
 			s.serveFile(0, indexPath, w, r)
 			return
 		}
 	}
 
 	// Check if directory listing is disabled, if so,
-	// return here with a 404 error
+	// return here with a 404 error, unless the directory
+	// is handled by a single-page application
 	if s.DisableDirectoryList {
+		if s.shouldServeSPAFallback(r) {
+			s.serveFile(0, s.spaFallbackPath, w, r)
+			return
+		}
+
 		httpErrorf(http.StatusNotFound, w, "404 not found")
 		return
 	}
@@ -199,6 +223,27 @@ func (s *Server) walk(requestedPath string, w http.ResponseWriter, r *http.Reque
 	// Render the directory listing
 	sort.Sort(isort.FoldersFirst(list))
 
+	// Keep track of the files in the directory, so precompressed
+	// versions of them can be hidden from the listing when they're
+	// served in their place
+	names := make(map[string]bool, len(list))
+	if s.Precompressed {
+		for _, f := range list {
+			if !f.IsDir() {
+				names[f.Name()] = true
+			}
+		}
+	}
+
+	// Find the path of the directory relative to the root of the served
+	// files, to check which files within it are hidden
+	relDir := s.requestPath(r)
+	if root, err := filepath.Abs(s.Path); err == nil {
+		if rel, inside := relativePath(root, requestedPath); inside {
+			relDir = filepath.ToSlash(rel)
+		}
+	}
+
 	// Generate a list of FileInfo objects
 	files := make([]os.FileInfo, 0, len(list))
 	for _, f := range list {
@@ -209,8 +254,23 @@ func (s *Server) walk(requestedPath string, w http.ResponseWriter, r *http.Reque
 			return
 		}
 
-		// Check if file starts with config prefix
-		if s.isFiltered(fi.Name()) {
+		// List symbolic links as the files they point to, if the
+		// symlink policy allows it
+		if fi.Mode()&os.ModeSymlink != 0 {
+			resolved, listed := s.resolveSymlink(filepath.Join(requestedPath, fi.Name()), fi)
+			if !listed {
+				continue
+			}
+			fi = resolved
+		}
+
+		// Skip hidden files, like the config file or excluded ones
+		if s.isHidden(path.Join(relDir, fi.Name()), fi.IsDir()) {
+			continue
+		}
+
+		// Skip precompressed versions of other files
+		if !fi.IsDir() && isPrecompressedSidecar(fi.Name(), names) {
 			continue
 		}
 
@@ -229,6 +289,9 @@ func (s *Server) walk(requestedPath string, w http.ResponseWriter, r *http.Reque
 			Logger:      s.LogOutput,
 		}
 
+		// Set the caching policy for the listing
+		s.setCacheControl(w, s.requestPath(r), renderer.ContentType(outputFormat))
+
 		// Render the directory listing
 		if err := renderer.Render(outputFormat, config, w, files); err != nil {
 			if errors.Is(err, renderer.UnsupportedFormatError{}) {
@@ -256,6 +319,10 @@ func (s *Server) walk(requestedPath string, w http.ResponseWriter, r *http.Reque
 	// Define the parent directory
 	parent := getParentURL(s.PathPrefix, r.URL.Path)
 
+	// Set the caching policy for the listing
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	s.setCacheControl(w, s.requestPath(r), "text/html; charset=utf-8")
+
 	// Render the directory listing
 	content := map[string]any{
 		"DirectoryRootPath": s.PathPrefix,
@@ -293,10 +360,18 @@ func (s *statusCodeHijacker) WriteHeader(code int) {
 }
 
 // serveFile serves a file with the appropriate headers, including support
-// for ETag and Last-Modified headers, as well as range requests.
+// for ETag and Last-Modified headers, as well as range and conditional
+// requests.
 // If the status code is not 0, the status code provided will be used
 // when serving the file in the given path.
 func (s *Server) serveFile(statusCode int, location string, w http.ResponseWriter, r *http.Request) {
+	// Don't serve files through symbolic links forbidden by the policy
+	if !s.symlinkAllowed(location) {
+		s.printWarningf("symbolic link in path %q is forbidden by the %q symlink policy", location, s.Symlinks)
+		httpErrorf(http.StatusNotFound, w, "404 not found")
+		return
+	}
+
 	f, err := os.Open(location) //nolint:gosec // file server: location is derived from the serving root, not raw user input
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -315,44 +390,16 @@ func (s *Server) serveFile(statusCode int, location string, w http.ResponseWrite
 		return
 	}
 
-	var contentType string
-	if local := ctype.GetContentTypeForFilename(filepath.Base(location)); local != "" {
-		contentType = local
-	}
-
-	var data [512]byte
-	n, err := f.Read(data[:])
-	if err != nil && !errors.Is(err, io.EOF) {
+	// Find the content type of the file, and its content if it's
+	// small enough to be kept in memory
+	details, err := s.fileDetails(location, f, fi)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Only detect content type if we have content to examine
-	if contentType == "" && n > 0 {
-		if local := http.DetectContentType(data[:n]); local != "application/octet-stream" {
-			contentType = local
-		}
-	}
-
-	// Only attempt charset detection if we have content to examine
-	charset := ""
-	if n > 0 && contentType != "" && !strings.HasPrefix(contentType, "application/octet-stream") {
-		if utf8.Valid(data[:n]) {
-			charset = "utf-8"
-		} else {
-			res, err := chardet.NewTextDetector().DetectBest(data[:n])
-			if err == nil && res.Confidence > 50 && res.Charset != "" {
-				charset = res.Charset
-			}
-		}
-	}
-
+	contentType := details.contentType
 	if contentType != "" {
-		// Add charset for text-based content types only
-		if charset != "" && contentType != "application/octet-stream" {
-			contentType += "; charset=" + charset
-		}
-
 		w.Header().Set("Content-Type", contentType)
 	}
 
@@ -362,16 +409,62 @@ func (s *Server) serveFile(statusCode int, location string, w http.ResponseWrite
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
 	}
 
-	// Reset file position to beginning after reading first bytes for content detection
-	if _, err := f.Seek(0, io.SeekStart); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	// Serve the cached content of the file if available
+	var content io.ReadSeeker = f
+	if details.content != nil {
+		content = details.reader()
+	}
+
+	// Keep track of the file being sent to generate its ETag
+	served, servedInfo := location, fi
+
+	// Serve a precompressed version of the file instead if enabled and
+	// there's one the client accepts, keeping the content type of the
+	// original file
+	var compressed *os.File
+	var encoding string
+	if s.Precompressed {
+		var hasVariants bool
+		compressed, encoding, hasVariants = openPrecompressed(location, fi, r)
+		if hasVariants {
+			compression.AddVary(w.Header(), "Accept-Encoding")
+		}
+	}
+
+	if compressed != nil && !s.symlinkAllowed(compressed.Name()) {
+		compressed.Close()
+		compressed = nil
+	}
+
+	if compressed != nil {
+		defer compressed.Close()
+
+		if contentType == "" {
+			w.Header().Set("Content-Type", sniffContentType(fi.Name(), content))
+		}
+
+		w.Header().Set("Content-Encoding", encoding)
+		content = compressed
+
+		if info, err := compressed.Stat(); err == nil {
+			served, servedInfo = compressed.Name(), info
+		}
 	}
 
 	// Check if the caller changed the status code, if not, simply call
 	// the appropriate handler/
 	if statusCode == 0 {
-		http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+		// Set a strong ETag for the file, regardless of its size, which
+		// is used to handle conditional and range requests
+		if tag := s.fileETag(served, servedInfo); tag != "" {
+			w.Header().Set("ETag", tag)
+		}
+
+		// Set the caching policy based on the original file, even if
+		// a precompressed version of it is served
+		s.setCacheControl(w, s.servedPath(location, r), contentType)
+
+		http.ServeContent(w, r, fi.Name(), fi.ModTime(), content)
 		return
 	}
 
@@ -380,11 +473,17 @@ func (s *Server) serveFile(statusCode int, location string, w http.ResponseWrite
 
 	// Call serve content with the hijacked response writer, which won't
 	// be able to overwrite the status code.
-	http.ServeContent(&statusCodeHijacker{ResponseWriter: w}, r, fi.Name(), fi.ModTime(), f)
+	http.ServeContent(&statusCodeHijacker{ResponseWriter: w}, r, fi.Name(), fi.ModTime(), content)
 }
 
-// healthCheck is a simple health check endpoint that returns 200 OK
+// healthCheck is a simple health check endpoint that returns 200 OK,
+// or 503 Service Unavailable while draining connections before stopping
 func (s *Server) healthCheck(w http.ResponseWriter, _ *http.Request) {
+	if s.isDraining() {
+		httpErrorf(http.StatusServiceUnavailable, w, "Draining")
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
@@ -2,8 +2,11 @@ package server
 
 import (
 	"html/template"
+	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/patrickdappollonio/http-server/internal/fileutil"
 )
 
 // filenameToIcon is a map of filenames to their respective icons
@@ -140,9 +143,20 @@ var groupToIcon = map[string]string{
 	"compressed": "fas fa-file-zipper",
 }
 
-// getIconForFile returns the icon class for a file based on its name
+// getIconForFile returns the icon class for a file based on its name,
+// whether it's a folder or not, and whether it's a symbolic link.
+func getIconForFile(file os.FileInfo) template.HTMLAttr {
+	// Symbolic links have their own icon, regardless of their target
+	if fileutil.SymlinkTarget(file) != "" {
+		return template.HTMLAttr("fas fa-link")
+	}
+
+	return getIconForName(file.IsDir(), file.Name())
+}
+
+// getIconForName returns the icon class for a file based on its name
 // and whether it's a folder or not.
-func getIconForFile(isFolder bool, filename string) template.HTMLAttr {
+func getIconForName(isFolder bool, filename string) template.HTMLAttr {
 	// If it's a folder, it's a quick find
 	if isFolder {
 		return template.HTMLAttr("fas fa-folder")
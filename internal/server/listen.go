@@ -0,0 +1,186 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// unixSocketPrefix is the prefix of listen addresses for Unix domain sockets
+const unixSocketPrefix = "unix:"
+
+// listenAddress is a validated address to listen on, either a TCP
+// address or the path to a Unix domain socket.
+type listenAddress struct {
+	network string
+	address string
+}
+
+// String returns the address in the format used by the listen flag.
+func (a listenAddress) String() string {
+	if a.network == "unix" {
+		return unixSocketPrefix + a.address
+	}
+
+	return a.address
+}
+
+// parseListenAddress parses an address like "host:port", "[::1]:port" or
+// "unix:/path/to/file.sock".
+func parseListenAddress(value string) (listenAddress, error) {
+	if socket, found := strings.CutPrefix(value, unixSocketPrefix); found {
+		if socket == "" {
+			return listenAddress{}, fmt.Errorf("invalid listen address %q: the path to the socket file is required", value)
+		}
+
+		return listenAddress{network: "unix", address: socket}, nil
+	}
+
+	host, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return listenAddress{}, fmt.Errorf("invalid listen address %q: use \"host:port\", \"[ipv6]:port\" or \"unix:/path/to/file.sock\": %w", value, err)
+	}
+
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || (n == 0 && port != "0") {
+		return listenAddress{}, fmt.Errorf("invalid listen address %q: port %q must be a number between 0 and 65535", value, port)
+	}
+
+	if strings.ContainsAny(host, "/ ") {
+		return listenAddress{}, fmt.Errorf("invalid listen address %q: host %q must be a hostname or an IP address", value, host)
+	}
+
+	return listenAddress{network: "tcp", address: net.JoinHostPort(host, port)}, nil
+}
+
+// validateListeners parses the addresses to listen on, defaulting to all
+// the interfaces on the configured port, and the permissions of the Unix
+// domain sockets.
+func (s *Server) validateListeners() error {
+	s.listeners = nil
+
+	if len(s.Listen) == 0 {
+		s.listeners = []listenAddress{{network: "tcp", address: fmt.Sprintf(":%d", s.Port)}}
+	}
+
+	seen := make(map[listenAddress]bool, len(s.Listen))
+	for _, value := range s.Listen {
+		addr, err := parseListenAddress(strings.TrimSpace(value))
+		if err != nil {
+			return err
+		}
+
+		if seen[addr] {
+			return fmt.Errorf("listen address %q is used more than once", value)
+		}
+
+		seen[addr] = true
+		s.listeners = append(s.listeners, addr)
+	}
+
+	if s.SocketMode != "" {
+		mode, err := strconv.ParseUint(s.SocketMode, 8, 32)
+		if err != nil || mode > 0o777 {
+			return fmt.Errorf("invalid socket mode %q: must be octal permissions, like \"0660\"", s.SocketMode)
+		}
+
+		s.socketMode = fs.FileMode(mode)
+	}
+
+	return nil
+}
+
+// listen opens a listener for each of the configured addresses. If any of
+// them can't be opened, the ones already open are closed.
+func (s *Server) listen() ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(s.listeners))
+
+	for _, addr := range s.listeners {
+		l, err := s.listenOn(addr)
+		if err != nil {
+			for _, open := range listeners {
+				open.Close()
+			}
+
+			return nil, err
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+// listenOn opens a listener for the address. Sockets left behind by a
+// previous run are removed, and new sockets get the configured
+// permissions. Sockets are removed when their listener is closed.
+//
+//nolint:ireturn // TCP and Unix listeners are returned alike, to be served the same way
+func (s *Server) listenOn(addr listenAddress) (net.Listener, error) {
+	if addr.network != "unix" {
+		l, err := net.Listen(addr.network, addr.address)
+		if err != nil {
+			return nil, fmt.Errorf("unable to listen on %s: %w", addr, err)
+		}
+
+		return l, nil
+	}
+
+	if fi, err := os.Lstat(addr.address); err == nil {
+		if fi.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("unable to listen on %s: file exists and it isn't a socket", addr)
+		}
+
+		// Only remove sockets nobody is listening on anymore, so a
+		// second instance doesn't take over the socket of a running one
+		if err := checkStaleSocket(addr.address); err != nil {
+			return nil, fmt.Errorf("unable to listen on %s: %w", addr, err)
+		}
+
+		if err := os.Remove(addr.address); err != nil {
+			return nil, fmt.Errorf("unable to remove stale socket %s: %w", addr, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("unable to listen on %s: %w", addr, err)
+	}
+
+	l, err := net.Listen(addr.network, addr.address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on %s: %w", addr, err)
+	}
+
+	if s.socketMode != 0 {
+		if err := os.Chmod(addr.address, s.socketMode); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("unable to set permissions of socket %s: %w", addr, err)
+		}
+	}
+
+	return l, nil
+}
+
+// staleSocketTimeout is how long to wait for an existing socket to accept
+// a connection, before considering it in use by an unresponsive process.
+const staleSocketTimeout = time.Second
+
+// checkStaleSocket checks the socket at the given location was left behind
+// by a process that's no longer running, by connecting to it: only a
+// refused connection means it can be safely removed.
+func checkStaleSocket(location string) error {
+	conn, err := net.DialTimeout("unix", location, staleSocketTimeout)
+	if err == nil {
+		conn.Close()
+		return errors.New("socket is in use by another process")
+	}
+
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("unable to check if the existing socket is in use: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateListeners(t *testing.T) {
+	tests := []struct {
+		name       string
+		listen     []string
+		socketMode string
+		want       []string
+		wantError  string
+	}{
+		{name: "all interfaces on the port by default", want: []string{":5000"}},
+		{name: "host and port", listen: []string{"127.0.0.1:8080"}, want: []string{"127.0.0.1:8080"}},
+		{name: "all interfaces", listen: []string{":8080"}, want: []string{":8080"}},
+		{name: "hostname", listen: []string{"localhost:8080"}, want: []string{"localhost:8080"}},
+		{name: "ipv6", listen: []string{"[::1]:8080"}, want: []string{"[::1]:8080"}},
+		{name: "unix socket", listen: []string{"unix:/run/http-server.sock"}, want: []string{"unix:/run/http-server.sock"}},
+		{
+			name:   "multiple addresses",
+			listen: []string{"127.0.0.1:8080", "[::1]:8080", "unix:http.sock"},
+			want:   []string{"127.0.0.1:8080", "[::1]:8080", "unix:http.sock"},
+		},
+		{name: "socket mode", listen: []string{"unix:http.sock"}, socketMode: "0600", want: []string{"unix:http.sock"}},
+		{name: "missing port", listen: []string{"127.0.0.1"}, wantError: "missing port"},
+		{name: "ipv6 without brackets", listen: []string{"::1:8080"}, wantError: "too many colons"},
+		{name: "invalid port", listen: []string{"127.0.0.1:http"}, wantError: "must be a number"},
+		{name: "port out of range", listen: []string{"127.0.0.1:70000"}, wantError: "must be a number"},
+		{name: "missing socket path", listen: []string{"unix:"}, wantError: "path to the socket file is required"},
+		{name: "duplicated address", listen: []string{":8080", ":8080"}, wantError: "used more than once"},
+		{name: "invalid socket mode", socketMode: "rw-rw----", wantError: "invalid socket mode"},
+		{name: "socket mode out of range", socketMode: "1777", wantError: "invalid socket mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{Port: 5000, Listen: tt.listen, SocketMode: tt.socketMode}
+
+			err := s.validateListeners()
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			got := make([]string, 0, len(s.listeners))
+			for _, addr := range s.listeners {
+				got = append(got, addr.String())
+			}
+
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Fatalf("expected addresses %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestServeMultipleListeners(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("content"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	socket := filepath.Join(dir, "http.sock")
+
+	// Leave a stale socket behind, as if a previous run crashed
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("unable to create stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	s := &Server{
+		Port:         5000,
+		Path:         dir,
+		PathPrefix:   "/",
+		LogOutput:    io.Discard,
+		ETagMaxSize:  "5M",
+		ETagDisabled: true,
+		Listen:       []string{"127.0.0.1:0", "unix:" + socket},
+		SocketMode:   "0600",
+	}
+
+	if err := s.Validate(); err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	if err := s.prepare(); err != nil {
+		t.Fatalf("unable to prepare server: %v", err)
+	}
+
+	listeners, err := s.listen()
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+
+	fi, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("unable to stat socket: %v", err)
+	}
+
+	if mode := fi.Mode().Perm(); mode != 0o600 {
+		t.Fatalf("expected socket permissions %v, got %v", fs.FileMode(0o600), mode)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.serve(ctx, listeners, s.handler()) }()
+
+	clients := map[string]*http.Client{
+		"tcp": http.DefaultClient,
+		"unix": {Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}},
+	}
+
+	urls := map[string]string{
+		"tcp":  "http://" + listeners[0].Addr().String() + "/file.txt",
+		"unix": "http://unix/file.txt",
+	}
+
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			resp, err := client.Get(urls[name])
+			if err != nil {
+				t.Fatalf("unable to request file: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK || string(body) != "content" {
+				t.Fatalf("expected status 200 with %q, got %d with %q", "content", resp.StatusCode, body)
+			}
+		})
+	}
+
+	stop()
+	if err := <-served; err != nil {
+		t.Fatalf("not expecting error on shutdown, got: %v", err)
+	}
+
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Fatalf("expected socket to be removed on shutdown, got: %v", err)
+	}
+
+	if _, err := net.Dial("tcp", listeners[0].Addr().String()); err == nil {
+		t.Fatalf("expected tcp listener to be closed on shutdown")
+	}
+}
+
+func TestListenOnExistingFile(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "file.sock")
+	if err := os.WriteFile(location, []byte("not a socket"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	s := &Server{Listen: []string{"127.0.0.1:0", "unix:" + location}}
+	if err := s.validateListeners(); err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	if _, err := s.listen(); err == nil || !strings.Contains(err.Error(), "isn't a socket") {
+		t.Fatalf("expected error about the existing file, got: %v", err)
+	}
+
+	if _, err := os.Stat(location); err != nil {
+		t.Fatalf("expected existing file to be kept, got: %v", err)
+	}
+}
+
+func TestListenOnSocketInUse(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "http.sock")
+
+	running, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("unable to listen on socket: %v", err)
+	}
+	defer running.Close()
+
+	s := &Server{Listen: []string{"unix:" + socket}}
+	if err := s.validateListeners(); err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	if _, err := s.listen(); err == nil || !strings.Contains(err.Error(), "in use by another process") {
+		t.Fatalf("expected error about the socket in use, got: %v", err)
+	}
+
+	// The socket of the running process is kept
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("expected socket in use to be kept, got: %v", err)
+	}
+	conn.Close()
+}
+
+func TestServeNotifiesSystemd(t *testing.T) {
+	dir := t.TempDir()
+
+	notifications, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: filepath.Join(dir, "notify.sock"), Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("unable to create notification socket: %v", err)
+	}
+	defer notifications.Close()
+
+	t.Setenv("NOTIFY_SOCKET", filepath.Join(dir, "notify.sock"))
+
+	s := &Server{Path: dir, PathPrefix: "/", LogOutput: io.Discard, ETagDisabled: true}
+	if err := s.prepare(); err != nil {
+		t.Fatalf("unable to prepare server: %v", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.serve(ctx, []net.Listener{l}, s.handler()) }()
+
+	read := func() string {
+		buf := make([]byte, 64)
+		n, err := notifications.Read(buf)
+		if err != nil {
+			t.Fatalf("unable to read notification: %v", err)
+		}
+		return string(buf[:n])
+	}
+
+	if got := read(); got != "READY=1" {
+		t.Fatalf("expected %q once serving, got %q", "READY=1", got)
+	}
+
+	stop()
+
+	if got := read(); got != "STOPPING=1" {
+		t.Fatalf("expected %q when stopping, got %q", "STOPPING=1", got)
+	}
+
+	if err := <-served; err != nil {
+		t.Fatalf("not expecting error on shutdown, got: %v", err)
+	}
+}
@@ -2,15 +2,20 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
+
+	"github.com/patrickdappollonio/http-server/internal/systemd"
 )
 
-func (s *Server) ListenAndServe() error {
+// prepare generates the templates, cache buster and markdown renderer
+// used to handle requests, for the server and its virtual hosts.
+func (s *Server) prepare() error {
 	// Generate the appropriate templates for the entire server
 	dltemplates, err := s.generateTemplates()
 	if err != nil {
@@ -23,39 +28,108 @@ func (s *Server) ListenAndServe() error {
 		s.cacheBuster = s.version
 	}
 
-	// Set up an initial server
-	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", s.Port),
-		Handler: s.router(),
+	// Configure the markdown renderer once, now that the path to
+	// the assets is known
+	s.markdown = s.newMarkdownRenderer()
+
+	for _, vh := range s.vhosts {
+		if err := vh.server.prepare(); err != nil {
+			return err
+		}
 	}
 
-	// Create a signal to wait for an error
-	done := make(chan error, 1)
+	return nil
+}
 
-	// Start the server asynchronously
-	go func() {
-		fmt.Fprintln(s.LogOutput, "Starting server...")
-		if err := srv.ListenAndServe(); err != nil {
-			if err != http.ErrServerClosed {
-				done <- fmt.Errorf("unable to start server: %w", err)
-			} else {
-				fmt.Fprintln(s.LogOutput, "Server closed. Bye!")
-			}
-		}
-	}()
+// ListenAndServe serves the files on every configured address until the
+// process receives an interrupt or termination signal.
+func (s *Server) ListenAndServe() error {
+	if err := s.prepare(); err != nil {
+		return err
+	}
+
+	// Use the sockets passed by systemd if the server was started
+	// through socket activation, or open every listener before
+	// serving, so a wrong address fails the startup rather than a
+	// single listener
+	listeners, err := systemd.Listeners()
+	if err != nil {
+		return err
+	}
+
+	if len(listeners) > 0 {
+		fmt.Fprintf(s.LogOutput, "Using %d sockets passed by systemd, ignoring the configured addresses\n", len(listeners))
+	} else if listeners, err = s.listen(); err != nil {
+		return err
+	}
+
+	// Serve requests through a handler that can be swapped when the
+	// configuration file changes
+	handler := newReloadableHandler(s.handler())
+
+	// Watch the configuration and redirections files for changes
+	// until the server stops
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	s.watchConfig(watchCtx, handler)
 
 	// Wait for a closing signal
-	go func() {
-		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-		defer cancel()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	return s.serve(ctx, listeners, handler)
+}
+
+// serve serves the same handler on all the listeners until the context is
+// done, then gracefully shuts down all of them. If any listener fails,
+// the others are closed.
+func (s *Server) serve(ctx context.Context, listeners []net.Listener, handler http.Handler) error {
+	srv := s.httpServer(handler)
+
+	// Start serving asynchronously on every listener
+	fmt.Fprintln(s.LogOutput, "Starting server...")
+	failed := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func() {
+			if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				failed <- fmt.Errorf("unable to start server on %s: %w", l.Addr(), err)
+			}
+		}()
+	}
 
-		<-ctx.Done()
-		fmt.Fprintln(s.LogOutput, "Requesting server to stop. Please wait...")
+	// Tell systemd the server is ready, when running as a service
+	s.notify("READY=1")
 
-		nctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-		defer cancel()
-		done <- srv.Shutdown(nctx)
-	}()
+	select {
+	case err := <-failed:
+		srv.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Fprintln(s.LogOutput, "Requesting server to stop. Please wait...")
+	s.notify("STOPPING=1")
+	s.drain(srv)
+
+	// Wait for in-flight requests, closing the remaining connections
+	// if they take too long
+	nctx, cancel := s.shutdownContext()
+	defer cancel()
+	if err := srv.Shutdown(nctx); err != nil {
+		srv.Close()
+		return fmt.Errorf("unable to stop server gracefully: %w", err)
+	}
+
+	s.printCacheStats()
+	s.saveETagHashes()
+	fmt.Fprintln(s.LogOutput, "Server closed. Bye!")
+	return nil
+}
 
-	return <-done
+// notify sends a state change to systemd, if the server runs as a
+// service expecting notifications.
+func (s *Server) notify(state string) {
+	if _, err := systemd.Notify(state); err != nil {
+		s.printWarningf("unable to notify systemd: %s", err)
+	}
 }
@@ -33,14 +33,45 @@ func (s *Server) renderMarkdownFile(location string, v *bytes.Buffer) error {
 	// Close the file when we're done
 	defer f.Close()
 
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to stat markdown file %q: %w", location, err)
+	}
+
+	// Reuse the rendered markdown if the file hasn't changed
+	key := cacheKey(cacheKindMarkdown, location, fi)
+	if entry, found := s.cache.Get(key); found {
+		v.Write(entry.content)
+		return nil
+	}
+
 	// Copy the file contents to an intermediate buffer
 	var buf bytes.Buffer
 	if _, err := io.Copy(&buf, f); err != nil {
 		return fmt.Errorf("unable to read markdown file %q: %w", location, err)
 	}
 
-	// Configure goldmark
-	md := goldmark.New(
+	// Render the markdown, reusing the renderer configured at startup
+	md := s.markdown
+	if md == nil {
+		md = s.newMarkdownRenderer()
+	}
+
+	var rendered bytes.Buffer
+	if err := md.Convert(buf.Bytes(), &rendered); err != nil {
+		return fmt.Errorf("unable to render markdown file %q: %w", location, err)
+	}
+
+	s.cache.Add(key, cachedFile{content: rendered.Bytes()}, int64(len(location)+rendered.Len()))
+	v.Write(rendered.Bytes())
+	return nil
+}
+
+// newMarkdownRenderer configures goldmark to render markdown files.
+//
+//nolint:ireturn // goldmark only exposes its renderer as an interface
+func (s *Server) newMarkdownRenderer() goldmark.Markdown {
+	return goldmark.New(
 		goldmark.WithExtensions(
 			extension.GFM, // enables Table, Strikethrough, Linkify and TaskList
 			extension.Footnote,
@@ -61,13 +92,6 @@ func (s *Server) renderMarkdownFile(location string, v *bytes.Buffer) error {
 			html.WithUnsafe(),
 		),
 	)
-
-	// Render the markdown
-	if err := md.Convert(buf.Bytes(), v); err != nil {
-		return fmt.Errorf("unable to render markdown file %q: %w", location, err)
-	}
-
-	return nil
 }
 
 // generateMarkdown generates the markdown needed to render the content
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/patrickdappollonio/http-server/internal/renderer"
+)
+
+// Mount is a directory served under its own path prefix, alongside other
+// mounts served by the same process. Mounts are configured through the
+// configuration file.
+type Mount struct {
+	PathPrefix   string `mapstructure:"prefix"`
+	SiteSettings `mapstructure:",squash"`
+}
+
+// validateMounts validates each mount and creates the server handling it,
+// based on the top-level settings. The in-memory cache and the content
+// hashes for ETags are shared by all mounts.
+func (s *Server) validateMounts() error {
+	s.mounts = nil
+	if len(s.Mounts) == 0 {
+		return nil
+	}
+
+	var merrs MultiError
+	seen := make(map[string]bool, len(s.Mounts))
+
+	for i, m := range s.Mounts {
+		child, err := s.newMount(m)
+		if err != nil {
+			merrs.Append(fmt.Errorf("invalid mount #%d (%q): %w", i+1, m.PathPrefix, err))
+			continue
+		}
+
+		if seen[child.PathPrefix] {
+			merrs.Append(fmt.Errorf("invalid mount #%d: prefix %q is used by another mount", i+1, child.PathPrefix))
+			continue
+		}
+
+		seen[child.PathPrefix] = true
+		s.mounts = append(s.mounts, child)
+	}
+
+	if len(merrs.Errors) == 0 {
+		return nil
+	}
+
+	return &merrs
+}
+
+// newMount creates the server for a mount, validating its settings.
+func (s *Server) newMount(m Mount) (*Server, error) {
+	if m.PathPrefix == "" {
+		return nil, errors.New("prefix is required")
+	}
+
+	if m.Path == "" {
+		return nil, errors.New("path is required")
+	}
+
+	// Mounts are served under the top-level path prefix
+	prefix := path.Join("/", s.PathPrefix, m.PathPrefix) + "/"
+	if prefix == path.Join("/", s.PathPrefix)+"/" {
+		return nil, errors.New("prefix can't be the root of the server, where the list of mounts is shown")
+	}
+
+	return s.newSite(m.SiteSettings, func(child *Server) {
+		child.PathPrefix = prefix
+	})
+}
+
+// mountRoutes registers the routes of every mount, each of them with its
+// own middlewares, alongside a page listing the mounts at the root.
+func (s *Server) mountRoutes(r chi.Router) {
+	for _, m := range s.mounts {
+		// Templates and assets are shared, and served from the root
+		m.templates, m.markdown, m.cacheBuster = s.templates, s.markdown, s.cacheBuster
+		r.Group(m.routes)
+	}
+
+	basicAuth, jwtAuth := s.authMiddlewares()
+	r.With(basicAuth, jwtAuth).HandleFunc(s.PathPrefix, s.listMounts)
+
+	assetsPrefix := path.Join(s.PathPrefix, specialPath, s.cacheBuster)
+	r.HandleFunc(path.Join(assetsPrefix, "assets", "*"), s.serveAssets(assetsPrefix))
+	s.statusRoutes(r)
+}
+
+// mountInfo describes a mount as a directory in the list of mounts.
+type mountInfo struct {
+	os.FileInfo
+	name string
+}
+
+// Name returns the path prefix of the mount, without slashes.
+func (m mountInfo) Name() string {
+	return m.name
+}
+
+// listMounts renders the list of mounts, as a directory listing where
+// each mount is a directory.
+func (s *Server) listMounts(w http.ResponseWriter, r *http.Request) {
+	files := make([]os.FileInfo, 0, len(s.mounts))
+	for _, m := range s.mounts {
+		fi, err := os.Stat(m.Path)
+		if err != nil {
+			s.printWarningf("unable to stat mount %q at %q: %s", m.PathPrefix, m.Path, err)
+			continue
+		}
+
+		rel := strings.TrimPrefix(m.PathPrefix, s.PathPrefix)
+		files = append(files, mountInfo{FileInfo: fi, name: strings.Trim(rel, "/")})
+	}
+
+	if outputFormat := r.URL.Query().Get("output"); outputFormat != "" {
+		config := renderer.Config{
+			CurrentPath: r.URL.Path,
+			Logger:      s.LogOutput,
+		}
+
+		if err := renderer.Render(outputFormat, config, w, files); err != nil {
+			if errors.Is(err, renderer.UnsupportedFormatError{}) {
+				httpErrorf(http.StatusBadRequest, w, "unsupported output format: %q (supported formats: %s)",
+					outputFormat, renderer.GetSupportedFormatsString())
+				return
+			}
+
+			s.printWarningf("error rendering list of mounts: %s", err)
+			httpErrorf(http.StatusInternalServerError, w, "error rendering list of mounts -- see application logs for more information")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	content := map[string]any{
+		"DirectoryRootPath": s.PathPrefix,
+		"PageTitle":         s.PageTitle,
+		"CurrentPath":       r.URL.Path,
+		"CacheBuster":       s.cacheBuster,
+		"IsRoot":            true,
+		"Files":             files,
+		"ShouldRenderFiles": true,
+		"HideLinks":         s.HideLinks,
+	}
+
+	if err := s.templates.ExecuteTemplate(w, "app.tmpl", content); err != nil {
+		s.printWarningf("unable to render list of mounts: %s", err)
+		httpErrorf(http.StatusInternalServerError, w, "unable to render list of mounts -- see application logs for more information")
+	}
+}
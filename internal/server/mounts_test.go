@@ -0,0 +1,196 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMounts(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string]string{
+		"docs/guide.txt":      "guide",
+		"docs/_redirections":  "/docs/old /docs/guide.txt 302\n",
+		"artifacts/build.bin": "build",
+		"private/secret.txt":  "secret",
+		"top.txt":             "top",
+	}
+
+	for name, content := range files {
+		location := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+		if err := os.WriteFile(location, []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	yes := true
+
+	s := &Server{
+		Port:         5000,
+		Path:         root,
+		PathPrefix:   "/",
+		LogOutput:    io.Discard,
+		PageTitle:    "All files",
+		ETagMaxSize:  "5M",
+		ETagDisabled: true,
+		Mounts: []Mount{
+			{PathPrefix: "/docs/", SiteSettings: SiteSettings{Path: filepath.Join(root, "docs"), PageTitle: "Documentation"}},
+			{PathPrefix: "artifacts", SiteSettings: SiteSettings{Path: filepath.Join(root, "artifacts"), DisableDirectoryList: &yes}},
+			{PathPrefix: "/private/", SiteSettings: SiteSettings{Path: filepath.Join(root, "private"), Username: "user", Password: "pass"}},
+		},
+	}
+
+	if err := s.Validate(); err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	templates, err := s.generateTemplates()
+	if err != nil {
+		t.Fatalf("unable to generate templates: %v", err)
+	}
+	s.templates = templates
+
+	handler := s.router()
+
+	tests := []struct {
+		name           string
+		path           string
+		auth           bool
+		expectCode     int
+		expectBody     string
+		expectLocation string
+	}{
+		{name: "file in a mount", path: "/docs/guide.txt", expectCode: http.StatusOK, expectBody: "guide"},
+		{name: "listing with the title of the mount", path: "/docs/", expectCode: http.StatusOK, expectBody: "<title>Documentation</title>"},
+		{name: "redirections of a mount", path: "/docs/old", expectCode: http.StatusFound, expectLocation: "/docs/guide.txt"},
+		{name: "mount without trailing slash", path: "/docs", expectCode: http.StatusMovedPermanently, expectLocation: "/docs/"},
+		{name: "prefix without slashes", path: "/artifacts/build.bin", expectCode: http.StatusOK, expectBody: "build"},
+		{name: "listing disabled in a mount", path: "/artifacts/", expectCode: http.StatusNotFound},
+		{name: "mount with authentication", path: "/private/secret.txt", expectCode: http.StatusUnauthorized},
+		{name: "mount with credentials", path: "/private/secret.txt", auth: true, expectCode: http.StatusOK, expectBody: "secret"},
+		{name: "top-level path not served", path: "/top.txt", expectCode: http.StatusNotFound},
+		{name: "unknown path", path: "/other/file.txt", expectCode: http.StatusNotFound},
+		{name: "list of mounts", path: "/", expectCode: http.StatusOK, expectBody: "<title>All files</title>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth {
+				req.SetBasicAuth("user", "pass")
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectCode {
+				t.Fatalf("expected status code %d, got %d - response: %s", tt.expectCode, rec.Code, rec.Body.String())
+			}
+
+			if tt.expectBody != "" && !strings.Contains(rec.Body.String(), tt.expectBody) {
+				t.Fatalf("expected body to contain %q, got %q", tt.expectBody, rec.Body.String())
+			}
+
+			if loc := rec.Header().Get("Location"); loc != tt.expectLocation {
+				t.Fatalf("expected location %q, got %q", tt.expectLocation, loc)
+			}
+		})
+	}
+
+	t.Run("list of mounts as json", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?output=json", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var listing struct {
+			Files []FileInfo `json:"files"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+			t.Fatalf("unable to parse listing %q: %v", rec.Body.String(), err)
+		}
+
+		paths := make([]string, 0, len(listing.Files))
+		for _, f := range listing.Files {
+			paths = append(paths, f.Path)
+		}
+
+		expected := []string{"/docs/", "/artifacts/", "/private/"}
+		if !slices.Equal(paths, expected) {
+			t.Fatalf("expected mounts %v, got %v", expected, paths)
+		}
+	})
+}
+
+func TestValidateMounts(t *testing.T) {
+	root := t.TempDir()
+
+	tests := []struct {
+		name       string
+		pathPrefix string
+		mounts     []Mount
+		wantError  string
+		wantPrefix []string
+	}{
+		{name: "no mounts"},
+		{
+			name:       "mounts under the top-level prefix",
+			pathPrefix: "/files/",
+			mounts:     []Mount{{PathPrefix: "/docs/", SiteSettings: SiteSettings{Path: root}}, {PathPrefix: "a/b", SiteSettings: SiteSettings{Path: root}}},
+			wantPrefix: []string{"/files/docs/", "/files/a/b/"},
+		},
+		{name: "missing prefix", mounts: []Mount{{SiteSettings: SiteSettings{Path: root}}}, wantError: "prefix is required"},
+		{name: "missing path", mounts: []Mount{{PathPrefix: "/docs/"}}, wantError: "path is required"},
+		{name: "root prefix", mounts: []Mount{{PathPrefix: "/", SiteSettings: SiteSettings{Path: root}}}, wantError: "can't be the root of the server"},
+		{name: "missing directory", mounts: []Mount{{PathPrefix: "/docs/", SiteSettings: SiteSettings{Path: filepath.Join(root, "missing")}}}, wantError: `invalid mount #1 ("/docs/")`},
+		{name: "invalid prefix", mounts: []Mount{{PathPrefix: "/do cs/", SiteSettings: SiteSettings{Path: root}}}, wantError: `"pathprefix" is invalid`},
+		{
+			name:      "duplicated prefix",
+			mounts:    []Mount{{PathPrefix: "/docs/", SiteSettings: SiteSettings{Path: root}}, {PathPrefix: "docs", SiteSettings: SiteSettings{Path: root}}},
+			wantError: `prefix "/docs/" is used by another mount`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Port:        5000,
+				Path:        root,
+				PathPrefix:  tt.pathPrefix,
+				LogOutput:   io.Discard,
+				ETagMaxSize: "5M",
+				Mounts:      tt.mounts,
+			}
+
+			err := s.validateMounts()
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			prefixes := make([]string, 0, len(s.mounts))
+			for _, m := range s.mounts {
+				prefixes = append(prefixes, m.PathPrefix)
+			}
+
+			if !slices.Equal(prefixes, tt.wantPrefix) {
+				t.Fatalf("expected prefixes %v, got %v", tt.wantPrefix, prefixes)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/patrickdappollonio/http-server/internal/compression"
+)
+
+// precompressedEncodings are the encodings looked up for precompressed
+// versions of a file, in order of preference when the client accepts
+// more than one with the same weight.
+var precompressedEncodings = []string{compression.Brotli, compression.Zstd, compression.Gzip}
+
+// openPrecompressed looks for precompressed versions of the file, like
+// "app.js.br" or "app.js.gz", and opens the one best matching the encodings
+// accepted by the client. Versions older than the original file are
+// ignored, since they're likely stale. It also reports whether any
+// version exists, so responses can vary on the accepted encodings.
+func openPrecompressed(location string, original os.FileInfo, r *http.Request) (*os.File, string, bool) {
+	available := make([]string, 0, len(precompressedEncodings))
+	for _, encoding := range precompressedEncodings {
+		fi, err := os.Stat(location + compression.Extension(encoding))
+		if err != nil || !fi.Mode().IsRegular() || fi.ModTime().Before(original.ModTime()) {
+			continue
+		}
+		available = append(available, encoding)
+	}
+
+	if len(available) == 0 {
+		return nil, "", false
+	}
+
+	encoding := compression.Negotiate(r.Header.Get("Accept-Encoding"), available...)
+	if encoding == "" {
+		return nil, "", true
+	}
+
+	f, err := os.Open(location + compression.Extension(encoding)) //nolint:gosec // sibling of a file already resolved within the serving root
+	if err != nil {
+		return nil, "", true
+	}
+
+	return f, encoding, true
+}
+
+// isPrecompressedSidecar checks if the file is a precompressed version
+// of another file in the same directory, given the names of all the
+// files in it.
+func isPrecompressedSidecar(name string, names map[string]bool) bool {
+	ext := filepath.Ext(name)
+	if !compression.IsPrecompressedExtension(ext) {
+		return false
+	}
+
+	return names[strings.TrimSuffix(name, ext)]
+}
+
+// sniffContentType returns the content type http.ServeContent would send
+// for the original file, from its extension or its first bytes, so its
+// precompressed versions are sent with the same content type.
+func sniffContentType(name string, content io.Reader) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		return contentType
+	}
+
+	var data [512]byte
+	n, _ := io.ReadFull(content, data[:])
+	return http.DetectContentType(data[:n])
+}
@@ -0,0 +1,296 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPrecompressedFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"app.js":            "console.log('original')",
+		"app.js.br":         "brotli content",
+		"app.js.gz":         "gzip content",
+		"style.css":         "body {}",
+		"style.css.gz":      "stale gzip content",
+		"archive.tar.gz":    "archive",
+		"data.hsunknown":    "\x00\x01\x02 binary",
+		"data.hsunknown.gz": "gzip content",
+	}
+
+	// Types only known by the mime package are sent for both versions
+	if err := mime.AddExtensionType(".hsunknown", "application/x-http-server-test"); err != nil {
+		t.Fatalf("unable to register extension: %v", err)
+	}
+
+	// Files are written in no particular order, so give them all the
+	// same modification time
+	now := time.Now()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+
+		if err := os.Chtimes(filepath.Join(dir, name), now, now); err != nil {
+			t.Fatalf("unable to change file times: %v", err)
+		}
+	}
+
+	// Precompressed versions older than the original are ignored
+	stale := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "style.css.gz"), stale, stale); err != nil {
+		t.Fatalf("unable to change file times: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		rangeHeader    string
+		expectStatus   int
+		expectBody     string
+		expectEncoding string
+		expectVary     bool
+	}{
+		{
+			name:           "preferred encoding",
+			path:           "/app.js",
+			acceptEncoding: "gzip, deflate, br, zstd",
+			expectStatus:   http.StatusOK,
+			expectBody:     "brotli content",
+			expectEncoding: "br",
+			expectVary:     true,
+		},
+		{
+			name:           "client preference",
+			path:           "/app.js",
+			acceptEncoding: "br;q=0.1, gzip",
+			expectStatus:   http.StatusOK,
+			expectBody:     "gzip content",
+			expectEncoding: "gzip",
+			expectVary:     true,
+		},
+		{
+			name:         "no accepted encoding",
+			path:         "/app.js",
+			expectStatus: http.StatusOK,
+			expectBody:   "console.log('original')",
+			expectVary:   true,
+		},
+		{
+			name:           "unavailable encoding",
+			path:           "/app.js",
+			acceptEncoding: "zstd",
+			expectStatus:   http.StatusOK,
+			expectBody:     "console.log('original')",
+			expectVary:     true,
+		},
+		{
+			name:           "range request",
+			path:           "/app.js",
+			acceptEncoding: "br",
+			rangeHeader:    "bytes=0-5",
+			expectStatus:   http.StatusPartialContent,
+			expectBody:     "brotli",
+			expectEncoding: "br",
+			expectVary:     true,
+		},
+		{
+			name:           "stale precompressed file",
+			path:           "/style.css",
+			acceptEncoding: "gzip",
+			expectStatus:   http.StatusOK,
+			expectBody:     "body {}",
+		},
+		{
+			name:           "sidecar requested directly",
+			path:           "/app.js.br",
+			acceptEncoding: "br",
+			expectStatus:   http.StatusOK,
+			expectBody:     "brotli content",
+		},
+		{
+			name:         "stale sidecar requested directly",
+			path:         "/style.css.gz",
+			expectStatus: http.StatusOK,
+			expectBody:   "stale gzip content",
+		},
+		{
+			name:           "precompressed file requested directly",
+			path:           "/archive.tar.gz",
+			acceptEncoding: "gzip",
+			expectStatus:   http.StatusOK,
+			expectBody:     "archive",
+		},
+	}
+
+	s := &Server{Path: dir, PathPrefix: "/", LogOutput: io.Discard, ETagDisabled: true, Precompressed: true}
+	handler := s.router()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectStatus {
+				t.Fatalf("expected status code %d, got %d", tt.expectStatus, rec.Code)
+			}
+
+			if rec.Body.String() != tt.expectBody {
+				t.Fatalf("expected body %q, got %q", tt.expectBody, rec.Body.String())
+			}
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.expectEncoding {
+				t.Fatalf("expected content encoding %q, got %q", tt.expectEncoding, got)
+			}
+
+			if got := rec.Header().Get("Vary") == "Accept-Encoding"; got != tt.expectVary {
+				t.Fatalf("expected vary header to be set: %v, got headers: %v", tt.expectVary, rec.Header())
+			}
+
+			if tt.path == "/app.js" {
+				if got := rec.Header().Get("Content-Type"); got != "text/javascript; charset=utf-8" {
+					t.Fatalf("expected the content type of the original file, got %q", got)
+				}
+			}
+		})
+	}
+
+	t.Run("same content type as the original file", func(t *testing.T) {
+		contentType := func(acceptEncoding string) string {
+			req := httptest.NewRequest(http.MethodGet, "/data.hsunknown", nil)
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			return rec.Header().Get("Content-Type")
+		}
+
+		original, compressed := contentType(""), contentType("gzip")
+		if original != "application/x-http-server-test" || compressed != original {
+			t.Fatalf("expected content type %q for both versions, got %q and %q", "application/x-http-server-test", original, compressed)
+		}
+	})
+
+	t.Run("hidden from listings", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?output=json", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var listing struct {
+			Files []FileInfo `json:"files"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+			t.Fatalf("unable to parse listing %q: %v", rec.Body.String(), err)
+		}
+
+		names := make([]string, 0, len(listing.Files))
+		for _, f := range listing.Files {
+			names = append(names, f.Name)
+		}
+		slices.Sort(names)
+
+		expected := []string{"app.js", "archive.tar.gz", "data.hsunknown", "style.css"}
+		if !slices.Equal(names, expected) {
+			t.Fatalf("expected files %v, got %v", expected, names)
+		}
+	})
+}
+
+func TestPrecompressedDisabled(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"foo.tar": "tarball", "foo.tar.gz": "compressed tarball"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	s := &Server{Path: dir, PathPrefix: "/", LogOutput: io.Discard, ETagDisabled: true}
+	handler := s.router()
+
+	tests := []struct {
+		name       string
+		path       string
+		expectBody string
+	}{
+		{name: "original file", path: "/foo.tar", expectBody: "tarball"},
+		{name: "compressed file", path: "/foo.tar.gz", expectBody: "compressed tarball"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d", http.StatusOK, rec.Code)
+			}
+
+			if rec.Body.String() != tt.expectBody {
+				t.Fatalf("expected body %q, got %q", tt.expectBody, rec.Body.String())
+			}
+
+			if got := rec.Header().Get("Content-Encoding"); got != "" {
+				t.Fatalf("expected no content encoding, got %q", got)
+			}
+		})
+	}
+
+	t.Run("listed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?output=json", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var listing struct {
+			Files []FileInfo `json:"files"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+			t.Fatalf("unable to parse listing %q: %v", rec.Body.String(), err)
+		}
+
+		if len(listing.Files) != 2 {
+			t.Fatalf("expected both files to be listed, got %v", listing.Files)
+		}
+	})
+}
+
+func TestPrecompressedWithDynamicCompression(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"app.js": "console.log('original')", "app.js.br": "brotli content"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	s := &Server{Path: dir, PathPrefix: "/", LogOutput: io.Discard, ETagDisabled: true, Precompressed: true, Compression: []string{"gzip"}}
+	if err := s.validateCompression(); err != nil {
+		t.Fatalf("unable to validate compression: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	rec := httptest.NewRecorder()
+	s.router().ServeHTTP(rec, req)
+
+	if got := rec.Header().Values("Vary"); !slices.Equal(got, []string{"Accept-Encoding"}) {
+		t.Fatalf("expected a single vary header on the accepted encodings, got %q", got)
+	}
+}
@@ -1,46 +1,264 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/patrickdappollonio/http-server/internal/redirects"
 )
 
 // redirectionsPath is the path to the redirections file
 const redirectionsPath = "_redirections"
 
-// getPathToRedirectionsFile returns the path to the redirections file
+// RedirectionsFilePath returns the path to the redirections file
 // with the current http-server "served" directory
-func (s *Server) getPathToRedirectionsFile() string {
+func (s *Server) RedirectionsFilePath() string {
 	return path.Join(s.Path, redirectionsPath)
 }
 
+// errNoRedirections is returned when there's no redirections file
+var errNoRedirections = errors.New("no redirections file found")
+
+// redirectionsReloadDelay is the time to wait after the last change to the
+// redirections file before reloading it, since editors often write files
+// in multiple steps
+const redirectionsReloadDelay = 100 * time.Millisecond
+
+// redirectsHolder holds the active redirections engine, which can be
+// swapped at runtime when the redirections file changes
+type redirectsHolder struct {
+	engine    atomic.Pointer[redirects.Engine]
+	lastError atomic.Pointer[string]
+}
+
+// setReloadError records the error of the last reload of the
+// redirections file, or clears it if the reload succeeded
+func (h *redirectsHolder) setReloadError(err error) {
+	if err == nil {
+		h.lastError.Store(nil)
+		return
+	}
+
+	msg := err.Error()
+	h.lastError.Store(&msg)
+}
+
+// reloadError returns the error of the last reload of the redirections
+// file, if it failed
+func (h *redirectsHolder) reloadError() string {
+	if h == nil {
+		return ""
+	}
+
+	if msg := h.lastError.Load(); msg != nil {
+		return *msg
+	}
+
+	return ""
+}
+
 // LoadRedirectionsIfEnabled loads the redirections file if redirections are enabled
 func (s *Server) LoadRedirectionsIfEnabled() error {
-	// If redirections are disabled, return immediately
-	if s.DisableRedirects {
+	// If redirections are disabled, return immediately, same as
+	// when serving mounts, since each of them has its own
+	if s.DisableRedirects || len(s.mounts) > 0 {
 		return nil
 	}
 
-	// Load the redirections file
-	b, err := os.ReadFile(s.getPathToRedirectionsFile())
+	// Load the redirections file, if there's one
+	engine, err := s.readRedirections()
+	if err != nil && !errors.Is(err, errNoRedirections) {
+		return err
+	}
+
+	// Set the redirections engine
+	s.redirects = &redirectsHolder{}
+	s.redirects.engine.Store(engine)
+	return nil
+}
+
+// ReloadRedirections reads the redirections file again and swaps the
+// active engine. If the file can't be read or parsed, the previous
+// rules are kept.
+func (s *Server) ReloadRedirections() error {
+	if s.redirects == nil {
+		return nil
+	}
+
+	engine, err := s.readRedirections()
+	if errors.Is(err, errNoRedirections) {
+		err = nil
+	}
+
+	s.redirects.setReloadError(err)
+	if err != nil {
+		return err
+	}
+
+	s.redirects.engine.Store(engine)
+
+	if engine == nil {
+		fmt.Fprintf(s.LogOutput, "Redirections file %q removed: redirections disabled\n", s.RedirectionsFilePath())
+		return nil
+	}
+
+	fmt.Fprintf(s.LogOutput, "Redirections reloaded from %q (found %d redirections)\n", s.RedirectionsFilePath(), len(engine.Rules))
+	return nil
+}
+
+// readRedirections reads and parses the redirections file. If the file
+// doesn't exist, errNoRedirections is returned.
+func (s *Server) readRedirections() (*redirects.Engine, error) {
+	b, err := os.ReadFile(s.RedirectionsFilePath())
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil
+			return nil, errNoRedirections
 		}
 
-		return fmt.Errorf("unable to read redirections file at %q: %w", s.getPathToRedirectionsFile(), err)
+		return nil, fmt.Errorf("unable to read redirections file at %q: %w", s.RedirectionsFilePath(), err)
 	}
 
 	// Parse the redirections file
 	engine, err := redirects.New(string(b))
 	if err != nil {
-		return fmt.Errorf("redirection error on file %q: %w", s.getPathToRedirectionsFile(), err)
+		return nil, fmt.Errorf("redirection error on file %q: %w", s.RedirectionsFilePath(), err)
 	}
 
-	// Set the redirections engine
-	s.redirects = engine
-	return nil
+	// Prevent rewrites from shadowing files that exist on disk
+	engine.FileExists = s.pathExists
+
+	// Rewrites and proxies require the same authentication as the files
+	basicAuth, jwtAuth := s.authMiddlewares()
+	engine.Authenticate = func(next http.Handler) http.Handler {
+		return basicAuth(jwtAuth(next))
+	}
+
+	return engine, nil
+}
+
+// currentRedirections returns the active redirections engine, if any
+func (s *Server) currentRedirections() *redirects.Engine {
+	if s.redirects == nil {
+		return nil
+	}
+
+	return s.redirects.engine.Load()
+}
+
+// redirectionsMiddleware applies the redirection rules active at the
+// time of the request
+func (s *Server) redirectionsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		engine := s.currentRedirections()
+		if engine == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		engine.Middleware(s.LogOutput)(next).ServeHTTP(w, r)
+	})
+}
+
+// watchRedirections reloads the redirections file whenever it changes on
+// disk or the process receives a SIGHUP signal, until the context is done.
+// The watchers are set up before returning, while the reloading happens
+// in the background.
+func (s *Server) watchRedirections(ctx context.Context) {
+	if s.redirects == nil {
+		return
+	}
+
+	// Reload on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	// Watch the served directory rather than the file itself, so the
+	// file can be created, removed or atomically replaced by editors
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		s.printWarningf("unable to watch redirections file for changes: %s", err)
+	} else if err := watcher.Add(s.Path); err != nil {
+		s.printWarningf("unable to watch %q for redirections file changes: %s", s.Path, err)
+		watcher.Close()
+		watcher = nil
+	}
+
+	go s.reloadRedirectionsOnChange(ctx, hup, watcher)
+}
+
+// reloadRedirectionsOnChange reloads the redirections file on SIGHUP or
+// when the watcher reports a change to it, until the context is done
+func (s *Server) reloadRedirectionsOnChange(ctx context.Context, hup chan os.Signal, watcher *fsnotify.Watcher) {
+	defer signal.Stop(hup)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+
+	if watcher != nil {
+		defer watcher.Close()
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	reload := func() {
+		if err := s.ReloadRedirections(); err != nil {
+			s.printWarningf("keeping previous redirections: %s", err)
+		}
+	}
+
+	// Debounce changes, since a single save can produce several events
+	target := filepath.Clean(s.RedirectionsFilePath())
+	timer := time.NewTimer(redirectionsReloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-hup:
+			fmt.Fprintln(s.LogOutput, "Received SIGHUP: reloading redirections")
+			reload()
+
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+
+			if filepath.Clean(ev.Name) == target {
+				timer.Reset(redirectionsReloadDelay)
+			}
+
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+
+			s.printWarningf("error watching redirections file: %s", err)
+
+		case <-timer.C:
+			reload()
+		}
+	}
+}
+
+// pathExists reports whether the given request path maps to an existing
+// file or directory within the served directory
+func (s *Server) pathExists(requestPath string) bool {
+	relpath := strings.TrimPrefix(requestPath, s.PathPrefix)
+	_, err := os.Stat(filepath.Join(s.Path, filepath.FromSlash(path.Clean("/"+relpath))))
+	return err == nil
 }
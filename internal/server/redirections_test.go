@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchRedirections(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, redirectionsPath)
+
+	if err := os.WriteFile(file, []byte("/old /new permanent\n"), 0o600); err != nil {
+		t.Fatalf("unable to write redirections file: %v", err)
+	}
+
+	s := &Server{Path: dir, LogOutput: io.Discard}
+	if err := s.LoadRedirectionsIfEnabled(); err != nil {
+		t.Fatalf("not expecting error loading redirections, got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.watchRedirections(ctx)
+
+	// waitForRules waits until the engine has the expected amount of rules
+	waitForRules := func(expected int) {
+		t.Helper()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			engine := s.currentRedirections()
+			if (engine == nil && expected == 0) || (engine != nil && len(engine.Rules) == expected) {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		t.Fatalf("timed out waiting for %d redirection rules", expected)
+	}
+
+	waitForRules(1)
+
+	// A valid change is picked up
+	if err := os.WriteFile(file, []byte("/old /new permanent\n/foo /bar temporary\n"), 0o600); err != nil {
+		t.Fatalf("unable to write redirections file: %v", err)
+	}
+	waitForRules(2)
+
+	// An invalid change keeps the previous rules
+	if err := os.WriteFile(file, []byte("/old /new permanent\n/foo\n"), 0o600); err != nil {
+		t.Fatalf("unable to write redirections file: %v", err)
+	}
+	time.Sleep(4 * redirectionsReloadDelay)
+	waitForRules(2)
+
+	// Removing the file disables redirections
+	if err := os.Remove(file); err != nil {
+		t.Fatalf("unable to remove redirections file: %v", err)
+	}
+	waitForRules(0)
+}
+
+func TestReloadRedirectionsKeepsRulesOnError(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, redirectionsPath)
+
+	if err := os.WriteFile(file, []byte("/old /new permanent\n"), 0o600); err != nil {
+		t.Fatalf("unable to write redirections file: %v", err)
+	}
+
+	s := &Server{Path: dir, LogOutput: io.Discard}
+	if err := s.LoadRedirectionsIfEnabled(); err != nil {
+		t.Fatalf("not expecting error loading redirections, got: %v", err)
+	}
+
+	if err := os.WriteFile(file, []byte("# comment\n/old /new invalid\n"), 0o600); err != nil {
+		t.Fatalf("unable to write redirections file: %v", err)
+	}
+
+	err := s.ReloadRedirections()
+	if err == nil {
+		t.Fatal("expecting error reloading invalid redirections, got nil")
+	}
+
+	if expected := "line 2"; !strings.Contains(err.Error(), expected) {
+		t.Fatalf("expecting error to mention %q, got: %v", expected, err)
+	}
+
+	if engine := s.currentRedirections(); engine == nil || len(engine.Rules) != 1 {
+		t.Fatalf("expecting previous rules to be kept")
+	}
+}
+
+func TestRedirectionsRequireAuthentication(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("expected the credentials of the client not to reach the upstream")
+		}
+		io.WriteString(w, "from upstream")
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":     "index",
+		redirectionsPath: "/api/* " + upstream.URL + " proxy\n/app/* /index.html rewrite\n",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	s := &Server{
+		Port:        5000,
+		Path:        dir,
+		PathPrefix:  "/",
+		LogOutput:   io.Discard,
+		ETagMaxSize: "5M",
+		Username:    "user",
+		Password:    "pass",
+	}
+
+	if err := s.Validate(); err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	if err := s.LoadRedirectionsIfEnabled(); err != nil {
+		t.Fatalf("not expecting error loading redirections, got: %v", err)
+	}
+
+	if err := s.prepare(); err != nil {
+		t.Fatalf("unable to prepare server: %v", err)
+	}
+
+	handler := s.router()
+
+	tests := []struct {
+		name       string
+		path       string
+		auth       bool
+		expectCode int
+		expectBody string
+	}{
+		{name: "proxy without credentials", path: "/api/users", expectCode: http.StatusUnauthorized},
+		{name: "proxy with credentials", path: "/api/users", auth: true, expectCode: http.StatusOK, expectBody: "from upstream"},
+		{name: "rewrite without credentials", path: "/app/users", expectCode: http.StatusUnauthorized},
+		{name: "rewrite with credentials", path: "/app/users", auth: true, expectCode: http.StatusOK, expectBody: "index"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth {
+				req.SetBasicAuth("user", "pass")
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectCode {
+				t.Fatalf("expected status code %d, got %d - response: %s", tt.expectCode, rec.Code, rec.Body.String())
+			}
+
+			if tt.expectBody != "" && !strings.Contains(rec.Body.String(), tt.expectBody) {
+				t.Fatalf("expected body to contain %q, got %q", tt.expectBody, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configReloadDelay is the time to wait after the last change to the
+// configuration file before reloading it, since editors often write
+// files in multiple steps
+const configReloadDelay = 100 * time.Millisecond
+
+// ConfigLoader reads the settings of the server again, from the same
+// flags, environment variables and configuration file it started with.
+type ConfigLoader func() (*Server, error)
+
+// SetConfigLoader sets the function used to read the settings again when
+// the configuration file changes. Without it, the configuration file is
+// only read when the server starts.
+func (s *Server) SetConfigLoader(loader ConfigLoader) {
+	s.configLoader = loader
+}
+
+// reloadableHandler is a handler that can be swapped atomically when the
+// configuration changes, without dropping connections.
+type reloadableHandler struct {
+	current atomic.Pointer[http.Handler]
+}
+
+// newReloadableHandler creates a reloadable handler serving the handler
+// until it's swapped.
+func newReloadableHandler(handler http.Handler) *reloadableHandler {
+	h := &reloadableHandler{}
+	h.store(handler)
+	return h
+}
+
+// store swaps the handler serving new requests.
+func (h *reloadableHandler) store(handler http.Handler) {
+	h.current.Store(&handler)
+}
+
+// ServeHTTP serves the request with the current handler.
+func (h *reloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*h.current.Load()).ServeHTTP(w, r)
+}
+
+// watchSites watches the redirections files of the server, its mounts and
+// its virtual hosts, until the context is done.
+func (s *Server) watchSites(ctx context.Context) {
+	s.watchRedirections(ctx)
+	for _, m := range s.mounts {
+		m.watchRedirections(ctx)
+	}
+	for _, vh := range s.vhosts {
+		vh.server.watchRedirections(ctx)
+	}
+}
+
+// watchConfig reloads the configuration file whenever it changes on disk,
+// until the context is done, swapping the handler if the new settings are
+// valid. The redirections files are watched too, for the settings
+// currently in use.
+func (s *Server) watchConfig(ctx context.Context, handler *reloadableHandler) {
+	if s.ConfigFile == "" || s.configLoader == nil {
+		s.watchSites(ctx)
+		return
+	}
+
+	// Watch the directory rather than the file itself, so the file
+	// can be atomically replaced by editors or configuration tools
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		s.printWarningf("unable to watch configuration file for changes: %s", err)
+		s.watchSites(ctx)
+		return
+	}
+
+	if err := watcher.Add(filepath.Dir(s.ConfigFile)); err != nil {
+		s.printWarningf("unable to watch %q for configuration file changes: %s", filepath.Dir(s.ConfigFile), err)
+		watcher.Close()
+		s.watchSites(ctx)
+		return
+	}
+
+	sitesCtx, stopSites := context.WithCancel(ctx)
+	s.watchSites(sitesCtx)
+
+	go s.reloadConfigOnChange(ctx, handler, watcher, resolveConfigFile(s.ConfigFile), stopSites)
+}
+
+// reloadConfigOnChange reloads the configuration file when the watcher
+// reports a change to it, or to the file it resolved to when the watch
+// started, until the context is done.
+func (s *Server) reloadConfigOnChange(ctx context.Context, handler *reloadableHandler, watcher *fsnotify.Watcher, resolved string, stopSites context.CancelFunc) {
+	defer watcher.Close()
+	defer func() { stopSites() }()
+
+	current := s
+	reload := func() {
+		next, err := current.reloadConfig()
+		if err != nil {
+			s.printWarningf("keeping previous configuration, unable to reload %q:\n%s", s.ConfigFile, err)
+			return
+		}
+
+		handler.store(next.handler())
+
+		// Watch the redirections files of the new settings instead
+		stopSites()
+		var sitesCtx context.Context
+		sitesCtx, stopSites = context.WithCancel(ctx)
+		next.watchSites(sitesCtx)
+
+		current = next
+		fmt.Fprintf(s.LogOutput, "Configuration reloaded from %q\n", s.ConfigFile)
+	}
+
+	// Debounce changes, since a single save can produce several events
+	target := filepath.Clean(s.ConfigFile)
+	timer := time.NewTimer(configReloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			// Files mounted from Kubernetes ConfigMaps are symbolic links
+			// swapped through a "..data" link in the same directory, so
+			// any change to the file they point to counts too
+			changed := filepath.Clean(ev.Name) == target
+			if current := resolveConfigFile(target); current != resolved {
+				resolved, changed = current, true
+			}
+
+			if changed {
+				timer.Reset(configReloadDelay)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			s.printWarningf("error watching configuration file: %s", err)
+
+		case <-timer.C:
+			reload()
+		}
+	}
+}
+
+// resolveConfigFile returns the file the configuration file points to,
+// with all symbolic links resolved, or an empty string if it can't be
+// resolved, like while it's being replaced.
+func resolveConfigFile(location string) string {
+	resolved, err := filepath.EvalSymlinks(location)
+	if err != nil {
+		return ""
+	}
+
+	return resolved
+}
+
+// reloadConfig reads and validates the settings again, returning a server
+// ready to handle requests with them. The state kept for the whole
+// process, like the in-memory cache or the request counters, is carried
+// over to the new server.
+func (s *Server) reloadConfig() (*Server, error) {
+	next, err := s.configLoader()
+	if err != nil {
+		return nil, err
+	}
+
+	next.LogOutput, next.version = s.LogOutput, s.version
+	next.ConfigFile, next.configLoader = s.ConfigFile, s.configLoader
+	s.keepStartupSettings(next)
+
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := next.LoadRedirectionsIfEnabled(); err != nil {
+		return nil, err
+	}
+
+	next.inheritState(s)
+
+	if err := next.prepare(); err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// keepStartupSettings keeps the settings that only apply when the server
+// starts, like the addresses it listens on, warning about those changed.
+func (s *Server) keepStartupSettings(next *Server) {
+	var changed []string
+	for _, setting := range []struct {
+		flag  string
+		equal bool
+	}{
+		{"port", next.Port == s.Port},
+		{"listen", slices.Equal(next.Listen, s.Listen)},
+		{"socket-mode", next.SocketMode == s.SocketMode},
+		{"read-timeout", next.ReadTimeout == s.ReadTimeout},
+		{"read-header-timeout", next.ReadHeaderTimeout == s.ReadHeaderTimeout},
+		{"write-timeout", next.WriteTimeout == s.WriteTimeout},
+		{"idle-timeout", next.IdleTimeout == s.IdleTimeout},
+		{"max-header-bytes", next.MaxHeaderBytes == s.MaxHeaderBytes},
+		{"shutdown-timeout", next.ShutdownTimeout == s.ShutdownTimeout},
+		{"drain-period", next.DrainPeriod == s.DrainPeriod},
+		{"cache-size", next.CacheSize == s.CacheSize},
+		{"etag-hash-cache", next.ETagHashCache == s.ETagHashCache},
+	} {
+		if !setting.equal {
+			changed = append(changed, setting.flag)
+		}
+	}
+
+	next.Port, next.Listen, next.SocketMode = s.Port, s.Listen, s.SocketMode
+	next.ReadTimeout, next.ReadHeaderTimeout, next.WriteTimeout, next.IdleTimeout = s.ReadTimeout, s.ReadHeaderTimeout, s.WriteTimeout, s.IdleTimeout
+	next.MaxHeaderBytes, next.ShutdownTimeout, next.DrainPeriod = s.MaxHeaderBytes, s.ShutdownTimeout, s.DrainPeriod
+	next.CacheSize, next.ETagHashCache = s.CacheSize, s.ETagHashCache
+
+	if len(changed) > 0 {
+		s.printWarningf("changes to %s only apply when the server restarts: keeping previous values", strings.Join(changed, ", "))
+	}
+}
+
+// inheritState makes the server, its mounts and its virtual hosts use the
+// state kept for the whole process from the previous server.
+func (s *Server) inheritState(prev *Server) {
+	s.cache, s.etagHashes, s.draining, s.stats = prev.cache, prev.etagHashes, prev.draining, prev.stats
+
+	for _, m := range s.mounts {
+		m.inheritState(prev)
+	}
+
+	for _, vh := range s.vhosts {
+		vh.server.inheritState(prev)
+	}
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newReloadTestServer creates a server whose configuration file holds
+// its title, or "invalid" for an invalid configuration.
+func newReloadTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.txt")
+	if err := os.WriteFile(config, []byte("Before"), 0o600); err != nil {
+		t.Fatalf("unable to write configuration file: %v", err)
+	}
+
+	return newReloadTestServerFor(t, dir, config), config
+}
+
+// newReloadTestServerFor creates a server serving the directory, whose
+// existing configuration file holds its title.
+func newReloadTestServerFor(t *testing.T, dir, config string) *Server {
+	t.Helper()
+
+	loader := func() (*Server, error) {
+		b, err := os.ReadFile(config)
+		if err != nil {
+			return nil, err
+		}
+
+		next := &Server{Port: 5000, Path: dir, PathPrefix: "/", ETagMaxSize: "5M", PageTitle: string(b)}
+		if string(b) == "invalid" {
+			next.PathPrefix = "not a prefix"
+		}
+
+		return next, nil
+	}
+
+	s, err := loader()
+	if err != nil {
+		t.Fatalf("unable to load configuration: %v", err)
+	}
+
+	s.LogOutput = io.Discard
+	s.ConfigFile = config
+	s.SetConfigLoader(loader)
+
+	if err := s.Validate(); err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	if err := s.prepare(); err != nil {
+		t.Fatalf("unable to prepare server: %v", err)
+	}
+
+	return s
+}
+
+// watchTestConfig watches the configuration file of the server, returning
+// a function waiting until the handler serves the expected title.
+func watchTestConfig(t *testing.T, s *Server) func(expected string) {
+	t.Helper()
+
+	handler := newReloadableHandler(s.handler())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s.watchConfig(ctx, handler)
+
+	title := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		body := rec.Body.String()
+		start, end := strings.Index(body, "<title>"), strings.Index(body, "</title>")
+		if start < 0 || end < start {
+			t.Fatalf("unable to find the title in %q", body)
+		}
+
+		return body[start+len("<title>") : end]
+	}
+
+	return func(expected string) {
+		t.Helper()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if title() == expected {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		t.Fatalf("expected title %q, got %q", expected, title())
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	s, config := newReloadTestServer(t)
+	waitForTitle := watchTestConfig(t, s)
+
+	waitForTitle("Before")
+
+	if err := os.WriteFile(config, []byte("After"), 0o600); err != nil {
+		t.Fatalf("unable to write configuration file: %v", err)
+	}
+	waitForTitle("After")
+
+	// Invalid settings keep the previous handler
+	if err := os.WriteFile(config, []byte("invalid"), 0o600); err != nil {
+		t.Fatalf("unable to write configuration file: %v", err)
+	}
+	time.Sleep(10 * configReloadDelay)
+	waitForTitle("After")
+
+	// Valid settings are applied again after an invalid change
+	if err := os.WriteFile(config, []byte("Fixed"), 0o600); err != nil {
+		t.Fatalf("unable to write configuration file: %v", err)
+	}
+	waitForTitle("Fixed")
+}
+
+func TestWatchConfigSymlinkSwap(t *testing.T) {
+	// Lay out the files like Kubernetes mounts a ConfigMap: the file is a
+	// link through "..data", which points to a directory with the content
+	dir := t.TempDir()
+	writeVersion := func(version, title string) {
+		t.Helper()
+
+		if err := os.Mkdir(filepath.Join(dir, version), 0o700); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, version, "config.txt"), []byte(title), 0o600); err != nil {
+			t.Fatalf("unable to write configuration file: %v", err)
+		}
+	}
+
+	writeVersion("..v1", "Before")
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("unable to create link: %v", err)
+	}
+
+	config := filepath.Join(dir, "config.txt")
+	if err := os.Symlink(filepath.Join("..data", "config.txt"), config); err != nil {
+		t.Fatalf("unable to create link: %v", err)
+	}
+
+	s := newReloadTestServerFor(t, dir, config)
+	waitForTitle := watchTestConfig(t, s)
+	waitForTitle("Before")
+
+	// Swap the "..data" link atomically, then remove the old content,
+	// never touching the configuration file link itself
+	writeVersion("..v2", "After")
+	if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatalf("unable to create link: %v", err)
+	}
+
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("unable to swap link: %v", err)
+	}
+
+	if err := os.RemoveAll(filepath.Join(dir, "..v1")); err != nil {
+		t.Fatalf("unable to remove previous content: %v", err)
+	}
+
+	waitForTitle("After")
+}
+
+func TestReloadConfig(t *testing.T) {
+	t.Run("keeps startup settings and process state", func(t *testing.T) {
+		s, _ := newReloadTestServer(t)
+
+		loader := s.configLoader
+		s.SetConfigLoader(func() (*Server, error) {
+			next, err := loader()
+			if err != nil {
+				return nil, err
+			}
+
+			next.Port, next.DrainPeriod = 8080, time.Minute
+			return next, nil
+		})
+
+		next, err := s.reloadConfig()
+		if err != nil {
+			t.Fatalf("not expecting error, got: %v", err)
+		}
+
+		if next.Port != s.Port || next.DrainPeriod != s.DrainPeriod {
+			t.Fatalf("expected startup settings to be kept, got port %d and drain period %s", next.Port, next.DrainPeriod)
+		}
+
+		if next.stats != s.stats || next.draining != s.draining {
+			t.Fatalf("expected process state to be shared with the previous server")
+		}
+
+		if next.templates == nil {
+			t.Fatalf("expected new server to be ready to handle requests")
+		}
+	})
+
+	t.Run("reads secret files again", func(t *testing.T) {
+		s, _ := newReloadTestServer(t)
+
+		secret := filepath.Join(t.TempDir(), "password")
+		if err := os.WriteFile(secret, []byte("rotated\n"), 0o600); err != nil {
+			t.Fatalf("unable to write secret file: %v", err)
+		}
+
+		loader := s.configLoader
+		s.SetConfigLoader(func() (*Server, error) {
+			next, err := loader()
+			if err != nil {
+				return nil, err
+			}
+
+			next.Username, next.PasswordFile = "user", secret
+			return next, nil
+		})
+
+		next, err := s.reloadConfig()
+		if err != nil {
+			t.Fatalf("not expecting error, got: %v", err)
+		}
+
+		if next.Password != "rotated" {
+			t.Fatalf("expected password to be read from the secret file, got %q", next.Password)
+		}
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		s, config := newReloadTestServer(t)
+		if err := os.WriteFile(config, []byte("invalid"), 0o600); err != nil {
+			t.Fatalf("unable to write configuration file: %v", err)
+		}
+
+		if _, err := s.reloadConfig(); err == nil || !strings.Contains(err.Error(), "pathprefix") {
+			t.Fatalf("expected validation error, got: %v", err)
+		}
+	})
+
+	t.Run("unreadable settings", func(t *testing.T) {
+		s, _ := newReloadTestServer(t)
+		s.SetConfigLoader(func() (*Server, error) {
+			return nil, errors.New("unable to read configuration file")
+		})
+
+		if _, err := s.reloadConfig(); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+	})
+}
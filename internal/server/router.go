@@ -2,15 +2,15 @@ package server
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"path"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/klauspost/compress/gzhttp"
+	"github.com/patrickdappollonio/http-server/internal/compression"
 	"github.com/patrickdappollonio/http-server/internal/middlewares"
+	"github.com/patrickdappollonio/http-server/internal/redirects"
 )
 
 func (s *Server) router() http.Handler {
@@ -19,15 +19,57 @@ func (s *Server) router() http.Handler {
 	// Allow logging all request to our custom logger
 	r.Use(middlewares.LogRequest(s.LogOutput, logFormat, "token"))
 
+	// Count the requests served, including the ones that panic
+	r.Use(s.countRequests)
+
 	// Recover the request in case of a panic
 	r.Use(middleware.Recoverer)
 
 	// Only allow specific methods in all our requests
 	r.Use(middlewares.VerbsAllowed("GET", "HEAD"))
 
-	// Disable access to specific files
-	r.Use(middlewares.DisableAccessToFile(s.isFiltered, http.StatusNotFound))
+	// Enable etag support for files smaller than
+	// 10 MB, and only if the feature is enabled
+	maxBodySize := s.etagMaxSizeBytes
+	r.Use(middlewares.Etag(!s.ETagDisabled, maxBodySize))
+
+	// Compress responses with the encodings enabled, if any
+	if encodings := s.compressionEncodings(); len(encodings) > 0 {
+		r.Use(compression.Handler(encodings, s.compressionMinSizeBytes))
+	}
+
+	// Enable CORS if needed
+	if s.CorsEnabled {
+		r.Use(middlewares.EnableCORS)
+	}
+
+	// Handle emptiness of path prefix
+	if s.PathPrefix == "" {
+		s.PathPrefix = "/"
+	}
+
+	// Serve each mount under its own prefix, or the files of the server
+	if len(s.mounts) > 0 {
+		s.mountRoutes(r)
+	} else {
+		r.Group(s.routes)
+	}
+
+	// If the path prefix is not the root of the server, then we
+	// can preemptively redirect users to the appropriate destination
+	// so they don't see a not found error
+	if s.PathPrefix != "/" {
+		r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, s.PathPrefix, http.StatusFound)
+		})
+	}
+
+	return r
+}
 
+// authMiddlewares returns the middlewares for basic and JWT
+// authentication, which do nothing if the method isn't enabled.
+func (s *Server) authMiddlewares() (func(http.Handler) http.Handler, func(http.Handler) http.Handler) {
 	// Enable basic authentication if needed
 	basicAuth := func(next http.Handler) http.Handler { return next }
 	if s.IsBasicAuthEnabled() {
@@ -47,38 +89,30 @@ func (s *Server) router() http.Handler {
 		)
 	}
 
-	// Enable etag support for files smaller than
-	// 10 MB, and only if the feature is enabled
-	maxBodySize := s.etagMaxSizeBytes
-	r.Use(middlewares.Etag(!s.ETagDisabled, maxBodySize))
-
-	// Check if gzip is enabled
-	if s.GzipEnabled {
-		r.Use(func(h http.Handler) http.Handler {
-			log.Printf("Gzip enabled for all requests")
-			return gzhttp.GzipHandler(h)
-		})
-	}
-
-	// Enable CORS if needed
-	if s.CorsEnabled {
-		r.Use(middlewares.EnableCORS)
-	}
+	return basicAuth, jwtAuth
+}
 
+// routes registers the middlewares and routes serving the files of the
+// server under its path prefix.
+func (s *Server) routes(r chi.Router) {
 	// Check if the redirect engine is enabled, and if so, load
-	// the middleware for it
+	// the middleware for it, which picks up the rules active at
+	// the time of each request
 	if s.redirects != nil {
-		r.Use(s.redirects.Middleware(s.LogOutput))
+		r.Use(s.redirectionsMiddleware)
 	}
 
+	// Disable access to specific files, checked after redirections
+	// so rewritten requests can't reach them either
+	r.Use(middlewares.DisableAccessToFile(s.isForbiddenPath, http.StatusNotFound))
+
 	// Check if the request is against a URL ending on a known
-	// index file, and if so, redirect to the directory
-	r.Use(middlewares.RedirectIndexes(http.StatusMovedPermanently))
+	// index file, and if so, redirect to the directory, unless the
+	// request was internally rewritten to that file
+	r.Use(middlewares.RedirectIndexes(http.StatusMovedPermanently, redirects.IsRewritten))
 
-	// Handle emptiness of path prefix
-	if s.PathPrefix == "" {
-		s.PathPrefix = "/"
-	}
+	// Configure the authentication methods, if any
+	basicAuth, jwtAuth := s.authMiddlewares()
 
 	// Create a route based on a path prefix, prevalidated that
 	// the prefix is a valid prefix, and including any potential
@@ -92,23 +126,13 @@ func (s *Server) router() http.Handler {
 	assetsPrefix := path.Join(s.PathPrefix, specialPath, s.cacheBuster)
 	r.HandleFunc(path.Join(assetsPrefix, "assets", "*"), s.serveAssets(assetsPrefix))
 
-	// Create a health check endpoint
-	r.HandleFunc(path.Join(s.PathPrefix, specialPath, "health"), s.healthCheck)
+	// Create the health check and status endpoints
+	s.statusRoutes(r)
 
-	// Handle special path prefix cases
+	// Redirect path prefix without trailing slash to a canonical location
 	if s.PathPrefix != "/" {
-		// If the path prefix is not the root of the server, then we
-		// can preemptively redirect users to the appropriate destination
-		// so they don't see a not found error
-		r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			http.Redirect(w, r, s.PathPrefix, http.StatusFound)
-		})
-
-		// Redirect path prefix without trailing slash to a canonical location
 		r.HandleFunc(strings.TrimSuffix(s.PathPrefix, "/"), func(w http.ResponseWriter, r *http.Request) {
 			http.Redirect(w, r, s.PathPrefix, http.StatusMovedPermanently)
 		})
 	}
-
-	return r
 }
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// validateSecretFiles reads the password and the JWT signing key from the
+// files set with --password-file and --jwt-key-file, so they don't show
+// up in the process list or the environment of the container. The files
+// are read every time the settings are validated, including on reloads,
+// and hidden from every site serving a path they live in.
+func (s *Server) validateSecretFiles() error {
+	for _, secret := range []struct {
+		flag     string
+		fileFlag string
+		file     string
+		value    *string
+	}{
+		{"password", "password-file", s.PasswordFile, &s.Password},
+		{"jwt-key", "jwt-key-file", s.JWTSigningKeyFile, &s.JWTSigningKey},
+	} {
+		if secret.file == "" {
+			continue
+		}
+
+		if *secret.value != "" {
+			return fmt.Errorf("--%s and --%s are mutually exclusive: set only one of them", secret.flag, secret.fileFlag)
+		}
+
+		value, err := ReadSecretFile(secret.file)
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %w", secret.fileFlag, err)
+		}
+
+		*secret.value = value
+		s.secretFiles = append(s.secretFiles, secret.file)
+	}
+
+	// Never serve the secrets if they live in the served path, including
+	// those read by the top-level server for its mounts and virtual hosts
+	for _, file := range s.secretFiles {
+		if validateIsFileInPath(s.Path, file) {
+			s.forbiddenMatches = append(s.forbiddenMatches, filepath.Base(file))
+		}
+	}
+
+	return nil
+}
+
+// ReadSecretFile reads a secret from a file, like those mounted by Docker
+// or Kubernetes secrets, without its trailing newline. Empty secrets are
+// rejected, since they would disable the authentication.
+func ReadSecretFile(file string) (string, error) {
+	b, err := os.ReadFile(file) //nolint:gosec // file is provided by the user running the server
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret file: %w", err)
+	}
+
+	value := strings.TrimRight(string(b), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("secret file %q is empty", file)
+	}
+
+	return value, nil
+}
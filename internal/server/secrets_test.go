@@ -0,0 +1,197 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateSecretFiles(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		setup         func(s *Server, file string)
+		wantPassword  string
+		wantJWTKey    string
+		wantError     string
+		wantFiltered  bool
+		fileInDirPath bool
+	}{
+		{
+			name:         "password from file without trailing newline",
+			content:      "s3cr3t\n",
+			setup:        func(s *Server, file string) { s.Username, s.PasswordFile = "user", file },
+			wantPassword: "s3cr3t",
+		},
+		{
+			name:       "jwt key from file",
+			content:    "signing-key\r\n",
+			setup:      func(s *Server, file string) { s.JWTSigningKeyFile = file },
+			wantJWTKey: "signing-key",
+		},
+		{
+			name:          "secret file in the served path is hidden",
+			content:       "s3cr3t",
+			setup:         func(s *Server, file string) { s.Username, s.PasswordFile = "user", file },
+			wantPassword:  "s3cr3t",
+			wantFiltered:  true,
+			fileInDirPath: true,
+		},
+		{
+			name:      "password and password file",
+			content:   "s3cr3t",
+			setup:     func(s *Server, file string) { s.Username, s.Password, s.PasswordFile = "user", "other", file },
+			wantError: "--password and --password-file are mutually exclusive",
+		},
+		{
+			name:      "empty secret file",
+			content:   "\n",
+			setup:     func(s *Server, file string) { s.JWTSigningKeyFile = file },
+			wantError: "is empty",
+		},
+		{
+			name:      "missing secret file",
+			setup:     func(s *Server, file string) { s.PasswordFile = file + ".missing" },
+			wantError: "invalid --password-file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			secretsDir := t.TempDir()
+			if tt.fileInDirPath {
+				secretsDir = dir
+			}
+
+			file := filepath.Join(secretsDir, "secret.txt")
+			if err := os.WriteFile(file, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("unable to write secret file: %v", err)
+			}
+
+			s := &Server{Port: 5000, Path: dir, PathPrefix: "/", LogOutput: io.Discard, ETagMaxSize: "5M"}
+			tt.setup(s, file)
+
+			err := s.Validate()
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			if s.Password != tt.wantPassword || s.JWTSigningKey != tt.wantJWTKey {
+				t.Fatalf("expected password %q and JWT key %q, got %q and %q", tt.wantPassword, tt.wantJWTKey, s.Password, s.JWTSigningKey)
+			}
+
+			if got := s.isFiltered("secret.txt"); got != tt.wantFiltered {
+				t.Fatalf("expected secret file to be filtered: %v, got %v", tt.wantFiltered, got)
+			}
+		})
+	}
+}
+
+func TestSecretFilesNotServedBySites(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(s *Server, root string)
+		host    string
+		path    string
+		visible string
+	}{
+		{
+			name: "virtual host serving the same path",
+			setup: func(s *Server, root string) {
+				s.VirtualHosts = []VirtualHost{{Host: "other.local", SiteSettings: SiteSettings{Path: root}}}
+			},
+			host:    "other.local",
+			path:    "/password.txt",
+			visible: "/index.txt",
+		},
+		{
+			name: "virtual host serving a path with the secret",
+			setup: func(s *Server, root string) {
+				s.Path = filepath.Join(root, "public")
+				s.VirtualHosts = []VirtualHost{{Host: "other.local", SiteSettings: SiteSettings{Path: root}}}
+			},
+			host:    "other.local",
+			path:    "/password.txt",
+			visible: "/index.txt",
+		},
+		{
+			name: "mount serving the path with the secret",
+			setup: func(s *Server, root string) {
+				s.Mounts = []Mount{{PathPrefix: "/files/", SiteSettings: SiteSettings{Path: root}}}
+			},
+			path:    "/files/password.txt",
+			visible: "/files/index.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range map[string]string{
+				"password.txt":     "hunter2\n",
+				"index.txt":        "index",
+				"public/index.txt": "public",
+			} {
+				location := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+					t.Fatalf("unable to create directory: %v", err)
+				}
+				if err := os.WriteFile(location, []byte(content), 0o600); err != nil {
+					t.Fatalf("unable to write file: %v", err)
+				}
+			}
+
+			s := &Server{
+				Port:         5000,
+				Path:         root,
+				PathPrefix:   "/",
+				LogOutput:    io.Discard,
+				ETagMaxSize:  "5M",
+				Username:     "user",
+				PasswordFile: filepath.Join(root, "password.txt"),
+			}
+			tt.setup(s, root)
+
+			if err := s.Validate(); err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			if err := s.prepare(); err != nil {
+				t.Fatalf("unable to prepare server: %v", err)
+			}
+
+			handler := s.handler()
+
+			request := func(path string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.Host = tt.host
+				req.SetBasicAuth("user", "hunter2")
+
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec
+			}
+
+			if rec := request(tt.visible); rec.Code != http.StatusOK {
+				t.Fatalf("expected other files to be served, got %d - response: %s", rec.Code, rec.Body.String())
+			}
+
+			if rec := request(tt.path); rec.Code != http.StatusNotFound {
+				t.Fatalf("expected secret file to be hidden, got %d - response: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
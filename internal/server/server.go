@@ -3,10 +3,17 @@ package server
 import (
 	"html/template"
 	"io"
+	"io/fs"
 	"path"
 	"strings"
-
-	"github.com/patrickdappollonio/http-server/internal/redirects"
+	"sync/atomic"
+	"time"
+
+	"github.com/patrickdappollonio/http-server/internal/cache"
+	"github.com/patrickdappollonio/http-server/internal/cachecontrol"
+	"github.com/patrickdappollonio/http-server/internal/etag"
+	"github.com/patrickdappollonio/http-server/internal/ignore"
+	"github.com/yuin/goldmark"
 )
 
 const repositoryURL = "https://github.com/patrickdappollonio/http-server/"
@@ -27,16 +34,24 @@ type Server struct {
 	CustomNotFoundPage       string
 	CustomNotFoundStatusCode int
 
+	// Single-page application settings
+	SPAFallback     string `flagName:"spa-fallback"`
+	spaFallbackPath string
+
 	// Basic auth settings
-	Username string `flagName:"username" validate:"omitempty,excluded_with=JWTSigningKey"`
-	Password string `flagName:"password" validate:"omitempty,excluded_with=JWTSigningKey"`
+	Username     string `flagName:"username" validate:"omitempty,excluded_with=JWTSigningKey"`
+	Password     string `flagName:"password" validate:"omitempty,excluded_with=JWTSigningKey"`
+	PasswordFile string `flagName:"password-file"`
 
 	// Boolean specific settings
 	CorsEnabled         bool
+	StatusEnabled       bool
 	HideLinks           bool
 	ETagDisabled        bool
 	ETagMaxSize         string
 	etagMaxSizeBytes    int64
+	ETagHashCache       string
+	etagHashes          *etag.HashCache
 	GzipEnabled         bool
 	DisableCacheBuster  bool
 	DisableMarkdown     bool
@@ -44,27 +59,83 @@ type Server struct {
 	HideFilesInMarkdown bool
 	FullMarkdownRender  bool
 
+	// Compression settings
+	Precompressed           bool
+	Compression             []string
+	CompressionMinSize      string
+	compressionMinSizeBytes int64
+
+	// In-memory cache settings
+	CacheSize string `flagName:"cache-size"`
+	cache     *cache.LRU[cachedFile]
+
+	// Cache-Control settings
+	CacheControl []string `flagName:"cache-control"`
+	cacheControl *cachecontrol.Policy
+
+	// File visibility settings
+	HideDotfiles bool
+	Exclude      []string `flagName:"exclude"`
+	excludes     *ignore.Matcher
+
+	// Symbolic link settings
+	Symlinks string `flagName:"symlinks" validate:"omitempty,oneof=follow within-root deny"`
+	realPath string
+
+	// Addresses to listen on, instead of all interfaces on the port
+	Listen     []string `flagName:"listen"`
+	SocketMode string   `flagName:"socket-mode"`
+	listeners  []listenAddress
+	socketMode fs.FileMode
+
+	// Timeouts and limits of the HTTP server, and of its shutdown
+	ReadTimeout       time.Duration `flagName:"read-timeout"`
+	ReadHeaderTimeout time.Duration `flagName:"read-header-timeout"`
+	WriteTimeout      time.Duration `flagName:"write-timeout"`
+	IdleTimeout       time.Duration `flagName:"idle-timeout"`
+	MaxHeaderBytes    string        `flagName:"max-header-bytes"`
+	ShutdownTimeout   time.Duration `flagName:"shutdown-timeout"`
+	DrainPeriod       time.Duration `flagName:"drain-period"`
+	maxHeaderBytes    int
+	draining          *atomic.Bool
+
+	// Request counters for the status endpoint
+	stats *requestStats
+
+	// Mounts served under their own path prefixes
+	Mounts []Mount
+	mounts []*Server
+
+	// Virtual hosts selected by the "Host" header of each request
+	VirtualHosts []VirtualHost
+	vhosts       []virtualHost
+
 	// Redirection handling
 	DisableRedirects bool
-	redirects        *redirects.Engine
+	redirects        *redirectsHolder
 
 	// JWT Specific settings
-	JWTSigningKey    string `flagName:"jwt-key" validate:"omitempty,excluded_with=Username,excluded_with=Password"`
-	ValidateTimedJWT bool
+	JWTSigningKey     string `flagName:"jwt-key" validate:"omitempty,excluded_with=Username,excluded_with=Password"`
+	JWTSigningKeyFile string `flagName:"jwt-key-file"`
+	ValidateTimedJWT  bool
 
 	// Custom CSS settings
 	CustomCSS string `flagName:"custom-css-file" validate:"omitempty,file"`
 
 	// Viper config settings
 	ConfigFilePrefix string
+	ConfigFile       string
+	configLoader     ConfigLoader
 
 	// Internal fields
 	cacheBuster       string
 	templates         *template.Template
+	markdown          goldmark.Markdown
 	version           string
 	forbiddenPrefixes []string
 	forbiddenSuffixes []string
 	forbiddenMatches  []string
+	secretFiles       []string
 
 	// Force download settings
 	ForceDownloadExtensions []string
@@ -0,0 +1,98 @@
+package server
+
+import "slices"
+
+// SiteSettings are the settings of a directory served by a mount or a
+// virtual host. Settings left empty, including the path for virtual
+// hosts, are inherited from the top-level settings.
+type SiteSettings struct {
+	Path      string `mapstructure:"path"`
+	PageTitle string `mapstructure:"title"`
+
+	// Authentication settings, replacing the inherited ones when set
+	Username      string `mapstructure:"username"`
+	Password      string `mapstructure:"password"`
+	JWTSigningKey string `mapstructure:"jwt-key"`
+
+	// Directory listing and markdown toggles
+	DisableDirectoryList *bool `mapstructure:"disable-directory-listing"`
+	DisableMarkdown      *bool `mapstructure:"disable-markdown"`
+	MarkdownBeforeDir    *bool `mapstructure:"markdown-before-dir"`
+	HideFilesInMarkdown  *bool `mapstructure:"hide-files-in-markdown"`
+	FullMarkdownRender   *bool `mapstructure:"render-all-markdown"`
+	DisableRedirects     *bool `mapstructure:"disable-redirects"`
+}
+
+// newSite creates a server for a mount or a virtual host, based on the
+// top-level settings with the site settings applied. The configure
+// function can change other settings before the server is validated.
+// The in-memory cache and the content hashes for ETags are shared with
+// the top-level server.
+func (s *Server) newSite(settings SiteSettings, configure func(*Server)) (*Server, error) {
+	child := *s
+	child.Mounts, child.mounts = nil, nil
+	child.VirtualHosts, child.vhosts = nil, nil
+
+	child.forbiddenMatches = nil
+
+	// Secrets were already read from their files by the top-level server,
+	// but their files must still be hidden if the site serves them
+	child.PasswordFile, child.JWTSigningKeyFile = "", ""
+	child.secretFiles = slices.Clip(s.secretFiles)
+
+	// Settings pointing to files within the top-level path don't apply
+	// when serving a different path
+	if settings.Path != "" {
+		child.Path = settings.Path
+		child.CustomCSS, child.SPAFallback = "", ""
+	}
+
+	if settings.PageTitle != "" {
+		child.PageTitle = settings.PageTitle
+	}
+
+	// Authentication methods replace each other, so a site can use
+	// a different method than the top-level settings
+	if settings.Username != "" || settings.Password != "" {
+		child.Username, child.Password, child.JWTSigningKey = settings.Username, settings.Password, ""
+	}
+
+	if settings.JWTSigningKey != "" {
+		child.Username, child.Password, child.JWTSigningKey = "", "", settings.JWTSigningKey
+	}
+
+	for _, toggle := range []struct {
+		value *bool
+		field *bool
+	}{
+		{settings.DisableDirectoryList, &child.DisableDirectoryList},
+		{settings.DisableMarkdown, &child.DisableMarkdown},
+		{settings.MarkdownBeforeDir, &child.MarkdownBeforeDir},
+		{settings.HideFilesInMarkdown, &child.HideFilesInMarkdown},
+		{settings.FullMarkdownRender, &child.FullMarkdownRender},
+		{settings.DisableRedirects, &child.DisableRedirects},
+	} {
+		if toggle.value != nil {
+			*toggle.field = *toggle.value
+		}
+	}
+
+	if configure != nil {
+		configure(&child)
+	}
+
+	if err := child.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Share the state kept for the whole process, but not the
+	// redirections, which are read from the path of the site
+	child.cache, child.etagHashes, child.draining, child.stats = s.cache, s.etagHashes, s.draining, s.stats
+	child.redirects = nil
+
+	if err := child.LoadRedirectionsIfEnabled(); err != nil {
+		return nil, err
+	}
+
+	return &child, nil
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/patrickdappollonio/http-server/internal/ctype"
+)
+
+// validateSPAFallback checks that the single-page application fallback
+// is an existing file within the path being served, and stores its
+// location on disk.
+func (s *Server) validateSPAFallback() error {
+	location := filepath.Join(s.Path, filepath.FromSlash(strings.TrimPrefix(s.SPAFallback, "/")))
+
+	if !validateIsFileInPath(s.Path, location) {
+		return fmt.Errorf("single-page application fallback %q is outside the server's path %q: it must be served from the server itself", s.SPAFallback, s.Path)
+	}
+
+	info, err := os.Stat(location)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("single-page application fallback %q does not exist in %q", s.SPAFallback, s.Path)
+		}
+
+		return fmt.Errorf("unable to process single-page application fallback at %q: %w", location, err)
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("single-page application fallback %q must be a file, not a directory", s.SPAFallback)
+	}
+
+	s.spaFallbackPath = location
+	return nil
+}
+
+// shouldServeSPAFallback checks whether the request, for a path that
+// doesn't exist, should be served the single-page application fallback:
+// only requests from browsers navigating to a page qualify, while missing
+// assets like scripts or images still return a "not found" error.
+func (s *Server) shouldServeSPAFallback(r *http.Request) bool {
+	if s.spaFallbackPath == "" {
+		return false
+	}
+
+	return !isAssetPath(r.URL.Path) && acceptsHTML(r)
+}
+
+// isAssetPath checks if the last segment of the path has a file extension
+// with a known content type, like "app.js" or "logo.png".
+func isAssetPath(p string) bool {
+	ext := strings.ToLower(path.Ext(p))
+	if ext == "" {
+		return false
+	}
+
+	return ctype.GetContentTypeForFilename("file"+ext) != "" || mime.TypeByExtension(ext) != ""
+}
+
+// acceptsHTML checks if the request accepts an HTML response, either
+// explicitly or through a wildcard, without it being excluded with "q=0".
+func acceptsHTML(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept") {
+		for _, accepted := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+			if err != nil {
+				continue
+			}
+
+			if q, found := params["q"]; found {
+				if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+					continue
+				}
+			}
+
+			switch mediaType {
+			case "text/html", "text/*", "*/*":
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSPAFallback(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"index.html":        "spa",
+		"404.html":          "custom not found",
+		"about.txt":         "about",
+		"assets/app.js":     "app",
+		"docs/readme.txt":   "readme",
+		"nested/index.html": "nested index",
+	}
+
+	for name, content := range files {
+		location := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+		if err := os.WriteFile(location, []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name                 string
+		path                 string
+		accept               string
+		customNotFound       bool
+		disableDirectoryList bool
+		expectStatusCode     int
+		expectBody           string
+		expectLocation       string
+	}{
+		{
+			name:             "client-side route",
+			path:             "/dashboard/settings",
+			accept:           "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			expectStatusCode: http.StatusOK,
+			expectBody:       "spa",
+		},
+		{
+			name:             "client-side route with a dot",
+			path:             "/users/john.doe",
+			accept:           "text/html",
+			expectStatusCode: http.StatusOK,
+			expectBody:       "spa",
+		},
+		{
+			name:             "client-side route accepting anything",
+			path:             "/dashboard",
+			accept:           "*/*",
+			expectStatusCode: http.StatusOK,
+			expectBody:       "spa",
+		},
+		{
+			name:             "request not accepting html",
+			path:             "/api/users",
+			accept:           "application/json",
+			expectStatusCode: http.StatusNotFound,
+			expectBody:       "404 not found",
+		},
+		{
+			name:             "request explicitly rejecting html",
+			path:             "/dashboard",
+			accept:           "text/html;q=0, application/json",
+			expectStatusCode: http.StatusNotFound,
+			expectBody:       "404 not found",
+		},
+		{
+			name:             "request without accept header",
+			path:             "/dashboard",
+			expectStatusCode: http.StatusNotFound,
+			expectBody:       "404 not found",
+		},
+		{
+			name:             "missing asset",
+			path:             "/assets/missing.js",
+			accept:           "*/*",
+			expectStatusCode: http.StatusNotFound,
+			expectBody:       "404 not found",
+		},
+		{
+			name:             "existing file",
+			path:             "/about.txt",
+			accept:           "text/html",
+			expectStatusCode: http.StatusOK,
+			expectBody:       "about",
+		},
+		{
+			name:             "existing directory with an index",
+			path:             "/nested/",
+			accept:           "text/html",
+			expectStatusCode: http.StatusOK,
+			expectBody:       "nested index",
+		},
+		{
+			name:             "index files are still redirected",
+			path:             "/index.html",
+			accept:           "text/html",
+			expectStatusCode: http.StatusMovedPermanently,
+			expectLocation:   "/",
+		},
+		{
+			name:             "custom not found page for missing assets",
+			path:             "/assets/missing.js",
+			accept:           "*/*",
+			customNotFound:   true,
+			expectStatusCode: http.StatusNotFound,
+			expectBody:       "custom not found",
+		},
+		{
+			name:             "fallback takes precedence over custom not found page",
+			path:             "/dashboard",
+			accept:           "text/html",
+			customNotFound:   true,
+			expectStatusCode: http.StatusOK,
+			expectBody:       "spa",
+		},
+		{
+			name:                 "directory without index and directory listing disabled",
+			path:                 "/docs/",
+			accept:               "text/html",
+			disableDirectoryList: true,
+			expectStatusCode:     http.StatusOK,
+			expectBody:           "spa",
+		},
+		{
+			name:                 "directory without index and directory listing disabled not accepting html",
+			path:                 "/docs/",
+			accept:               "application/json",
+			disableDirectoryList: true,
+			expectStatusCode:     http.StatusNotFound,
+			expectBody:           "404 not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Path:                 dir,
+				PathPrefix:           "/",
+				LogOutput:            io.Discard,
+				SPAFallback:          "index.html",
+				DisableDirectoryList: tt.disableDirectoryList,
+				ETagDisabled:         true,
+			}
+
+			if tt.customNotFound {
+				s.CustomNotFoundPage = filepath.Join(dir, "404.html")
+			}
+
+			if err := s.validateSPAFallback(); err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rec := httptest.NewRecorder()
+			s.router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectStatusCode {
+				t.Fatalf("expected status code %d, got %d - response: %s", tt.expectStatusCode, rec.Code, rec.Body.String())
+			}
+
+			if tt.expectBody != "" && rec.Body.String() != tt.expectBody {
+				t.Fatalf("expected body %q, got %q", tt.expectBody, rec.Body.String())
+			}
+
+			if loc := rec.Header().Get("Location"); loc != tt.expectLocation {
+				t.Fatalf("expected location %q, got %q", tt.expectLocation, loc)
+			}
+		})
+	}
+}
+
+func TestValidateSPAFallback(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("spa"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "folder"), 0o755); err != nil {
+		t.Fatalf("unable to create directory: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		fallback    string
+		expectError bool
+	}{
+		{name: "file in the served path", fallback: "index.html"},
+		{name: "file with leading slash", fallback: "/index.html"},
+		{name: "missing file", fallback: "app.html", expectError: true},
+		{name: "directory", fallback: "folder", expectError: true},
+		{name: "outside the served path", fallback: "../index.html", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{Path: dir, SPAFallback: tt.fallback}
+			err := s.validateSPAFallback()
+
+			if tt.expectError && err == nil {
+				t.Fatalf("expecting error, got nil")
+			}
+
+			if !tt.expectError && err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"path"
+	"strings"
 )
 
 // startupPrefix is the prefix used for all startup messages
@@ -13,13 +15,42 @@ const startupPrefix = " >"
 func (s *Server) PrintStartup() {
 	fmt.Fprintln(s.LogOutput, "SETUP:")
 
-	fmt.Fprintln(s.LogOutput, startupPrefix, "Configured to use port:", s.Port)
-	fmt.Fprintln(s.LogOutput, startupPrefix, "Serving path:", s.Path)
+	if len(s.Listen) == 0 {
+		fmt.Fprintln(s.LogOutput, startupPrefix, "Configured to use port:", s.Port)
+	} else {
+		for _, addr := range s.listeners {
+			fmt.Fprintln(s.LogOutput, startupPrefix, "Listening on:", addr)
+		}
+	}
+	if len(s.mounts) == 0 {
+		fmt.Fprintln(s.LogOutput, startupPrefix, "Serving path:", s.Path)
+	}
 
 	if s.PathPrefix != "" && s.PathPrefix != "/" {
 		fmt.Fprintln(s.LogOutput, startupPrefix, "Path prefix:", s.PathPrefix)
 	}
 
+	for _, m := range s.mounts {
+		fmt.Fprintln(s.LogOutput, startupPrefix, "Mount", m.PathPrefix, "serving path:", m.Path)
+
+		if engine := m.currentRedirections(); engine != nil {
+			fmt.Fprintf(s.LogOutput, "%s Mount %s redirections enabled from %q (found %d redirections)\n", startupPrefix, m.PathPrefix, m.RedirectionsFilePath(), len(engine.Rules))
+		}
+	}
+
+	for _, vh := range s.vhosts {
+		hosts := strings.Join(vh.patterns, ", ")
+		fmt.Fprintln(s.LogOutput, startupPrefix, "Virtual host", hosts, "serving path:", vh.server.Path)
+
+		if engine := vh.server.currentRedirections(); engine != nil {
+			fmt.Fprintf(s.LogOutput, "%s Virtual host %s redirections enabled from %q (found %d redirections)\n", startupPrefix, hosts, vh.server.RedirectionsFilePath(), len(engine.Rules))
+		}
+	}
+
+	if s.ConfigFile != "" && s.configLoader != nil {
+		fmt.Fprintf(s.LogOutput, "%s Configuration file %q reloaded on changes\n", startupPrefix, s.ConfigFile)
+	}
+
 	if s.DisableDirectoryList {
 		fmt.Fprintln(s.LogOutput, startupPrefix, "Directory listing disabled (including markdown rendering)")
 	}
@@ -32,14 +63,58 @@ func (s *Server) PrintStartup() {
 		fmt.Fprintf(s.LogOutput, "%s Using custom 404 status code: \"%d %s\"\n", startupPrefix, s.CustomNotFoundStatusCode, http.StatusText(s.CustomNotFoundStatusCode))
 	}
 
-	if s.GzipEnabled {
-		fmt.Fprintln(s.LogOutput, startupPrefix, "Gzip compression enabled for supported content types")
+	if s.SPAFallback != "" {
+		fmt.Fprintln(s.LogOutput, startupPrefix, "Single-page application fallback enabled, serving:", s.SPAFallback)
+	}
+
+	if s.Precompressed {
+		fmt.Fprintln(s.LogOutput, startupPrefix, "Precompressed versions of files served to clients accepting them")
+	}
+
+	if encodings := s.compressionEncodings(); len(encodings) > 0 {
+		fmt.Fprintf(s.LogOutput, "%s Compression enabled for supported content types using %s, for responses of at least %s\n", startupPrefix, strings.Join(encodings, ", "), s.CompressionMinSize)
+	}
+
+	if s.HideDotfiles {
+		fmt.Fprintln(s.LogOutput, startupPrefix, "Files and directories starting with a dot hidden")
+	}
+
+	if !s.excludes.Empty() {
+		fmt.Fprintln(s.LogOutput, startupPrefix, "Files and directories matching exclude patterns hidden")
+	}
+
+	switch s.Symlinks {
+	case symlinksFollow:
+		fmt.Fprintln(s.LogOutput, startupPrefix, "Symbolic links followed wherever they point to, even outside the served path")
+	case symlinksWithinRoot:
+		fmt.Fprintln(s.LogOutput, startupPrefix, "Symbolic links only followed when pointing within the served path")
+	case symlinksDeny:
+		fmt.Fprintln(s.LogOutput, startupPrefix, "Symbolic links never followed")
+	}
+
+	if s.StatusEnabled {
+		fmt.Fprintln(s.LogOutput, startupPrefix, "Status endpoint enabled at:", path.Join(s.PathPrefix, specialPath, "status"))
+	}
+
+	if s.DrainPeriod > 0 {
+		fmt.Fprintf(s.LogOutput, "%s Health checks fail for %s before stopping, to drain connections\n", startupPrefix, s.DrainPeriod)
+	}
+
+	if s.cache != nil {
+		fmt.Fprintln(s.LogOutput, startupPrefix, "In-memory cache enabled for files and rendered markdown, with a size of", s.CacheSize)
+	}
+
+	if s.cacheControl != nil && len(s.cacheControl.Rules) > 0 {
+		fmt.Fprintf(s.LogOutput, "%s Cache-Control headers set using %d rules\n", startupPrefix, len(s.cacheControl.Rules))
 	}
 
 	if s.ETagDisabled {
 		fmt.Fprintln(s.LogOutput, startupPrefix, "ETag headers disabled")
 	} else {
-		fmt.Fprintf(s.LogOutput, "%s ETag headers enabled for files smaller than %s\n", startupPrefix, s.ETagMaxSize)
+		fmt.Fprintf(s.LogOutput, "%s ETag headers enabled for all files, and for other responses smaller than %s\n", startupPrefix, s.ETagMaxSize)
+		if s.etagHashes != nil {
+			fmt.Fprintln(s.LogOutput, startupPrefix, "ETag headers for files generated from their content, with hashes saved to:", s.ETagHashCache)
+		}
 	}
 
 	if s.CorsEnabled {
@@ -87,8 +162,8 @@ func (s *Server) PrintStartup() {
 	}
 
 	if !s.DisableRedirects {
-		if s.redirects != nil {
-			fmt.Fprintf(s.LogOutput, "%s Redirections enabled from %q (found %d redirections)\n", startupPrefix, s.getPathToRedirectionsFile(), len(s.redirects.Rules))
+		if engine := s.currentRedirections(); engine != nil {
+			fmt.Fprintf(s.LogOutput, "%s Redirections enabled from %q (found %d redirections)\n", startupPrefix, s.RedirectionsFilePath(), len(engine.Rules))
 		}
 	}
 
@@ -102,4 +177,8 @@ func (s *Server) printWarnings() {
 	if s.JWTSigningKey != "" && len(s.JWTSigningKey) < 32 {
 		s.printWarningf("JWT key is less than 32 characters. It can be brute forced easily.")
 	}
+
+	if s.StatusEnabled && !s.IsBasicAuthEnabled() && s.JWTSigningKey == "" {
+		s.printWarningf("The status endpoint is enabled without authentication: anyone can read the settings of the server.")
+	}
 }
@@ -0,0 +1,342 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// redacted replaces the value of secrets in the status of the server
+const redacted = "REDACTED"
+
+// requestStats counts the requests served by the process, across all the
+// mounts and virtual hosts.
+type requestStats struct {
+	startedAt time.Time
+	total     atomic.Int64
+	inFlight  atomic.Int64
+	byClass   [5]atomic.Int64
+}
+
+// newRequestStats creates the request counters, starting the uptime of
+// the server.
+func newRequestStats() *requestStats {
+	return &requestStats{startedAt: time.Now()}
+}
+
+// countRequests is a middleware counting the requests served, by the
+// class of their status code.
+func (s *Server) countRequests(next http.Handler) http.Handler {
+	if s.stats == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.stats.inFlight.Add(1)
+		defer s.stats.inFlight.Add(-1)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			// Handlers not writing a status code respond with 200 OK
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			s.stats.total.Add(1)
+			if class := status/100 - 1; class >= 0 && class < len(s.stats.byClass) {
+				s.stats.byClass[class].Add(1)
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// statusRoutes registers the endpoints reporting the status of the server
+// under its path prefix. Health checks don't require authentication, so
+// probes can use them, but the detailed status does, and it's only
+// available when enabled with --status-endpoint.
+func (s *Server) statusRoutes(r chi.Router) {
+	r.HandleFunc(path.Join(s.PathPrefix, specialPath, "health"), s.healthCheck)
+	r.HandleFunc(path.Join(s.PathPrefix, specialPath, "ready"), s.readinessCheck)
+
+	if s.StatusEnabled {
+		basicAuth, jwtAuth := s.authMiddlewares()
+		r.With(basicAuth, jwtAuth).HandleFunc(path.Join(s.PathPrefix, specialPath, "status"), s.statusReport)
+	}
+}
+
+// readinessCheck returns 200 OK when the server can serve requests, or
+// 503 Service Unavailable with the checks that failed.
+func (s *Server) readinessCheck(w http.ResponseWriter, _ *http.Request) {
+	failures := s.readinessFailures()
+	if len(failures) == 0 {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+		return
+	}
+
+	// Only the names of the checks are sent, since the details can
+	// include paths and errors from the filesystem, and probes don't
+	// require authentication
+	var checks []string
+	for _, failure := range failures {
+		s.printWarningf("readiness check %q failed: %s", failure.check, failure.detail)
+
+		if !slices.Contains(checks, failure.check) {
+			checks = append(checks, failure.check)
+		}
+	}
+
+	httpErrorf(http.StatusServiceUnavailable, w, "Not ready: %s\n", strings.Join(checks, ", "))
+}
+
+// readinessFailure is a readiness check that failed, with the details of
+// why it failed.
+type readinessFailure struct {
+	check  string
+	detail string
+}
+
+// readinessFailures checks that the served paths are readable, that the
+// templates are loaded and that the redirections files were parsed, and
+// returns the checks that failed.
+func (s *Server) readinessFailures() []readinessFailure {
+	var failures []readinessFailure
+
+	if s.isDraining() {
+		failures = append(failures, readinessFailure{check: "draining", detail: "server is draining connections before stopping"})
+	}
+
+	if s.templates == nil {
+		failures = append(failures, readinessFailure{check: "templates", detail: "templates aren't loaded"})
+	}
+
+	sites := []*Server{s}
+	if len(s.mounts) > 0 {
+		sites = s.mounts
+	}
+
+	for _, site := range sites {
+		if err := checkReadableDir(site.Path); err != nil {
+			failures = append(failures, readinessFailure{check: "path", detail: fmt.Sprintf("path %q isn't readable: %s", site.Path, err)})
+		}
+
+		if msg := site.redirects.reloadError(); msg != "" {
+			failures = append(failures, readinessFailure{check: "redirections", detail: fmt.Sprintf("redirections file %q has errors: %s", site.RedirectionsFilePath(), msg)})
+		}
+	}
+
+	return failures
+}
+
+// checkReadableDir checks that the directory can be opened and listed.
+func checkReadableDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+// serverStatus is the detailed status of the server.
+type serverStatus struct {
+	Version       string         `json:"version"`
+	StartedAt     time.Time      `json:"started_at"`
+	Uptime        string         `json:"uptime"`
+	UptimeSeconds int64          `json:"uptime_seconds"`
+	Ready         bool           `json:"ready"`
+	NotReady      []string       `json:"not_ready,omitempty"`
+	Config        configSummary  `json:"config"`
+	Requests      requestSummary `json:"requests"`
+	Cache         *cacheSummary  `json:"cache,omitempty"`
+}
+
+// configSummary describes the settings of the server, with the values of
+// secrets redacted.
+type configSummary struct {
+	Path               string          `json:"path"`
+	PathPrefix         string          `json:"path_prefix"`
+	Listen             []string        `json:"listen"`
+	Title              string          `json:"title,omitempty"`
+	Username           string          `json:"username,omitempty"`
+	Password           string          `json:"password,omitempty"`
+	JWTSigningKey      string          `json:"jwt_key,omitempty"`
+	DirectoryListing   bool            `json:"directory_listing"`
+	Markdown           bool            `json:"markdown"`
+	CORS               bool            `json:"cors"`
+	Precompressed      bool            `json:"precompressed"`
+	Compression        []string        `json:"compression,omitempty"`
+	ETag               bool            `json:"etag"`
+	Redirections       bool            `json:"redirections"`
+	HideDotfiles       bool            `json:"hide_dotfiles"`
+	Symlinks           string          `json:"symlinks,omitempty"`
+	SinglePageFallback string          `json:"spa_fallback,omitempty"`
+	Mounts             []siteSummary   `json:"mounts,omitempty"`
+	VirtualHosts       []siteSummary   `json:"vhosts,omitempty"`
+	Timeouts           timeoutsSummary `json:"timeouts"`
+	CacheControlRules  int             `json:"cache_control_rules,omitempty"`
+}
+
+// siteSummary describes a mount or a virtual host.
+type siteSummary struct {
+	Prefix string   `json:"prefix,omitempty"`
+	Hosts  []string `json:"hosts,omitempty"`
+	Path   string   `json:"path"`
+}
+
+// timeoutsSummary describes the timeouts of the server.
+type timeoutsSummary struct {
+	Read       string `json:"read"`
+	ReadHeader string `json:"read_header"`
+	Write      string `json:"write"`
+	Idle       string `json:"idle"`
+	Shutdown   string `json:"shutdown"`
+	Drain      string `json:"drain"`
+}
+
+// requestSummary counts the requests served since the server started.
+type requestSummary struct {
+	Total    int64            `json:"total"`
+	InFlight int64            `json:"in_flight"`
+	ByStatus map[string]int64 `json:"by_status"`
+}
+
+// cacheSummary describes the usage of the in-memory cache.
+type cacheSummary struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	Entries       int    `json:"entries"`
+	Size          int64  `json:"size"`
+	MaxSize       int64  `json:"max_size"`
+}
+
+// statusReport renders the detailed status of the server as JSON.
+func (s *Server) statusReport(w http.ResponseWriter, _ *http.Request) {
+	status := serverStatus{
+		Version: s.version,
+		Config:  s.configSummary(),
+	}
+
+	failures := s.readinessFailures()
+	status.Ready = len(failures) == 0
+	for _, failure := range failures {
+		status.NotReady = append(status.NotReady, failure.detail)
+	}
+
+	if s.stats != nil {
+		uptime := time.Since(s.stats.startedAt)
+		status.StartedAt = s.stats.startedAt.UTC()
+		status.Uptime = uptime.Truncate(time.Second).String()
+		status.UptimeSeconds = int64(uptime.Seconds())
+
+		status.Requests = requestSummary{
+			Total:    s.stats.total.Load(),
+			InFlight: s.stats.inFlight.Load(),
+			ByStatus: make(map[string]int64, len(s.stats.byClass)),
+		}
+
+		for i := range s.stats.byClass {
+			status.Requests.ByStatus[fmt.Sprintf("%dxx", i+1)] = s.stats.byClass[i].Load()
+		}
+	}
+
+	if s.cache != nil {
+		stats := s.cache.Stats()
+		status.Cache = &cacheSummary{
+			Hits:          stats.Hits,
+			Misses:        stats.Misses,
+			Evictions:     stats.Evictions,
+			Invalidations: stats.Invalidations,
+			Entries:       stats.Entries,
+			Size:          stats.Size,
+			MaxSize:       stats.MaxSize,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(status); err != nil {
+		s.printWarningf("unable to render status: %s", err)
+	}
+}
+
+// configSummary summarizes the settings of the server, redacting secrets.
+func (s *Server) configSummary() configSummary {
+	summary := configSummary{
+		Path:               s.Path,
+		PathPrefix:         s.PathPrefix,
+		Title:              s.PageTitle,
+		DirectoryListing:   !s.DisableDirectoryList,
+		Markdown:           !s.DisableDirectoryList && !s.DisableMarkdown,
+		CORS:               s.CorsEnabled,
+		Precompressed:      s.Precompressed,
+		Compression:        s.compressionEncodings(),
+		ETag:               !s.ETagDisabled,
+		Redirections:       s.redirects != nil,
+		HideDotfiles:       s.HideDotfiles,
+		Symlinks:           s.Symlinks,
+		SinglePageFallback: s.SPAFallback,
+		Timeouts: timeoutsSummary{
+			Read:       s.ReadTimeout.String(),
+			ReadHeader: s.ReadHeaderTimeout.String(),
+			Write:      s.WriteTimeout.String(),
+			Idle:       s.IdleTimeout.String(),
+			Shutdown:   s.ShutdownTimeout.String(),
+			Drain:      s.DrainPeriod.String(),
+		},
+	}
+
+	if s.Username != "" {
+		summary.Username = redacted
+	}
+
+	if s.Password != "" {
+		summary.Password = redacted
+	}
+
+	if s.JWTSigningKey != "" {
+		summary.JWTSigningKey = redacted
+	}
+
+	for _, addr := range s.listeners {
+		summary.Listen = append(summary.Listen, addr.String())
+	}
+
+	if s.cacheControl != nil {
+		summary.CacheControlRules = len(s.cacheControl.Rules)
+	}
+
+	for _, m := range s.mounts {
+		summary.Mounts = append(summary.Mounts, siteSummary{Prefix: m.PathPrefix, Path: m.Path})
+	}
+
+	for _, vh := range s.vhosts {
+		summary.VirtualHosts = append(summary.VirtualHosts, siteSummary{Hosts: vh.patterns, Path: vh.server.Path})
+	}
+
+	return summary
+}
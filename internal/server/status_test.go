@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadinessCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T, s *Server)
+		expectCode  int
+		expectCheck string
+		expectLog   string
+	}{
+		{name: "ready", expectCode: http.StatusOK},
+		{
+			name: "path removed",
+			setup: func(t *testing.T, s *Server) {
+				if err := os.RemoveAll(s.Path); err != nil {
+					t.Fatalf("unable to remove path: %v", err)
+				}
+			},
+			expectCode:  http.StatusServiceUnavailable,
+			expectCheck: "path",
+			expectLog:   "isn't readable",
+		},
+		{
+			name:        "templates not loaded",
+			setup:       func(_ *testing.T, s *Server) { s.templates = nil },
+			expectCode:  http.StatusServiceUnavailable,
+			expectCheck: "templates",
+			expectLog:   "templates aren't loaded",
+		},
+		{
+			name: "invalid redirections file",
+			setup: func(t *testing.T, s *Server) {
+				if err := os.WriteFile(s.RedirectionsFilePath(), []byte("/only-one-field\n"), 0o600); err != nil {
+					t.Fatalf("unable to write redirections file: %v", err)
+				}
+
+				if err := s.ReloadRedirections(); err == nil {
+					t.Fatalf("expected reload of the redirections file to fail")
+				}
+			},
+			expectCode:  http.StatusServiceUnavailable,
+			expectCheck: "redirections",
+			expectLog:   "only-one-field",
+		},
+		{
+			name:        "draining",
+			setup:       func(_ *testing.T, s *Server) { s.draining.Store(true) },
+			expectCode:  http.StatusServiceUnavailable,
+			expectCheck: "draining",
+			expectLog:   "draining connections",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "root")
+			if err := os.Mkdir(dir, 0o755); err != nil {
+				t.Fatalf("unable to create directory: %v", err)
+			}
+
+			if err := os.WriteFile(filepath.Join(dir, redirectionsPath), []byte("/old /new 301\n"), 0o600); err != nil {
+				t.Fatalf("unable to write redirections file: %v", err)
+			}
+
+			var logs bytes.Buffer
+			s := &Server{Port: 5000, Path: dir, PathPrefix: "/", LogOutput: &logs, ETagMaxSize: "5M"}
+			if err := s.Validate(); err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			if err := s.LoadRedirectionsIfEnabled(); err != nil {
+				t.Fatalf("unable to load redirections: %v", err)
+			}
+
+			if err := s.prepare(); err != nil {
+				t.Fatalf("unable to prepare server: %v", err)
+			}
+
+			handler := s.router()
+
+			if tt.setup != nil {
+				tt.setup(t, s)
+			}
+
+			for _, endpoint := range []string{"/_/ready", "/_/health"} {
+				req := httptest.NewRequest(http.MethodGet, endpoint, nil)
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+
+				// The health check only fails while draining
+				expectCode := tt.expectCode
+				if endpoint == "/_/health" && !s.isDraining() {
+					expectCode = http.StatusOK
+				}
+
+				if rec.Code != expectCode {
+					t.Fatalf("expected status code %d for %s, got %d - response: %s", expectCode, endpoint, rec.Code, rec.Body.String())
+				}
+
+				if endpoint != "/_/ready" || tt.expectCheck == "" {
+					continue
+				}
+
+				// Only the names of the failed checks are public, while
+				// the details are logged
+				if want := "Not ready: " + tt.expectCheck + "\n"; rec.Body.String() != want {
+					t.Fatalf("expected response %q, got %q", want, rec.Body.String())
+				}
+
+				if !strings.Contains(logs.String(), tt.expectLog) {
+					t.Fatalf("expected logs to contain %q, got %q", tt.expectLog, logs.String())
+				}
+			}
+		})
+	}
+}
+
+func TestStatusReport(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("content"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+
+	s := &Server{
+		Port:        5000,
+		Path:        dir,
+		PathPrefix:  "/",
+		LogOutput:   io.Discard,
+		ETagMaxSize: "5M",
+		CacheSize:   "1M",
+		Username:    "user",
+		Password:    "s3cr3t",
+
+		StatusEnabled: true,
+	}
+	s.SetVersion("v1.2.3")
+
+	if err := s.Validate(); err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	if err := s.prepare(); err != nil {
+		t.Fatalf("unable to prepare server: %v", err)
+	}
+
+	handler := s.router()
+
+	request := func(path string, auth bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth {
+			req.SetBasicAuth("user", "s3cr3t")
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	request("/file.txt", true)
+	request("/missing.txt", true)
+
+	if rec := request("/_/status", false); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status to require authentication, got %d", rec.Code)
+	}
+
+	rec := request("/_/status", true)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d - response: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	if strings.Contains(rec.Body.String(), "s3cr3t") {
+		t.Fatalf("expected password to be redacted, got %s", rec.Body.String())
+	}
+
+	var status serverStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("unable to parse status %q: %v", rec.Body.String(), err)
+	}
+
+	if status.Version != "v1.2.3" || !status.Ready || status.StartedAt.IsZero() {
+		t.Fatalf("expected version, readiness and start time, got %+v", status)
+	}
+
+	if status.Config.Path != dir || status.Config.Username != redacted || status.Config.Password != redacted {
+		t.Fatalf("expected config summary with redacted credentials, got %+v", status.Config)
+	}
+
+	// The request for the status itself is still in flight
+	requests := status.Requests
+	if requests.Total != 3 || requests.InFlight != 1 || requests.ByStatus["2xx"] != 1 || requests.ByStatus["4xx"] != 2 {
+		t.Fatalf("expected 3 requests, 1 in flight, 1 with 2xx and 2 with 4xx, got %+v", requests)
+	}
+
+	if status.Cache == nil || status.Cache.MaxSize != 1024*1024 {
+		t.Fatalf("expected cache stats, got %+v", status.Cache)
+	}
+
+	// The details of failed readiness checks are only in the status
+	s.draining.Store(true)
+	status = serverStatus{}
+	if err := json.Unmarshal(request("/_/status", true).Body.Bytes(), &status); err != nil {
+		t.Fatalf("unable to parse status: %v", err)
+	}
+
+	if status.Ready || len(status.NotReady) != 1 || !strings.Contains(status.NotReady[0], "draining connections") {
+		t.Fatalf("expected the details of the failed readiness checks, got %+v", status)
+	}
+}
+
+func TestStatusReportDisabled(t *testing.T) {
+	s := &Server{
+		Port:        5000,
+		Path:        t.TempDir(),
+		PathPrefix:  "/",
+		LogOutput:   io.Discard,
+		ETagMaxSize: "5M",
+	}
+
+	if err := s.Validate(); err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	if err := s.prepare(); err != nil {
+		t.Fatalf("unable to prepare server: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_/status", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status to be disabled by default, got %d - response: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/patrickdappollonio/http-server/internal/fileutil"
+)
+
+// Policies for symbolic links within the path being served.
+const (
+	// symlinksFollow follows symbolic links wherever they point to.
+	symlinksFollow = "follow"
+
+	// symlinksWithinRoot follows symbolic links only if they point to a
+	// file or directory within the path being served.
+	symlinksWithinRoot = "within-root"
+
+	// symlinksDeny never follows symbolic links.
+	symlinksDeny = "deny"
+)
+
+// validateSymlinks resolves the path being served, so symbolic links can
+// be checked against it without resolving it on every request.
+func (s *Server) validateSymlinks() error {
+	if s.Symlinks == "" || s.Symlinks == symlinksFollow {
+		return nil
+	}
+
+	root, err := resolvedRoot(s.Path)
+	if err != nil {
+		return fmt.Errorf("unable to resolve symbolic links of path %q: %w", s.Path, err)
+	}
+
+	s.realPath = root
+	return nil
+}
+
+// resolvedRoot returns the absolute path of the given directory, with all
+// symbolic links resolved.
+func resolvedRoot(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err //nolint:wrapcheck // wrapped by the caller
+	}
+
+	return filepath.EvalSymlinks(abs) //nolint:wrapcheck // wrapped by the caller
+}
+
+// realRoot returns the path being served with all symbolic links resolved,
+// resolving it again if it wasn't done during validation.
+func (s *Server) realRoot() (string, error) {
+	if s.realPath != "" {
+		return s.realPath, nil
+	}
+
+	return resolvedRoot(s.Path)
+}
+
+// symlinkAllowed checks if the file or directory at the given location can
+// be served under the symlink policy: with "deny", no symbolic link can be
+// part of its path, and with "within-root", symbolic links can only point
+// to files or directories within the path being served. Locations outside
+// of the path being served, like a custom 404 page, are set by the
+// operator and always allowed.
+func (s *Server) symlinkAllowed(location string) bool {
+	if s.Symlinks == "" || s.Symlinks == symlinksFollow {
+		return true
+	}
+
+	root, err := filepath.Abs(s.Path)
+	if err != nil {
+		return false
+	}
+
+	rel, inside := relativePath(root, location)
+	if !inside {
+		return true
+	}
+
+	realRoot, err := s.realRoot()
+	if err != nil {
+		return false
+	}
+
+	real, err := filepath.EvalSymlinks(location)
+	if err != nil {
+		return false
+	}
+
+	if s.Symlinks == symlinksDeny {
+		return real == filepath.Join(realRoot, rel)
+	}
+
+	_, inside = relativePath(realRoot, real)
+	return inside
+}
+
+// resolveSymlink returns the information of the file or directory the
+// symbolic link at the given location points to, to be shown in a
+// directory listing, and whether the link should be listed at all under
+// the symlink policy. Broken links are listed as they are when links are
+// followed everywhere.
+func (s *Server) resolveSymlink(location string, link os.FileInfo) (os.FileInfo, bool) {
+	if s.Symlinks == symlinksDeny || !s.symlinkAllowed(location) {
+		return nil, false
+	}
+
+	fi, err := os.Stat(location)
+	if err != nil {
+		fi = link
+	}
+
+	return fileutil.SymlinkInfo{FileInfo: fi, Target: s.symlinkTarget(location)}, true
+}
+
+// symlinkTarget returns the target of the symbolic link at the given
+// location, as a URL path if it points within the path being served, or
+// as written in the link otherwise.
+func (s *Server) symlinkTarget(location string) string {
+	if real, err := filepath.EvalSymlinks(location); err == nil {
+		if root, err := s.realRoot(); err == nil {
+			if rel, inside := relativePath(root, real); inside {
+				return path.Join("/", s.PathPrefix, filepath.ToSlash(rel))
+			}
+		}
+	}
+
+	target, err := os.Readlink(location)
+	if err != nil {
+		return ""
+	}
+
+	return target
+}
+
+// relativePath returns the location relative to the given root, and
+// whether the location is the root itself or within it.
+func relativePath(root, location string) (string, bool) {
+	rel, err := filepath.Rel(root, location)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return rel, true
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSymlinks(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	files := map[string]string{
+		filepath.Join(root, "file.txt"):          "file",
+		filepath.Join(root, "dir", "inner.txt"):  "inner",
+		filepath.Join(outside, "secret.txt"):     "secret",
+		filepath.Join(outside, "sub", "doc.txt"): "doc",
+	}
+
+	for location, content := range files {
+		if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+		if err := os.WriteFile(location, []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	links := map[string]string{
+		"link-inside.txt":  "file.txt",
+		"link-dir":         "dir",
+		"link-outside.txt": filepath.Join(outside, "secret.txt"),
+		"link-outside-dir": filepath.Join(outside, "sub"),
+		"broken":           "missing.txt",
+	}
+
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("unable to create symbolic links: %v", err)
+		}
+	}
+
+	tests := []struct {
+		policy      string
+		path        string
+		expectCode  int
+		expectBody  string
+		expectFiles map[string]string
+	}{
+		{policy: symlinksFollow, path: "/file.txt", expectCode: http.StatusOK, expectBody: "file"},
+		{policy: symlinksFollow, path: "/link-inside.txt", expectCode: http.StatusOK, expectBody: "file"},
+		{policy: symlinksFollow, path: "/link-dir/inner.txt", expectCode: http.StatusOK, expectBody: "inner"},
+		{policy: symlinksFollow, path: "/link-outside.txt", expectCode: http.StatusOK, expectBody: "secret"},
+		{policy: symlinksFollow, path: "/link-outside-dir/doc.txt", expectCode: http.StatusOK, expectBody: "doc"},
+		{policy: symlinksFollow, path: "/broken", expectCode: http.StatusNotFound},
+		{
+			policy:     symlinksFollow,
+			path:       "/?output=json",
+			expectCode: http.StatusOK,
+			expectFiles: map[string]string{
+				"dir":              "",
+				"file.txt":         "",
+				"link-inside.txt":  "/file.txt",
+				"link-dir":         "/dir",
+				"link-outside.txt": filepath.Join(outside, "secret.txt"),
+				"link-outside-dir": filepath.Join(outside, "sub"),
+				"broken":           "missing.txt",
+			},
+		},
+
+		{policy: symlinksWithinRoot, path: "/file.txt", expectCode: http.StatusOK, expectBody: "file"},
+		{policy: symlinksWithinRoot, path: "/link-inside.txt", expectCode: http.StatusOK, expectBody: "file"},
+		{policy: symlinksWithinRoot, path: "/link-dir/inner.txt", expectCode: http.StatusOK, expectBody: "inner"},
+		{policy: symlinksWithinRoot, path: "/link-outside.txt", expectCode: http.StatusNotFound},
+		{policy: symlinksWithinRoot, path: "/link-outside-dir/", expectCode: http.StatusNotFound},
+		{policy: symlinksWithinRoot, path: "/link-outside-dir/doc.txt", expectCode: http.StatusNotFound},
+		{
+			policy:     symlinksWithinRoot,
+			path:       "/?output=json",
+			expectCode: http.StatusOK,
+			expectFiles: map[string]string{
+				"dir":             "",
+				"file.txt":        "",
+				"link-inside.txt": "/file.txt",
+				"link-dir":        "/dir",
+			},
+		},
+
+		{policy: symlinksDeny, path: "/file.txt", expectCode: http.StatusOK, expectBody: "file"},
+		{policy: symlinksDeny, path: "/dir/inner.txt", expectCode: http.StatusOK, expectBody: "inner"},
+		{policy: symlinksDeny, path: "/link-inside.txt", expectCode: http.StatusNotFound},
+		{policy: symlinksDeny, path: "/link-dir/", expectCode: http.StatusNotFound},
+		{policy: symlinksDeny, path: "/link-dir/inner.txt", expectCode: http.StatusNotFound},
+		{policy: symlinksDeny, path: "/link-outside.txt", expectCode: http.StatusNotFound},
+		{
+			policy:     symlinksDeny,
+			path:       "/?output=json",
+			expectCode: http.StatusOK,
+			expectFiles: map[string]string{
+				"dir":      "",
+				"file.txt": "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy+" "+tt.path, func(t *testing.T) {
+			s := &Server{
+				Path:       root,
+				PathPrefix: "/",
+				LogOutput:  io.Discard,
+				Symlinks:   tt.policy,
+			}
+
+			if err := s.validateSymlinks(); err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+			s.router().ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectCode {
+				t.Fatalf("expected status code %d, got %d - response: %s", tt.expectCode, rec.Code, rec.Body.String())
+			}
+
+			if tt.expectBody != "" && rec.Body.String() != tt.expectBody {
+				t.Fatalf("expected body %q, got %q", tt.expectBody, rec.Body.String())
+			}
+
+			if tt.expectFiles == nil {
+				return
+			}
+
+			var listing struct {
+				Files []FileInfo `json:"files"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+				t.Fatalf("unable to parse listing %q: %v", rec.Body.String(), err)
+			}
+
+			got := make(map[string]string, len(listing.Files))
+			for _, f := range listing.Files {
+				got[f.Name] = f.SymlinkTarget
+			}
+
+			if !maps.Equal(got, tt.expectFiles) {
+				t.Fatalf("expected files and symlink targets %v, got %v", tt.expectFiles, got)
+			}
+		})
+	}
+}
+
+func TestSymlinksListing(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "file.txt"), []byte("file"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+	if err := os.Symlink("file.txt", filepath.Join(root, "link.txt")); err != nil {
+		t.Skipf("unable to create symbolic links: %v", err)
+	}
+
+	s := &Server{
+		Path:       root,
+		PathPrefix: "/",
+		LogOutput:  io.Discard,
+	}
+
+	templates, err := s.generateTemplates()
+	if err != nil {
+		t.Fatalf("unable to generate templates: %v", err)
+	}
+	s.templates = templates
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	s.router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d - response: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	body := rec.Body.String()
+	if !strings.Contains(body, `title="Symbolic link to /file.txt"`) {
+		t.Errorf("expected the symbolic link target in the listing, got: %s", body)
+	}
+
+	if strings.Count(body, `class="fas fa-link"`) != 1 {
+		t.Errorf("expected a single symbolic link icon in the listing, got: %s", body)
+	}
+}
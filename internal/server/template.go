@@ -7,6 +7,7 @@ import (
 	"path"
 
 	"github.com/patrickdappollonio/http-server/internal/common"
+	"github.com/patrickdappollonio/http-server/internal/fileutil"
 )
 
 // walkTemplatesFS embeds the templates used to render the directory listing
@@ -23,6 +24,7 @@ func (s *Server) generateTemplates() (*template.Template, error) {
 		"humansize":      common.Humansize,
 		"canonicalURL":   common.CanonicalURL,
 		"getIconForFile": getIconForFile,
+		"symlinkTarget":  fileutil.SymlinkTarget,
 		"unsafeHTML":     func(s string) template.HTML { return template.HTML(s) },
 		"default":        common.DefaultValue[any],
 		"serverVersion":  func() string { return s.version },
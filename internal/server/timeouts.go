@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/patrickdappollonio/http-server/internal/common"
+)
+
+// validateTimeouts checks the timeouts and limits of the HTTP server, the
+// time to wait for in-flight requests when stopping, and the time to
+// drain traffic before that.
+func (s *Server) validateTimeouts() error {
+	for _, timeout := range []struct {
+		flag  string
+		value time.Duration
+	}{
+		{"read-timeout", s.ReadTimeout},
+		{"read-header-timeout", s.ReadHeaderTimeout},
+		{"write-timeout", s.WriteTimeout},
+		{"idle-timeout", s.IdleTimeout},
+		{"shutdown-timeout", s.ShutdownTimeout},
+		{"drain-period", s.DrainPeriod},
+	} {
+		if timeout.value < 0 {
+			return fmt.Errorf("invalid --%s %q: must be zero or positive", timeout.flag, timeout.value)
+		}
+	}
+
+	// Reading the headers is part of reading the request, so a longer
+	// timeout for the headers would never apply
+	if s.ReadTimeout > 0 && s.ReadHeaderTimeout > s.ReadTimeout {
+		return fmt.Errorf("invalid --read-header-timeout %q: must not be longer than --read-timeout %q", s.ReadHeaderTimeout, s.ReadTimeout)
+	}
+
+	s.maxHeaderBytes = 0
+	if s.MaxHeaderBytes != "" {
+		size, err := common.ParseSize(s.MaxHeaderBytes)
+		if err != nil {
+			return fmt.Errorf("unable to parse max header bytes: %w", err)
+		}
+
+		if size > math.MaxInt32 {
+			return fmt.Errorf("invalid max header bytes %q: must be smaller than 2G", s.MaxHeaderBytes)
+		}
+
+		s.maxHeaderBytes = int(size)
+	}
+
+	s.draining = &atomic.Bool{}
+	return nil
+}
+
+// httpServer returns the HTTP server for the handler, with the configured
+// timeouts and limits. Zero values use the defaults of the standard
+// library, which means no timeouts.
+func (s *Server) httpServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadTimeout:       s.ReadTimeout,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.maxHeaderBytes,
+	}
+}
+
+// isDraining returns true when the server is about to stop, and health
+// checks fail so load balancers stop sending new requests.
+func (s *Server) isDraining() bool {
+	return s.draining != nil && s.draining.Load()
+}
+
+// drain makes health checks fail and waits for the drain period, so load
+// balancers stop sending new requests before the server stops. Keep-alive
+// connections are closed after their current request.
+func (s *Server) drain(srv *http.Server) {
+	if s.DrainPeriod <= 0 {
+		return
+	}
+
+	fmt.Fprintf(s.LogOutput, "Draining connections for %s before stopping: health checks will fail\n", s.DrainPeriod)
+	s.draining.Store(true)
+	srv.SetKeepAlivesEnabled(false)
+	time.Sleep(s.DrainPeriod)
+}
+
+// shutdownContext returns the context bounding the time to wait for
+// in-flight requests when stopping, without limit if no timeout is set.
+func (s *Server) shutdownContext() (context.Context, context.CancelFunc) {
+	if s.ShutdownTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithTimeout(context.Background(), s.ShutdownTimeout)
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateTimeouts(t *testing.T) {
+	tests := []struct {
+		name               string
+		server             Server
+		wantMaxHeaderBytes int
+		wantError          string
+	}{
+		{name: "no timeouts"},
+		{
+			name: "all timeouts",
+			server: Server{
+				ReadTimeout:       time.Minute,
+				ReadHeaderTimeout: 10 * time.Second,
+				WriteTimeout:      time.Minute,
+				IdleTimeout:       2 * time.Minute,
+				ShutdownTimeout:   30 * time.Second,
+				DrainPeriod:       5 * time.Second,
+				MaxHeaderBytes:    "64K",
+			},
+			wantMaxHeaderBytes: 64 * 1024,
+		},
+		{name: "header timeout without read timeout", server: Server{ReadHeaderTimeout: time.Minute}},
+		{name: "negative timeout", server: Server{WriteTimeout: -time.Second}, wantError: "invalid --write-timeout"},
+		{name: "negative drain period", server: Server{DrainPeriod: -time.Second}, wantError: "invalid --drain-period"},
+		{
+			name:      "header timeout longer than read timeout",
+			server:    Server{ReadTimeout: time.Second, ReadHeaderTimeout: time.Minute},
+			wantError: "must not be longer than --read-timeout",
+		},
+		{name: "invalid max header bytes", server: Server{MaxHeaderBytes: "lots"}, wantError: "unable to parse max header bytes"},
+		{name: "max header bytes too big", server: Server{MaxHeaderBytes: "4G"}, wantError: "must be smaller than 2G"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.server
+
+			err := s.validateTimeouts()
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			srv := s.httpServer(http.NotFoundHandler())
+			if srv.ReadTimeout != s.ReadTimeout || srv.ReadHeaderTimeout != s.ReadHeaderTimeout || srv.WriteTimeout != s.WriteTimeout || srv.IdleTimeout != s.IdleTimeout {
+				t.Fatalf("expected server timeouts to match the settings, got %+v", srv)
+			}
+
+			if srv.MaxHeaderBytes != tt.wantMaxHeaderBytes {
+				t.Fatalf("expected max header bytes %d, got %d", tt.wantMaxHeaderBytes, srv.MaxHeaderBytes)
+			}
+		})
+	}
+}
+
+func TestDrainBeforeShutdown(t *testing.T) {
+	s := &Server{
+		Port:         5000,
+		Path:         t.TempDir(),
+		PathPrefix:   "/",
+		LogOutput:    io.Discard,
+		ETagMaxSize:  "5M",
+		ETagDisabled: true,
+		DrainPeriod:  500 * time.Millisecond,
+	}
+
+	if err := s.Validate(); err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	if err := s.prepare(); err != nil {
+		t.Fatalf("unable to prepare server: %v", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.serve(ctx, []net.Listener{l}, s.handler()) }()
+
+	health := func() int {
+		resp, err := http.Get("http://" + l.Addr().String() + "/_/health")
+		if err != nil {
+			t.Fatalf("unable to request health check: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := health(); code != http.StatusOK {
+		t.Fatalf("expected health check to pass before stopping, got %d", code)
+	}
+
+	stopped := time.Now()
+	stop()
+
+	// Requests are still served while draining, but health checks fail
+	deadline := time.Now().Add(s.DrainPeriod)
+	for !s.isDraining() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if code := health(); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected health check to fail while draining, got %d", code)
+	}
+
+	if err := <-served; err != nil {
+		t.Fatalf("not expecting error on shutdown, got: %v", err)
+	}
+
+	if elapsed := time.Since(stopped); elapsed < s.DrainPeriod {
+		t.Fatalf("expected server to drain for %s before stopping, stopped after %s", s.DrainPeriod, elapsed)
+	}
+}
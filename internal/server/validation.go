@@ -61,6 +61,44 @@ func (s *Server) Validate() error {
 		return fmt.Errorf("unsupported custom not found status code: %d", s.CustomNotFoundStatusCode)
 	}
 
+	// Validate the single-page application fallback, which must be
+	// a file within the path being served
+	if s.SPAFallback != "" {
+		if err := s.validateSPAFallback(); err != nil {
+			return err
+		}
+	}
+
+	// Validate the encodings used to compress responses
+	if err := s.validateCompression(); err != nil {
+		return err
+	}
+
+	// Validate the size of the in-memory cache
+	if err := s.validateCache(); err != nil {
+		return err
+	}
+
+	// Validate the rules for the Cache-Control header
+	if err := s.validateCacheControl(); err != nil {
+		return err
+	}
+
+	// Parse the patterns of files to hide
+	if err := s.validateExclusions(); err != nil {
+		return err
+	}
+
+	// Resolve the path being served for the symlink policy
+	if err := s.validateSymlinks(); err != nil {
+		return err
+	}
+
+	// Read the password and the JWT signing key from their files
+	if err := s.validateSecretFiles(); err != nil {
+		return err
+	}
+
 	// Validate max size for ETag
 	if s.ETagMaxSize == "" {
 		return errors.New("etag max size is required: set it with --etag-max-size")
@@ -81,6 +119,17 @@ func (s *Server) Validate() error {
 
 	s.etagMaxSizeBytes = size
 
+	// Load the content hashes used for the ETags of files
+	if err := s.validateETagHashCache(); err != nil {
+		return err
+	}
+
+	// Hide the configuration file if it lives in the served path, since
+	// a file set with --config may not start with the well-known prefix
+	if s.ConfigFile != "" && validateIsFileInPath(s.Path, s.ConfigFile) {
+		s.forbiddenMatches = append(s.forbiddenMatches, filepath.Base(s.ConfigFile))
+	}
+
 	// Attempt to validate the structure, and grab the errors
 	if err := getValidator().Struct(s); err != nil {
 		// If the error isn't empty, and its type is of ValidationError
@@ -102,6 +151,29 @@ func (s *Server) Validate() error {
 		return fmt.Errorf("unable to validate configuration: %w", err)
 	}
 
+	// Parse the addresses to listen on
+	if err := s.validateListeners(); err != nil {
+		return err
+	}
+
+	// Validate the timeouts and limits of the HTTP server
+	if err := s.validateTimeouts(); err != nil {
+		return err
+	}
+
+	// Count the requests served, for the status endpoint
+	s.stats = newRequestStats()
+
+	// Validate the mounts, once the settings they inherit are valid
+	if err := s.validateMounts(); err != nil {
+		return err
+	}
+
+	// Validate the virtual hosts, which also inherit the settings
+	if err := s.validateVirtualHosts(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
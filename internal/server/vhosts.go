@@ -0,0 +1,165 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// VirtualHost is a directory served for requests whose "Host" header
+// matches the host or any of its aliases, with its own settings. Hosts
+// can start with a wildcard, like "*.example.com", to match any
+// subdomain. Virtual hosts are configured through the configuration
+// file, and requests not matching any of them are served with the
+// top-level settings, as the default host.
+type VirtualHost struct {
+	Host         string   `mapstructure:"host"`
+	Aliases      []string `mapstructure:"aliases"`
+	CustomCSS    string   `mapstructure:"custom-css-file"`
+	SiteSettings `mapstructure:",squash"`
+}
+
+// virtualHost is a validated virtual host, with the server handling its
+// requests.
+type virtualHost struct {
+	patterns []string
+	server   *Server
+}
+
+// validateVirtualHosts validates each virtual host and creates the server
+// handling it, based on the top-level settings.
+func (s *Server) validateVirtualHosts() error {
+	s.vhosts = nil
+	if len(s.VirtualHosts) == 0 {
+		return nil
+	}
+
+	var merrs MultiError
+	seen := make(map[string]bool)
+
+	for i, vh := range s.VirtualHosts {
+		patterns := make([]string, 0, 1+len(vh.Aliases))
+		var invalid bool
+
+		for _, name := range append([]string{vh.Host}, vh.Aliases...) {
+			pattern, err := parseHostPattern(name)
+			if err != nil {
+				merrs.Append(fmt.Errorf("invalid virtual host #%d (%q): %w", i+1, vh.Host, err))
+				invalid = true
+				continue
+			}
+
+			if seen[pattern] {
+				merrs.Append(fmt.Errorf("invalid virtual host #%d: host %q is used by another virtual host", i+1, pattern))
+				invalid = true
+				continue
+			}
+
+			seen[pattern] = true
+			patterns = append(patterns, pattern)
+		}
+
+		if invalid {
+			continue
+		}
+
+		child, err := s.newSite(vh.SiteSettings, func(child *Server) {
+			if vh.CustomCSS != "" {
+				child.CustomCSS = vh.CustomCSS
+				child.SkipForceDownloadFiles = append(slices.Clone(child.SkipForceDownloadFiles), vh.CustomCSS)
+			}
+		})
+		if err != nil {
+			merrs.Append(fmt.Errorf("invalid virtual host #%d (%q): %w", i+1, vh.Host, err))
+			continue
+		}
+
+		s.vhosts = append(s.vhosts, virtualHost{patterns: patterns, server: child})
+	}
+
+	if len(merrs.Errors) == 0 {
+		return nil
+	}
+
+	return &merrs
+}
+
+// parseHostPattern validates and normalizes the host of a virtual host,
+// which can be a domain, a domain with a wildcard as its first label, or
+// an IP address.
+func parseHostPattern(host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if host == "" {
+		return "", errors.New("host is required")
+	}
+
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return strings.Trim(host, "[]"), nil
+	}
+
+	domain := strings.TrimPrefix(host, "*.")
+	if domain == "" || strings.ContainsAny(domain, "*/:[] ") || strings.HasPrefix(domain, ".") || strings.Contains(domain, "..") {
+		return "", fmt.Errorf("host %q must be a domain, like \"example.com\", optionally starting with a wildcard, like \"*.example.com\", without a port", host)
+	}
+
+	return host, nil
+}
+
+// normalizeHost returns the host of a request without its port, in
+// lowercase and without a trailing dot.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+}
+
+// virtualHostIndex returns the index of the virtual host matching the
+// host of a request, or -1 if none matches. Exact matches take
+// precedence over wildcards, and longer wildcards over shorter ones.
+func (s *Server) virtualHostIndex(host string) int {
+	host = normalizeHost(host)
+	best, bestLen := -1, 0
+
+	for i, vh := range s.vhosts {
+		for _, pattern := range vh.patterns {
+			if pattern == host {
+				return i
+			}
+
+			if suffix, found := strings.CutPrefix(pattern, "*"); found && strings.HasSuffix(host, suffix) && len(suffix) > bestLen {
+				best, bestLen = i, len(suffix)
+			}
+		}
+	}
+
+	return best
+}
+
+// handler returns the handler for all the requests to the server, which
+// uses the router of the virtual host matching each request, or the
+// top-level router if none matches.
+func (s *Server) handler() http.Handler {
+	defaultRouter := s.router()
+	if len(s.vhosts) == 0 {
+		return defaultRouter
+	}
+
+	routers := make([]http.Handler, len(s.vhosts))
+	for i, vh := range s.vhosts {
+		routers[i] = vh.server.router()
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if i := s.virtualHostIndex(r.Host); i >= 0 {
+			routers[i].ServeHTTP(w, r)
+			return
+		}
+
+		defaultRouter.ServeHTTP(w, r)
+	})
+}
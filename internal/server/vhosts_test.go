@@ -0,0 +1,177 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVirtualHosts(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string]string{
+		"default/index.txt":        "default",
+		"docs/index.txt":           "docs",
+		"docs/_redirections":       "/old /index.txt 302\n",
+		"tenants/index.txt":        "tenants",
+		"private/index.txt":        "private",
+		"private/style.css":        "body {}",
+		"beta/index.txt":           "beta",
+		"default/only-default.txt": "only default",
+	}
+
+	for name, content := range files {
+		location := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+			t.Fatalf("unable to create directory: %v", err)
+		}
+		if err := os.WriteFile(location, []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	// Same as the top-level flag, the custom CSS file is validated
+	// relative to the working directory
+	t.Chdir(filepath.Join(root, "private"))
+
+	s := &Server{
+		Port:         5000,
+		Path:         filepath.Join(root, "default"),
+		PathPrefix:   "/",
+		LogOutput:    io.Discard,
+		PageTitle:    "Default",
+		ETagMaxSize:  "5M",
+		ETagDisabled: true,
+		VirtualHosts: []VirtualHost{
+			{Host: "docs.example.com", Aliases: []string{"www.docs.example.com"}, SiteSettings: SiteSettings{Path: filepath.Join(root, "docs"), PageTitle: "Docs"}},
+			{Host: "*.example.com", SiteSettings: SiteSettings{Path: filepath.Join(root, "tenants")}},
+			{Host: "*.beta.example.com", SiteSettings: SiteSettings{Path: filepath.Join(root, "beta")}},
+			{Host: "private.test", CustomCSS: "style.css", SiteSettings: SiteSettings{Path: filepath.Join(root, "private"), Username: "user", Password: "pass"}},
+		},
+	}
+
+	if err := s.Validate(); err != nil {
+		t.Fatalf("not expecting error, got: %v", err)
+	}
+
+	if err := s.prepare(); err != nil {
+		t.Fatalf("unable to prepare server: %v", err)
+	}
+
+	handler := s.handler()
+
+	tests := []struct {
+		name           string
+		host           string
+		path           string
+		auth           bool
+		expectCode     int
+		expectBody     string
+		expectLocation string
+	}{
+		{name: "exact host", host: "docs.example.com", path: "/index.txt", expectCode: http.StatusOK, expectBody: "docs"},
+		{name: "host with port", host: "docs.example.com:8080", path: "/index.txt", expectCode: http.StatusOK, expectBody: "docs"},
+		{name: "host in uppercase with trailing dot", host: "DOCS.Example.com.", path: "/index.txt", expectCode: http.StatusOK, expectBody: "docs"},
+		{name: "alias", host: "www.docs.example.com", path: "/index.txt", expectCode: http.StatusOK, expectBody: "docs"},
+		{name: "listing with the title of the host", host: "docs.example.com", path: "/", expectCode: http.StatusOK, expectBody: "<title>Docs</title>"},
+		{name: "redirections of the host", host: "docs.example.com", path: "/old", expectCode: http.StatusFound, expectLocation: "/index.txt"},
+		{name: "wildcard", host: "acme.example.com", path: "/index.txt", expectCode: http.StatusOK, expectBody: "tenants"},
+		{name: "wildcard with nested subdomain", host: "a.b.example.com", path: "/index.txt", expectCode: http.StatusOK, expectBody: "tenants"},
+		{name: "longer wildcard takes precedence", host: "acme.beta.example.com", path: "/index.txt", expectCode: http.StatusOK, expectBody: "beta"},
+		{name: "wildcard not matching the domain itself", host: "example.com", path: "/index.txt", expectCode: http.StatusOK, expectBody: "default"},
+		{name: "host with authentication", host: "private.test", path: "/index.txt", expectCode: http.StatusUnauthorized},
+		{name: "host with credentials", host: "private.test", path: "/index.txt", auth: true, expectCode: http.StatusOK, expectBody: "private"},
+		{name: "host with custom css", host: "private.test", path: "/", auth: true, expectCode: http.StatusOK, expectBody: `href="/style.css"`},
+		{name: "unknown host served by the default host", host: "other.test", path: "/only-default.txt", expectCode: http.StatusOK, expectBody: "only default"},
+		{name: "files of the default host not served by others", host: "docs.example.com", path: "/only-default.txt", expectCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Host = tt.host
+			if tt.auth {
+				req.SetBasicAuth("user", "pass")
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectCode {
+				t.Fatalf("expected status code %d, got %d - response: %s", tt.expectCode, rec.Code, rec.Body.String())
+			}
+
+			if tt.expectBody != "" && !strings.Contains(rec.Body.String(), tt.expectBody) {
+				t.Fatalf("expected body to contain %q, got %q", tt.expectBody, rec.Body.String())
+			}
+
+			if loc := rec.Header().Get("Location"); loc != tt.expectLocation {
+				t.Fatalf("expected location %q, got %q", tt.expectLocation, loc)
+			}
+		})
+	}
+}
+
+func TestValidateVirtualHosts(t *testing.T) {
+	root := t.TempDir()
+
+	tests := []struct {
+		name      string
+		vhosts    []VirtualHost
+		wantError string
+		wantPath  string
+	}{
+		{name: "no virtual hosts"},
+		{name: "path inherited from the top-level settings", vhosts: []VirtualHost{{Host: "example.com"}}, wantPath: root},
+		{name: "ip address", vhosts: []VirtualHost{{Host: "[::1]"}}, wantPath: root},
+		{name: "missing host", vhosts: []VirtualHost{{}}, wantError: "host is required"},
+		{name: "host with port", vhosts: []VirtualHost{{Host: "example.com:8080"}}, wantError: "without a port"},
+		{name: "wildcard in the middle", vhosts: []VirtualHost{{Host: "www.*.example.com"}}, wantError: "must be a domain"},
+		{name: "wildcard only", vhosts: []VirtualHost{{Host: "*"}}, wantError: "must be a domain"},
+		{name: "invalid alias", vhosts: []VirtualHost{{Host: "example.com", Aliases: []string{"*example.com"}}}, wantError: `invalid virtual host #1 ("example.com")`},
+		{name: "missing directory", vhosts: []VirtualHost{{Host: "example.com", SiteSettings: SiteSettings{Path: filepath.Join(root, "missing")}}}, wantError: `invalid virtual host #1 ("example.com")`},
+		{
+			name:      "duplicated host",
+			vhosts:    []VirtualHost{{Host: "example.com"}, {Host: "www.example.com", Aliases: []string{"Example.com."}}},
+			wantError: `host "example.com" is used by another virtual host`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Port:         5000,
+				Path:         root,
+				LogOutput:    io.Discard,
+				ETagMaxSize:  "5M",
+				VirtualHosts: tt.vhosts,
+			}
+
+			err := s.validateVirtualHosts()
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("expected error containing %q, got: %v", tt.wantError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			if len(s.vhosts) != len(tt.vhosts) {
+				t.Fatalf("expected %d virtual hosts, got %d", len(tt.vhosts), len(s.vhosts))
+			}
+
+			for _, vh := range s.vhosts {
+				if vh.server.Path != tt.wantPath {
+					t.Fatalf("expected path %q, got %q", tt.wantPath, vh.server.Path)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Package systemd implements the parts of the systemd protocols used by
+// the server: socket activation, to receive listening sockets from
+// systemd, and service notifications, to report the state of the server.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd, after
+// the standard input, output and error.
+const listenFDsStart = 3
+
+// Listeners returns the listeners passed by systemd through socket
+// activation, or none if the process wasn't socket-activated. The
+// environment variables used by systemd are removed, so child processes
+// don't inherit them.
+func Listeners() ([]net.Listener, error) {
+	return listeners(listenFDsStart)
+}
+
+// listeners returns the listeners passed by systemd, starting at the
+// given file descriptor.
+func listeners(start int) ([]net.Listener, error) {
+	pid, count := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS")
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	// The sockets are meant for another process
+	if pid == "" || pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid number of sockets passed by systemd in LISTEN_FDS: %q", count)
+	}
+
+	result := make([]net.Listener, 0, n)
+	for i := range n {
+		name := "LISTEN_FD_" + strconv.Itoa(start+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		// The listener uses a duplicate of the file descriptor, so the
+		// original one can be closed
+		f := os.NewFile(uintptr(start+i), name)
+		l, err := net.FileListener(f)
+		f.Close()
+
+		if err != nil {
+			for _, open := range result {
+				open.Close()
+			}
+
+			return nil, fmt.Errorf("unable to use socket %q passed by systemd: %w", name, err)
+		}
+
+		result = append(result, l)
+	}
+
+	return result, nil
+}
+
+// Notify sends a state change, like "READY=1" or "STOPPING=1", to the
+// service manager. It returns false if the process isn't running under
+// a service manager expecting notifications.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+
+	// Sockets starting with "@" are in the abstract namespace
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("unable to connect to notification socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("unable to send %q to notification socket: %w", state, err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,127 @@
+//go:build unix
+
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListeners(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer l.Close()
+
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("unable to get listener file: %v", err)
+	}
+
+	// The listener takes ownership of the file descriptor passed
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatalf("unable to duplicate file descriptor: %v", err)
+	}
+	f.Close()
+
+	tests := []struct {
+		name      string
+		pid       string
+		count     string
+		wantCount int
+		wantError bool
+	}{
+		{name: "not socket-activated"},
+		{name: "sockets for another process", pid: "1", count: "1"},
+		{name: "invalid count", pid: strconv.Itoa(os.Getpid()), count: "many", wantError: true},
+		{name: "socket-activated", pid: strconv.Itoa(os.Getpid()), count: "1", wantCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LISTEN_PID", tt.pid)
+			t.Setenv("LISTEN_FDS", tt.count)
+			t.Setenv("LISTEN_FDNAMES", "http")
+
+			got, err := listeners(fd)
+			if tt.wantError {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("not expecting error, got: %v", err)
+			}
+
+			if len(got) != tt.wantCount {
+				t.Fatalf("expected %d listeners, got %d", tt.wantCount, len(got))
+			}
+
+			for _, inherited := range got {
+				if inherited.Addr().String() != l.Addr().String() {
+					t.Fatalf("expected listener on %s, got %s", l.Addr(), inherited.Addr())
+				}
+				inherited.Close()
+			}
+
+			for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+				if _, found := os.LookupEnv(name); found {
+					t.Fatalf("expected %s to be removed from the environment", name)
+				}
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	t.Run("without notification socket", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", "")
+
+		sent, err := Notify("READY=1")
+		if err != nil || sent {
+			t.Fatalf("expected nothing to be sent, got sent=%v, err=%v", sent, err)
+		}
+	})
+
+	t.Run("with notification socket", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "notify.sock")
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+		if err != nil {
+			t.Fatalf("unable to create notification socket: %v", err)
+		}
+		defer conn.Close()
+
+		t.Setenv("NOTIFY_SOCKET", socket)
+
+		sent, err := Notify("READY=1")
+		if err != nil || !sent {
+			t.Fatalf("expected state to be sent, got sent=%v, err=%v", sent, err)
+		}
+
+		buf := make([]byte, 64)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("unable to read notification: %v", err)
+		}
+
+		if got := string(buf[:n]); got != "READY=1" {
+			t.Fatalf("expected %q, got %q", "READY=1", got)
+		}
+	})
+
+	t.Run("with missing notification socket", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", filepath.Join(t.TempDir(), "missing.sock"))
+
+		if _, err := Notify("READY=1"); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/patrickdappollonio/http-server/internal/redirects"
+	"github.com/patrickdappollonio/http-server/internal/server"
+	"github.com/spf13/cobra"
+)
+
+// newRedirectsCommand creates the "redirects" command and its subcommands,
+// used to validate redirection files before deploying them
+func newRedirectsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "redirects",
+		Short: "Validate and test redirection files",
+
+		// Subcommands work offline on the files given to them, so the
+		// server settings aren't loaded, nor can they make them fail
+		PersistentPreRunE: func(*cobra.Command, []string) error { return nil },
+	}
+
+	// Subcommands print straight to stdout, skipping the logger
+	cmd.SetOut(os.Stdout)
+
+	cmd.AddCommand(
+		newRedirectsCheckCommand(),
+		newRedirectsTestCommand(),
+		newRedirectsImportCommand(),
+	)
+
+	return cmd
+}
+
+// redirectionsFileIn returns the path to the redirections file within the
+// given served path
+func redirectionsFileIn(servedPath string) string {
+	return (&server.Server{Path: servedPath}).RedirectionsFilePath()
+}
+
+// newRedirectsCheckCommand creates the "redirects check" command, which
+// reports syntax errors, unreachable rules, loops and chains
+func newRedirectsCheckCommand() *cobra.Command {
+	var servedPath string
+
+	cmd := &cobra.Command{
+		Use:   "check [file]",
+		Short: "Check a redirections file for errors, unreachable rules, loops and chains",
+		Long:  "Check a redirections file for errors, unreachable rules, loops and chains.\nIf no file is given, the redirections file in the served path is checked.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file := redirectionsFileIn(servedPath)
+			if len(args) > 0 {
+				file = args[0]
+			}
+
+			engine, err := loadRedirectionsFile(file)
+			if err != nil {
+				return err
+			}
+
+			issues := engine.Lint()
+			for _, issue := range issues {
+				fmt.Fprintln(cmd.OutOrStdout(), issue.String())
+			}
+
+			if len(issues) > 0 {
+				return fmt.Errorf("found %d issues in %d redirection rules in %q", len(issues), len(engine.Rules), file)
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "No issues found in %d redirection rules in %q\n", len(engine.Rules), file)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&servedPath, "path", "d", "./", "path to the served directory holding the redirections file")
+	return cmd
+}
+
+// newRedirectsTestCommand creates the "redirects test" command, which
+// prints the rule matching a request and where it would be sent to
+func newRedirectsTestCommand() *cobra.Command {
+	var (
+		servedPath string
+		file       string
+		headers    []string
+		host       string
+		remoteAddr string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "test <uri>",
+		Short: "Print which redirection rule matches a request URI and its destination",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if file == "" {
+				file = redirectionsFileIn(servedPath)
+			}
+
+			engine, err := loadRedirectionsFile(file)
+			if err != nil {
+				return err
+			}
+
+			req, err := http.NewRequestWithContext(cmd.Context(), http.MethodGet, args[0], nil)
+			if err != nil {
+				return fmt.Errorf("invalid request URI %q: %w", args[0], err)
+			}
+
+			for _, header := range headers {
+				name, value, found := strings.Cut(header, ":")
+				if !found {
+					return fmt.Errorf("invalid header %q: use the format \"Name: value\"", header)
+				}
+				req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+			}
+
+			if host != "" {
+				req.Host = host
+			}
+
+			if remoteAddr != "" {
+				if net.ParseIP(remoteAddr) == nil {
+					return fmt.Errorf("invalid remote address %q: must be an IP address", remoteAddr)
+				}
+				req.RemoteAddr = net.JoinHostPort(remoteAddr, "0")
+			}
+
+			res, err := engine.ResolveRequest(req)
+			if err != nil {
+				if errors.Is(err, redirects.ErrNoMatchingRule) {
+					return fmt.Errorf("no redirection rule in %q matches %q", file, args[0])
+				}
+				return fmt.Errorf("unable to resolve %q: %w", args[0], err)
+			}
+
+			printResult(cmd.OutOrStdout(), res)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&servedPath, "path", "d", "./", "path to the served directory holding the redirections file")
+	flags.StringVarP(&file, "file", "f", "", "path to the redirections file (defaults to the redirections file in the served path)")
+	flags.StringArrayVarP(&headers, "header", "H", nil, "request header in the format \"Name: value\", can be repeated")
+	flags.StringVar(&host, "host", "", "host name of the request")
+	flags.StringVar(&remoteAddr, "remote-addr", "", "IP address of the client making the request")
+
+	return cmd
+}
+
+// newRedirectsImportCommand creates the "redirects import" command, which
+// converts redirections from other servers to the redirections syntax
+func newRedirectsImportCommand() *cobra.Command {
+	var (
+		from   string
+		output string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import [file]",
+		Short: "Convert Netlify, Apache or nginx redirections to the redirections syntax",
+		Long:  "Convert Netlify, Apache or nginx redirections to the redirections syntax.\nIf no file is given, or the file is \"-\", the redirections are read from stdin.\nLines that can't be converted are reported to stderr.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				b   []byte
+				err error
+			)
+
+			if len(args) == 0 || args[0] == "-" {
+				b, err = io.ReadAll(cmd.InOrStdin())
+			} else {
+				b, err = os.ReadFile(args[0]) //nolint:gosec // file is provided by the user running the command
+			}
+			if err != nil {
+				return fmt.Errorf("unable to read redirections to import: %w", err)
+			}
+
+			result, err := redirects.Import(from, string(b))
+			if err != nil {
+				return fmt.Errorf("unable to import redirections: %w", err)
+			}
+
+			for _, issue := range result.Issues {
+				fmt.Fprintln(os.Stderr, issue.String())
+			}
+
+			if output == "" || output == "-" {
+				fmt.Fprint(cmd.OutOrStdout(), result.String())
+			} else if err := os.WriteFile(output, []byte(result.String()), 0o644); err != nil { //nolint:gosec // redirection files are meant to be readable
+				return fmt.Errorf("unable to write redirections file: %w", err)
+			}
+
+			fmt.Fprintf(os.Stderr, "Imported %d redirection rules, %d lines could not be converted\n", len(result.Rules), len(result.Issues))
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&from, "from", "", "format of the redirections to import, one of: "+strings.Join(redirects.ImportFormats, ", "))
+	flags.StringVarP(&output, "output", "o", "", "file to write the converted redirections to (defaults to stdout)")
+	_ = cmd.MarkFlagRequired("from")
+
+	return cmd
+}
+
+// loadRedirectionsFile reads and parses a redirections file
+func loadRedirectionsFile(file string) (*redirects.Engine, error) {
+	b, err := os.ReadFile(file) //nolint:gosec // file is provided by the user running the command
+	if err != nil {
+		return nil, fmt.Errorf("unable to read redirections file: %w", err)
+	}
+
+	engine, err := redirects.New(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("redirection error on file %q: %w", file, err)
+	}
+
+	return engine, nil
+}
+
+// printResult prints the rule that matched a request and what the
+// server would do with it
+func printResult(w io.Writer, res *redirects.Result) {
+	fmt.Fprintf(w, "Matched rule on line %d: %s\n", res.Rule.LineNumber, res.Rule.Source)
+	fmt.Fprintln(w, "Action:", res.Rule.Action)
+
+	switch res.Rule.Action {
+	case redirects.ActionRedirect, redirects.ActionStatus:
+		fmt.Fprintf(w, "Status: %d %s\n", res.StatusCode, http.StatusText(res.StatusCode))
+	case redirects.ActionRewrite, redirects.ActionProxy:
+	}
+
+	if res.Destination != "" {
+		fmt.Fprintln(w, "Destination:", res.Destination)
+	}
+}